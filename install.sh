@@ -52,6 +52,9 @@ chmod +x ~/mybin/plot_singlebp_multiline_cov_facetscales
 cp scripts/plot_singlebp_multiline_cov_facetscales_boxed.R ~/mybin/plot_singlebp_multiline_cov_facetscales_boxed
 chmod +x ~/mybin/plot_singlebp_multiline_cov_facetscales_boxed
 
+cp scripts/plot_singlebp_multiline_cov_ribbon.R ~/mybin/plot_singlebp_multiline_cov_ribbon
+chmod +x ~/mybin/plot_singlebp_multiline_cov_ribbon
+
 cp scripts/plot_cov_helpers.R ~/rlibs
 
 cp scripts/plot_cov_vs_pair.R ~/mybin/plot_cov_vs_pair
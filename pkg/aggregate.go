@@ -0,0 +1,175 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+type AggregateArgs struct {
+	// "mean" (default), "median", "sum", "min", "max", "sd", "sem", or "cv"
+	Method string
+	// Add lower and upper columns: "sd" or "sem" (center minus and plus one
+	// sd or sem), or "minmax" (the smallest and largest values)
+	Spread string
+	// Minimum number of non-NaN values needed; fewer gives NaN (default 1)
+	MinN int
+}
+
+func aggMean(vals []float64) float64 {
+	return sumFloats(vals) / float64(len(vals))
+}
+
+// Sample standard deviation
+func aggSd(vals []float64) float64 {
+	if len(vals) < 2 {
+		return math.NaN()
+	}
+	m := aggMean(vals)
+	ss := 0.0
+	for _, v := range vals {
+		ss += (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(len(vals) - 1))
+}
+
+func aggSem(vals []float64) float64 {
+	return aggSd(vals) / math.Sqrt(float64(len(vals)))
+}
+
+func aggMedian(vals []float64) float64 {
+	sorted := append([]float64{}, vals...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n % 2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func aggMin(vals []float64) float64 {
+	out := vals[0]
+	for _, v := range vals[1:] {
+		out = math.Min(out, v)
+	}
+	return out
+}
+
+func aggMax(vals []float64) float64 {
+	out := vals[0]
+	for _, v := range vals[1:] {
+		out = math.Max(out, v)
+	}
+	return out
+}
+
+// Get the reducer for a method. Reducers are only called with at least one value.
+func AggregateFunc(method string) (func(vals []float64) float64, error) {
+	switch method {
+	case "", "mean": return aggMean, nil
+	case "median": return aggMedian, nil
+	case "sum": return sumFloats, nil
+	case "min": return aggMin, nil
+	case "max": return aggMax, nil
+	case "sd": return aggSd, nil
+	case "sem": return aggSem, nil
+	case "cv": return func(vals []float64) float64 { return aggSd(vals) / aggMean(vals) }, nil
+	}
+	return nil, fmt.Errorf("AggregateFunc: unknown method %q", method)
+}
+
+// Get the function giving the lower and upper bounds around center
+func AggregateSpreadFunc(spread string) (func(vals []float64, center float64) (float64, float64), error) {
+	switch spread {
+	case "sd":
+		return func(vals []float64, center float64) (float64, float64) {
+			sd := aggSd(vals)
+			return center - sd, center + sd
+		}, nil
+	case "sem":
+		return func(vals []float64, center float64) (float64, float64) {
+			sem := aggSem(vals)
+			return center - sem, center + sem
+		}, nil
+	case "minmax":
+		return func(vals []float64, center float64) (float64, float64) {
+			return aggMin(vals), aggMax(vals)
+		}, nil
+	}
+	return nil, fmt.Errorf("AggregateSpreadFunc: unknown spread %q", spread)
+}
+
+// Reduce the value columns (3 onward) of every line of r to chr, start,
+// end, the reduced value, and, if spread is not nil, lower and upper bounds
+func AggregateOne(r io.Reader, reduce func([]float64) float64, spread func([]float64, float64) (float64, float64), minN int) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+
+		var vals []float64
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 4 {
+				continue
+			}
+			vals = vals[:0]
+			for _, field := range line[3:] {
+				if v := AlwaysParseFloat(field); !math.IsNaN(v) {
+					vals = append(vals, v)
+				}
+			}
+
+			center, lower, upper := math.NaN(), math.NaN(), math.NaN()
+			if len(vals) >= minN {
+				center = reduce(vals)
+				if spread != nil {
+					lower, upper = spread(vals, center)
+				}
+			}
+
+			fmt.Fprintf(bw, "%v\t%v\t%v\t%v", line[0], line[1], line[2], center)
+			if spread != nil {
+				fmt.Fprintf(bw, "\t%v\t%v", lower, upper)
+			}
+			fmt.Fprintf(bw, "\n")
+		}
+	})
+}
+
+// Aggregate replicates: join all readers on exact span matches and reduce
+// their value columns to one value per span, with optional lower and upper
+// columns for ribbons. A single reader is treated as a wide table, and all of
+// its columns from 3 on are reduced. Anyargs must be of type AggregateArgs.
+func Aggregate(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	h := Handle("Aggregate: %w")
+
+	var args AggregateArgs
+	if e := UnmarshalJsonOut(anyargs, &args); e != nil {
+		return nil, h(e)
+	}
+	if len(rs) < 1 {
+		return []io.Reader{}, nil
+	}
+	if args.MinN < 1 {
+		args.MinN = 1
+	}
+
+	reduce, e := AggregateFunc(args.Method)
+	if e != nil { return nil, h(e) }
+	var spread func([]float64, float64) (float64, float64)
+	if args.Spread != "" {
+		spread, e = AggregateSpreadFunc(args.Spread)
+		if e != nil { return nil, h(e) }
+	}
+
+	r := rs[0]
+	if len(rs) > 1 {
+		r = JoinSpans(rs...)
+	}
+	return []io.Reader{AggregateOne(r, reduce, spread, args.MinN)}, nil
+}
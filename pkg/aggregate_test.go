@@ -0,0 +1,42 @@
+package covplots
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	reps := []string{
+		"chr1\t0\t10\t1\nchr1\t10\t20\t4\n",
+		"chr1\t0\t10\t3\nchr1\t10\t20\tNaN\n",
+		"chr1\t0\t10\t5\n",
+	}
+	tests := []struct {
+		args AggregateArgs
+		expect string
+	}{
+		{AggregateArgs{}, "chr1\t0\t10\t3\nchr1\t10\t20\t4\n"},
+		{AggregateArgs{Spread: "sd"}, "chr1\t0\t10\t3\t1\t5\nchr1\t10\t20\t4\tNaN\tNaN\n"},
+		{AggregateArgs{Method: "median", Spread: "minmax", MinN: 2}, "chr1\t0\t10\t3\t1\t5\nchr1\t10\t20\tNaN\tNaN\tNaN\n"},
+		{AggregateArgs{Method: "cv"}, "chr1\t0\t10\t0.6666666666666666\nchr1\t10\t20\tNaN\n"},
+	}
+
+	for _, test := range tests {
+		var rs []io.Reader
+		for _, rep := range reps {
+			rs = append(rs, strings.NewReader(rep))
+		}
+		outs, err := Aggregate(rs, test.args)
+		if err != nil {
+			panic(err)
+		}
+		out, err := io.ReadAll(outs[0])
+		if err != nil {
+			panic(err)
+		}
+		if string(out) != test.expect {
+			t.Errorf("args %v: out %q != expect %q", test.args, string(out), test.expect)
+		}
+	}
+}
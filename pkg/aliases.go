@@ -0,0 +1,174 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A map from every chromosome alias (and canonical name) to the canonical name
+type ChrAliases map[string]string
+
+// Read an alias table. Each tab-separated line holds a canonical chromosome
+// name followed by any number of aliases for it. Lines starting with "#" are
+// comments.
+func ReadChrAliases(r io.Reader) (ChrAliases, error) {
+	h := Handle("ReadChrAliases: %w")
+
+	out := ChrAliases{}
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		if s.Text() == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+		names := strings.Split(s.Text(), "\t")
+		canon := names[0]
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if prev, ok := out[name]; ok && prev != canon {
+				return nil, h(fmt.Errorf("alias %v maps to both %v and %v", name, prev, canon))
+			}
+			out[name] = canon
+		}
+	}
+	if s.Err() != nil {
+		return nil, h(s.Err())
+	}
+	return out, nil
+}
+
+func ReadChrAliasesPath(path string) (ChrAliases, error) {
+	r, e := OpenMaybeGz(path)
+	if e != nil { return nil, fmt.Errorf("ReadChrAliasesPath: %w", e) }
+	defer r.Close()
+
+	return ReadChrAliases(r)
+}
+
+// Get the canonical version of a full chromosome name. The whole name is
+// looked up first, then the chromosome part according to naming, keeping the
+// parent. The second return value is false if neither is in the table.
+func (a ChrAliases) Rename(name string, naming ChrNaming) (string, bool) {
+	if canon, ok := a[name]; ok {
+		return canon, true
+	}
+	chr, parent := naming.Split(name)
+	if canon, ok := a[chr]; ok {
+		return naming.Join(canon, parent), true
+	}
+	return name, false
+}
+
+// Counts of records whose chromosome was not in the alias table
+type AliasReport struct {
+	mu sync.Mutex
+	Total int
+	Unmapped map[string]int
+}
+
+func (r *AliasReport) add(total int, unmapped map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Unmapped == nil {
+		r.Unmapped = map[string]int{}
+	}
+	r.Total += total
+	for chr, n := range unmapped {
+		r.Unmapped[chr] += n
+	}
+}
+
+func (r *AliasReport) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chrs []string
+	nunmapped := 0
+	for chr, n := range r.Unmapped {
+		chrs = append(chrs, chr)
+		nunmapped += n
+	}
+	sort.Strings(chrs)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ChrAliases: %v of %v records had unmapped chromosomes", nunmapped, r.Total)
+	for _, chr := range chrs {
+		fmt.Fprintf(&b, "\n\t%v\t%v", chr, r.Unmapped[chr])
+	}
+	return b.String()
+}
+
+// Rename the chromosome of every line in r to its canonical name. Lines with
+// unknown chromosomes are passed through unchanged and counted in report.
+// If skipHeader is set, the first line is passed through untouched.
+func AliasChrs(r io.Reader, aliases ChrAliases, naming ChrNaming, skipHeader bool, report *AliasReport) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+
+		total := 0
+		unmapped := map[string]int{}
+		defer func() {
+			if report != nil {
+				report.add(total, unmapped)
+			}
+		}()
+
+		if skipHeader && s.Scan() {
+			fmt.Fprintln(bw, s.Text())
+		}
+		for s.Scan() {
+			name, rest, found := strings.Cut(s.Text(), "\t")
+			canon, ok := aliases.Rename(name, naming)
+			if !ok {
+				unmapped[name]++
+			}
+			total++
+			if !found {
+				fmt.Fprintln(bw, canon)
+				continue
+			}
+			fmt.Fprintf(bw, "%s\t%s\n", canon, rest)
+		}
+	})
+}
+
+// Apply AliasChrs to every reader
+func AliasChrsMulti(aliases ChrAliases, naming ChrNaming, skipHeader bool, report *AliasReport, rs ...io.Reader) []io.Reader {
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		out[i] = AliasChrs(r, aliases, naming, skipHeader, report)
+	}
+	return out
+}
+
+// Rename the chromosomes of bed entries (such as selected windows) to canonical names
+func AliasBedEntries(entries []BedEntry, aliases ChrAliases, naming ChrNaming) []BedEntry {
+	out := make([]BedEntry, len(entries))
+	for i, entry := range entries {
+		canon, ok := aliases.Rename(entry.Chr, naming)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "AliasBedEntries: chromosome %v not in alias table\n", entry.Chr)
+		}
+		entry.Chr = canon
+		out[i] = entry
+	}
+	return out
+}
+
+// Load the config's alias table, or nil if it has none
+func GetConfigAliases(cfg UltimateConfig) (ChrAliases, error) {
+	if cfg.ChrAliases == "" {
+		return nil, nil
+	}
+	return ReadChrAliasesPath(cfg.ChrAliases)
+}
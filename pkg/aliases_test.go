@@ -0,0 +1,41 @@
+package covplots
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var aliastable = `# canonical	aliases
+2L	chr2L	NT_033779.5
+X	chrX	NC_004354.4
+`
+
+var aliasin = `chr2L_ISO1	0	100	1
+NT_033779.5	100	200	2
+X_A4	0	100	3
+chr4_ISO1	0	100	4
+`
+
+var aliasout = `2L_ISO1	0	100	1
+2L	100	200	2
+X_A4	0	100	3
+chr4_ISO1	0	100	4
+`
+
+func TestAliasChrs(t *testing.T) {
+	aliases, e := ReadChrAliases(strings.NewReader(aliastable))
+	if e != nil { panic(e) }
+
+	report := new(AliasReport)
+	r := AliasChrs(strings.NewReader(aliasin), aliases, ChrNaming{}, false, report)
+
+	var b strings.Builder
+	io.Copy(&b, r)
+	if b.String() != aliasout {
+		t.Errorf("b.String() %v != aliasout %v", b.String(), aliasout)
+	}
+	if report.Total != 4 || report.Unmapped["chr4_ISO1"] != 1 || len(report.Unmapped) != 1 {
+		t.Errorf("unexpected report %v", report)
+	}
+}
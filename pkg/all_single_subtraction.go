@@ -14,6 +14,8 @@ func GetAllSubtractSingleFlags() AllSingleFlags {
 	flag.StringVar(&f.Config, "i", "", "Input config file. Tab-separated columns containing input bed path 1, input bed path 2, chromosome length bed path, and output prefix. Default stdin.")
 	flag.IntVar(&f.WinSize, "w", 1000000, "Sliding window plot size (default = 1000000).")
 	flag.IntVar(&f.WinStep, "s", 1000000, "Sliding window step distance (default = 1000000).")
+	flag.StringVar(&f.Naming.Sep, "sep", "", "Separator between chromosome and parent names (default \"_\").")
+	flag.StringVar(&f.Naming.Parent, "parent", "", "Position of the parent name: suffix (default), prefix, or none.")
 	flag.Parse()
 
 	return f
@@ -32,7 +34,7 @@ func SubtractSinglePlotWinsParallel(cfgs []Config, winsize, winstep, threads int
 	for i:=0; i<threads; i++ {
 		go func() {
 			for cfg := range jobs {
-				errs <- SubtractSinglePlotWins(cfg.Inpath, cfg.Inpath2, cfg.Chrlenpath, cfg.Outpre, winsize, winstep)
+				errs <- SubtractSinglePlotWinsNamed(cfg.Inpath, cfg.Inpath2, cfg.Chrlenpath, cfg.Outpre, winsize, winstep, cfg.Naming)
 			}
 		}()
 	}
@@ -56,6 +58,9 @@ func RunAllSubtractSinglePlots() {
 	if err != nil {
 		panic(err)
 	}
+	for i, _ := range cfgs {
+		cfgs[i].Naming = f.Naming
+	}
 
 	err = SubtractSinglePlotWinsParallel(cfgs, f.WinSize, f.WinStep, 8)
 	if err != nil {
@@ -65,7 +70,12 @@ func RunAllSubtractSinglePlots() {
 
 // Do just one subtraction job in sliding windows across the whole genome
 func SubtractSinglePlotWins(inpath1, inpath2, chrlenpath, outpre string, winsize, winstep int) error {
-	chrlens, err := GetChrLens(chrlenpath)
+	return SubtractSinglePlotWinsNamed(inpath1, inpath2, chrlenpath, outpre, winsize, winstep, ChrNaming{})
+}
+
+// SubtractSinglePlotWins with a chromosome naming scheme
+func SubtractSinglePlotWinsNamed(inpath1, inpath2, chrlenpath, outpre string, winsize, winstep int, naming ChrNaming) error {
+	chrlens, err := GetChrLensNamed(chrlenpath, naming)
 	if err != nil {
 		return fmt.Errorf("SubtactSinglePlotWins: %w", err)
 	}
@@ -75,7 +85,7 @@ func SubtractSinglePlotWins(inpath1, inpath2, chrlenpath, outpre string, winsize
 		for start := 0; start < chrlen; start += winstep {
 			end := start + winsize
 			outpre2 := fmt.Sprintf("%s_%v_%v_%v", outpre, chr, start, end)
-			err = SubtractSinglePlotPath(inpath1, inpath2, outpre2, chr, start, end)
+			err = SubtractSinglePlotPathNamed(inpath1, inpath2, naming, outpre2, chr, start, end)
 			if err != nil {
 				return fmt.Errorf("SubtractSinglePlotWins: %w", err)
 			}
@@ -87,6 +97,11 @@ func SubtractSinglePlotWins(inpath1, inpath2, chrlenpath, outpre string, winsize
 
 // Do just one subtraction job at one specified range
 func SubtractSinglePlotPath(path1, path2 string, outpre, chr string, start, end int) error {
+	return SubtractSinglePlotPathNamed(path1, path2, ChrNaming{}, outpre, chr, start, end)
+}
+
+// SubtractSinglePlotPath with a chromosome naming scheme
+func SubtractSinglePlotPathNamed(path1, path2 string, naming ChrNaming, outpre, chr string, start, end int) error {
 	r1, err := os.Open(path1)
 	if err != nil {
 		return fmt.Errorf("SubtractSinglePlotPath: %w", err)
@@ -99,7 +114,7 @@ func SubtractSinglePlotPath(path1, path2 string, outpre, chr string, start, end
 	}
 	defer r2.Close()
 
-	err = SubtractSinglePlot(r1, r2, outpre, chr, start, end)
+	err = SubtractSinglePlotNamed(r1, r2, naming, outpre, chr, start, end)
 	if err != nil {
 		return fmt.Errorf("SubtractSinglePlotPath: %w", err)
 	}
@@ -229,12 +244,17 @@ func subtractOld(r1, r2 io.Reader) (*strings.Reader, error) {
 
 // Do a full subtraction and plot for exactly two datasets
 func SubtractSinglePlot(r1, r2 io.Reader, outpre, chr string, start, end int) error {
-	fr1, err := Filter(r1, chr, start, end)
+	return SubtractSinglePlotNamed(r1, r2, ChrNaming{}, outpre, chr, start, end)
+}
+
+// SubtractSinglePlot with a chromosome naming scheme
+func SubtractSinglePlotNamed(r1, r2 io.Reader, naming ChrNaming, outpre, chr string, start, end int) error {
+	fr1, err := FilterNamed(r1, naming, chr, start, end)
 	if err != nil {
 		return err
 	}
 
-	fr2, err := Filter(r2, chr, start, end)
+	fr2, err := FilterNamed(r2, naming, chr, start, end)
 	if err != nil {
 		return err
 	}
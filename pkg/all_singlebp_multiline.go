@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"math"
 	"github.com/montanaflynn/stats"
-	"strconv"
 	"github.com/jgbaldwinbrown/shellout/pkg"
 	"strings"
 	"io"
@@ -56,10 +55,10 @@ func RunAllMultiplot() {
 }
 
 // Take a set of input readers and apply a chrom, start, end filter to each one
-func FilterMulti(chr string, start, end int, rs ...io.Reader) ([]io.Reader, error) {
+func FilterMulti(naming ChrNaming, chr string, start, end int, rs ...io.Reader) ([]io.Reader, error) {
 	var out []io.Reader
 	for _, r := range rs {
-		fr, err := Filter(r, chr, start, end)
+		fr, err := FilterNamed(r, naming, chr, start, end)
 		if err != nil {
 			return nil, fmt.Errorf("FilterMulti: %w", err)
 		}
@@ -68,6 +67,23 @@ func FilterMulti(chr string, start, end int, rs ...io.Reader) ([]io.Reader, erro
 	return out, nil
 }
 
+// Like FilterMulti, but pass the first line of each reader through unfiltered as a header
+func FilterMultiKeepHeader(naming ChrNaming, chr string, start, end int, rs ...io.Reader) ([]io.Reader, error) {
+	var out []io.Reader
+	for _, r := range rs {
+		header, rest, err := ReadHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("FilterMultiKeepHeader: %w", err)
+		}
+		fr, err := FilterNamed(rest, naming, chr, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("FilterMultiKeepHeader: %w", err)
+		}
+		out = append(out, io.MultiReader(strings.NewReader(strings.Join(header, "\t") + "\n"), fr))
+	}
+	return out, nil
+}
+
 // Take a set of plottable bed streams and a name for each one, and append the respective names to
 // the end of each line for each reader, then return a concatenated version of the streams
 func CombineSinglebpPlots(names []string, rs ...io.Reader) (*strings.Reader, error) {
@@ -222,6 +238,23 @@ plot_singlebp_multiline_cov_facet %v %v %v %v
 	return shellout.ShellPiped(script, os.Stdin, os.Stdout, os.Stderr)
 }
 
+// Wrapper for plot_singlebp_multiline_cov_ribbon, which reads LOWER and UPPER columns after VAL
+func PlotMultiRibbon(outpre string, ylim []float64) error {
+	script := fmt.Sprintf(
+		`#!/bin/bash
+set -e
+
+plot_singlebp_multiline_cov_ribbon %v %v %v %v
+`,
+		fmt.Sprintf("%v_plfmt.bed", outpre),
+		fmt.Sprintf("%v_plotted.png", outpre),
+		ylim[0],
+		ylim[1],
+	)
+
+	return shellout.ShellPiped(script, os.Stdin, os.Stdout, os.Stderr)
+}
+
 // A placeholder function that takes in a set of readers and returns nil
 func Nop([]io.Reader, any) ([]io.Reader, error) {return nil, nil}
 
@@ -264,6 +297,9 @@ func GetFunc(fstr string) func(rs []io.Reader, args any) ([]io.Reader, error) {
 	case "log10": return Log10
 	case "abs": return Abs
 	case "add": return Add
+	case "expr": return ExprFunc
+	case "aggregate": return Aggregate
+	case "filter": return FilterConditions
 	case "gunzip": return Gunzip
 	case "chrgrep": return ChrGrep
 	case "colgrep": return ColGrep
@@ -294,6 +330,109 @@ func GetFunc(fstr string) func(rs []io.Reader, args any) ([]io.Reader, error) {
 	return Panic
 }
 
+// Information about the enclosing config and plot window, for pipeline functions that need more than their own arguments
+type MultiplotFuncCtx struct {
+	Cfg UltimateConfig
+	Outpre string
+	Chr string
+	Start int
+	End int
+	Fullchr bool
+	// Shared by all windows of the config; may be nil
+	Data *ConfigData
+
+	// Set for the pass over the whole genome that fits statistics for the
+	// windows. That pass writes no files and no alias report.
+	wholeGenomePass bool
+	// The input of the current function over the whole genome, and the key
+	// of statistics fitted to it in Data
+	wholeInput func() ([]io.Reader, []io.Closer, error)
+	wholeInputKey string
+}
+
+// The config's chromosome aliases, or nil
+func (ctx MultiplotFuncCtx) aliases() ChrAliases {
+	if ctx.Data == nil {
+		return nil
+	}
+	return ctx.Data.Aliases
+}
+
+// The chromosomes of the config's chrlens file, without their parents, or
+// nil if it has none. The file is read once per config.
+func (ctx MultiplotFuncCtx) chrLens() ([]ChrLenSet, error) {
+	if ctx.Cfg.Chrlens == "" {
+		return nil, nil
+	}
+	return configCached(ctx.Data, "chrlens", func() ([]ChrLenSet, error) {
+		return GetChrLensAliased(ctx.Cfg.Chrlens, ctx.Cfg.Naming, ctx.aliases())
+	})
+}
+
+// The length of each chromosome
+func chrLenMap(chrlens []ChrLenSet) map[string]int64 {
+	lens := map[string]int64{}
+	for _, cl := range chrlens {
+		lens[cl.Chr] = int64(cl.Len)
+	}
+	return lens
+}
+
+// The context of a pass over the whole genome, to fit statistics that every
+// window of the config shares
+func (ctx MultiplotFuncCtx) wholeGenome() MultiplotFuncCtx {
+	return MultiplotFuncCtx{Cfg: ctx.Cfg, Chr: "full_genome", Fullchr: true, Data: ctx.Data, wholeGenomePass: true}
+}
+
+// Like GetFunc, but also provides the functions that depend on the enclosing config
+func GetCtxFunc(fstr string, ctx MultiplotFuncCtx) func(rs []io.Reader, args any) ([]io.Reader, error) {
+	switch fstr {
+	case "preset_cols":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return PresetColumns(rs, args, ctx.Cfg.ColumnPresets)
+		}
+	case "preset_cols_some":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return PresetColumnsSome(rs, args, ctx.Cfg.ColumnPresets)
+		}
+	case "liftover":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return Liftover(rs, args, ctx)
+		}
+	case "fill_gaps":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return FillGaps(rs, args, ctx)
+		}
+	case "mask":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return Mask(rs, args, ctx)
+		}
+	case "keep_regions":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return KeepRegions(rs, args, ctx)
+		}
+	case "annotate_overlap":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return AnnotateOverlap(rs, args, ctx)
+		}
+	case "rebin":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return Rebin(rs, args, ctx)
+		}
+	case "rechr":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return ReChrNamed(rs, args, ctx.Cfg.Naming)
+		}
+	case "normalize":
+		return func(rs []io.Reader, args any) ([]io.Reader, error) {
+			return NormalizeCtx(rs, args, ctx)
+		}
+	case "running_median", "gaussian_smooth", "loess", "ema":
+		return SmoothCtx(fstr, ctx)
+	default: return GetFunc(fstr)
+	}
+}
+
 // A structure that can read and close gzipped files
 type GzReader struct {
 	f *os.File
@@ -362,7 +501,41 @@ func CloseAny[T any](ts ...T) {
 // The function that runs the main logic. It takes an input set and a range,
 // then opens a stream for every input element, applies all filters, combines
 // all streams to one stream, writes to a file, and closes all streams.
-func MultiplotInputSet(cfg InputSet, chr string, start, end int, fullchr bool) (io.Reader, []io.Closer, error) {
+func MultiplotInputSet(cfg InputSet, ctx MultiplotFuncCtx) (io.Reader, []io.Closer, error) {
+	chr, start, end, fullchr := ctx.Chr, ctx.Start, ctx.End, ctx.Fullchr
+
+	frs, closers, err := inputSetStreams(cfg, ctx, len(cfg.Functions))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(frs) != 1 {
+		CloseAny(closers...)
+		return nil, nil, fmt.Errorf("Need exactly one reader")
+	}
+
+
+	var out io.Reader = frs[0]
+	if !fullchr {
+		outs, err := FilterMulti(ctx.Cfg.Naming, chr, start, end, frs[0])
+		if err != nil {
+			CloseAny(closers...)
+			return nil, nil, fmt.Errorf("MultiplotInputSet: during FilterMulti 2: %w", err)
+		}
+		if len(outs) != 1 {
+			CloseAny(closers...)
+			return nil, nil, fmt.Errorf("Need exactly one reader")
+		}
+		out = outs[0]
+	}
+
+
+	return out, closers, err
+}
+
+// Open the paths of an input set and run its first nfuncs functions
+func inputSetStreams(cfg InputSet, ctx MultiplotFuncCtx, nfuncs int) ([]io.Reader, []io.Closer, error) {
+	chr, start, end, fullchr := ctx.Chr, ctx.Start, ctx.End, ctx.Fullchr
+
 	rs, err := OpenPaths(cfg.Paths...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("MultiplotInputSet: during OpenPaths: %w", err)
@@ -372,9 +545,27 @@ func MultiplotInputSet(cfg InputSet, chr string, start, end int, fullchr bool) (
 		closers = append(closers, r.(io.Closer))
 	}
 
+	if aliases := ctx.aliases(); aliases != nil {
+		report := ctx.Data.AliasReport
+		if ctx.wholeGenomePass {
+			report = nil
+		}
+		rs = AliasChrsMulti(aliases, ctx.Cfg.Naming, cfg.Header, report, rs...)
+	}
+
+	// Liftover moves records between coordinate systems, so sets that use it
+	// are only filtered to the window after their functions have run
+	prefilter := !fullchr && !containsString(cfg.Functions, "liftover")
+
 	var frs []io.Reader
-	if !fullchr {
-		frs, err = FilterMulti(chr, start, end, rs...)
+	if prefilter && cfg.Header {
+		frs, err = FilterMultiKeepHeader(ctx.Cfg.Naming, chr, start, end, rs...)
+		if err != nil {
+			CloseAny(closers...)
+			return nil, nil, fmt.Errorf("MultiplotInputSet: during FilterMultiKeepHeader: %w", err)
+		}
+	} else if prefilter {
+		frs, err = FilterMulti(ctx.Cfg.Naming, chr, start, end, rs...)
 		if err != nil {
 			CloseAny(closers...)
 			return nil, nil, fmt.Errorf("MultiplotInputSet: during FilterMulti: %w", err)
@@ -383,9 +574,15 @@ func MultiplotInputSet(cfg InputSet, chr string, start, end int, fullchr bool) (
 		frs = rs
 	}
 
-	for i, funcstr := range cfg.Functions {
+	for i, funcstr := range cfg.Functions[:nfuncs] {
 		fmt.Println("running", funcstr)
-		f := GetFunc(funcstr)
+		i := i
+		fctx := ctx
+		fctx.wholeInput = func() ([]io.Reader, []io.Closer, error) {
+			return inputSetStreams(cfg, ctx.wholeGenome(), i)
+		}
+		fctx.wholeInputKey = fmt.Sprintf("input set %v function %v", cfg.Name, i)
+		f := GetCtxFunc(funcstr, fctx)
 		if len(cfg.FunctionArgs) > i {
 			frs, err = f(frs, cfg.FunctionArgs[i])
 		} else {
@@ -396,28 +593,7 @@ func MultiplotInputSet(cfg InputSet, chr string, start, end int, fullchr bool) (
 			return nil, nil, fmt.Errorf("error when running %v: %w; paths: %v", funcstr, err, cfg.Paths)
 		}
 	}
-	if len(frs) != 1 {
-		CloseAny(closers...)
-		return nil, nil, fmt.Errorf("Need exactly one reader")
-	}
-
-
-	var out io.Reader = frs[0]
-	if !fullchr {
-		outs, err := FilterMulti(chr, start, end, frs[0])
-		if err != nil {
-			CloseAny(closers...)
-			return nil, nil, fmt.Errorf("MultiplotInputSet: during FilterMulti 2: %w", err)
-		}
-		if len(outs) != 1 {
-			CloseAny(closers...)
-			return nil, nil, fmt.Errorf("Need exactly one reader")
-		}
-		out = outs[0]
-	}
-
-
-	return out, closers, err
+	return frs, closers, nil
 }
 
 func CheckPathExists(path string) bool {
@@ -435,13 +611,24 @@ func GzPath(path string, threads int) error {
 
 // All input files are expected to have a chromosome name with the structure "chr_parent". This modifies the stream to just "chr".
 func StripParent(r io.Reader) (io.Reader, error) {
+	return StripParentNamed(r, ChrNaming{})
+}
+
+// Remove the parent from the chromosome name of every line, according to naming
+func StripParentNamed(r io.Reader, naming ChrNaming) (io.Reader, error) {
+	if err := naming.Validate(); err != nil {
+		return nil, err
+	}
 	newr := PipeWrite(func(w io.Writer) {
 		s := bufio.NewScanner(r)
 		s.Buffer([]byte{}, 1e12)
-		re := regexp.MustCompile(`^([^_	]*)_([^	])*`)
 		for s.Scan() {
-			line := re.ReplaceAllString(s.Text(), "$1")
-			fmt.Fprintln(w, line)
+			name, rest, found := strings.Cut(s.Text(), "\t")
+			if !found {
+				fmt.Fprintln(w, naming.Chr(name))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\n", naming.Chr(name), rest)
 		}
 	})
 	return newr, nil
@@ -469,41 +656,96 @@ type MultiplotPlotFuncArgs struct {
 	Start int
 	End int
 	Fullchr bool
+	// Automatic y limits of each facet, if configured
+	FacetYlims map[string][]float64
+	// Shared by all windows of the config; may be nil
+	Data *ConfigData
 }
 
 // Generate plottable files and run plot code for one UltimateConfig
 func Multiplot(cfg UltimateConfig, chr string, start, end int) error {
-	outpre := fmt.Sprintf("%s_%v_%v_%v", cfg.Outpre, chr, start, end)
+	data, err := LoadConfigData(cfg)
+	if err != nil {
+		return fmt.Errorf("Multiplot: %w", err)
+	}
+	defer data.Report(os.Stderr)
+	return MultiplotWithData(cfg, data, chr, start, end)
+}
+
+// Multiplot with data shared by the config's other windows
+func MultiplotWithData(cfg UltimateConfig, data *ConfigData, chr string, start, end int) error {
+	margs, err := MultiplotPrepareWithData(cfg, data, chr, start, end)
+	if err != nil {
+		return fmt.Errorf("Multiplot: %w", err)
+	}
+	if err = MultiplotPlot(margs, nil); err != nil {
+		return fmt.Errorf("Multiplot: %w", err)
+	}
+	return nil
+}
+
+// Generate the plottable files of one window, without plotting them
+func MultiplotPrepare(cfg UltimateConfig, chr string, start, end int) (MultiplotPlotFuncArgs, error) {
+	data, err := LoadConfigData(cfg)
+	if err != nil {
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: %w", err)
+	}
+	defer data.Report(os.Stderr)
+	return MultiplotPrepareWithData(cfg, data, chr, start, end)
+}
+
+// MultiplotPrepare with data shared by the config's other windows
+func MultiplotPrepareWithData(cfg UltimateConfig, data *ConfigData, chr string, start, end int) (MultiplotPlotFuncArgs, error) {
+	if e := ValidateConfig(cfg); e != nil {
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: %w", e)
+	}
+	outpre := WindowOutpre(cfg.Outpre, chr, start, end)
 	if e := os.MkdirAll(outpre, 0776); e != nil {
-		return fmt.Errorf("Multiplot: %w", e)
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: %w", e)
 	}
 
 	var rs []io.Reader
 	fullchr := cfg.Fullchr || chr == "full_genome"
+	ctx := MultiplotFuncCtx{Cfg: cfg, Outpre: outpre, Chr: chr, Start: start, End: end, Fullchr: fullchr, Data: data}
+	var err error
 	for _, set := range cfg.InputSets {
-		r, closers, err := MultiplotInputSet(set, chr, start, end, fullchr)
+		r, closers, err := MultiplotInputSet(set, ctx)
 		if err != nil {
-			return fmt.Errorf("Multiplot: during MultiplotInputSet: %w", err)
+			return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during MultiplotInputSet: %w", err)
 		}
 		defer CloseAny(closers...)
 		rs = append(rs, r)
 	}
 
+	if len(cfg.QuantileNormalize.Sets) > 0 {
+		rs, err = QuantileNormalizeSetsCtx(rs, ctx)
+		if err != nil {
+			return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during QuantileNormalizeSets: %w", err)
+		}
+	}
+
+	if len(cfg.Facets) > 0 {
+		facetOf := facetsBySet(cfg.Facets)
+		for i, set := range cfg.InputSets {
+			rs[i] = AddFacetToOneReader(rs[i], facetOf[set.Name])
+		}
+	}
+
 	var names []string
 	for _, set := range cfg.InputSets {
 		names = append(names, set.Name)
 	}
 
 	var combined io.Reader
-	combined, err := CombineSinglebpPlots(names, rs...)
+	combined, err = CombineSinglebpPlots(names, rs...)
 	if err != nil {
-		return fmt.Errorf("Multiplot: during CombineSinglebpPlots: %w", err)
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during CombineSinglebpPlots: %w", err)
 	}
 
 	if cfg.NoParent {
-		combined, err = StripParent(combined)
+		combined, err = StripParentNamed(combined, cfg.Naming)
 		if err != nil {
-			return fmt.Errorf("Multiplot: during StripParent: %w", err)
+			return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during StripParent: %w", err)
 		}
 	}
 
@@ -514,45 +756,153 @@ func Multiplot(cfg UltimateConfig, chr string, start, end int) error {
 	} else if cfg.ManualChrsBedPath != "" {
 		manualChrs, err := GetManualChrs(cfg.ManualChrsBedPath)
 		if err != nil {
-			return fmt.Errorf("Multiplot: during GetManualChrs: %w", err)
+			return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during GetManualChrs: %w", err)
 		}
 		pf, err = PlfmtSmall(combined, outpre, manualChrs, true)
 	} else {
 		pf, err = PlfmtSmall(combined, outpre, nil, false)
 	}
 	if err != nil {
-		return fmt.Errorf("Multiplot: during PlfmtSmall: %w", err)
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during PlfmtSmall: %w", err)
 	}
-
-	ylim := []float64{-300,300}
-	if cfg.Ylim != nil {
-		ylim = cfg.Ylim
+	if err = WriteInputSetStyles(outpre, cfg.InputSets); err != nil {
+		return MultiplotPlotFuncArgs{}, fmt.Errorf("MultiplotPrepare: during WriteInputSetStyles: %w", err)
 	}
 
-	mPlotFuncArgs := MultiplotPlotFuncArgs{
+	return MultiplotPlotFuncArgs{
 		Plformatter: pf,
 		Cfg: cfg,
 		Chr: chr,
 		Start: start,
 		End: end,
 		Fullchr: fullchr,
+		Data: data,
+	}, nil
+}
+
+// Plot one window from the files that MultiplotPrepare wrote, then gzip its
+// plfmt data. Shared holds y limits computed for all windows, or nil.
+func MultiplotPlot(margs MultiplotPlotFuncArgs, shared *AutoYlims) error {
+	cfg := margs.Cfg
+	outpre := WindowOutpre(cfg.Outpre, margs.Chr, margs.Start, margs.End)
+	ylim := ConfigYlim(cfg)
+	args := cfg.PlotfuncArgs
+
+	if cfg.AutoYlim != nil || len(cfg.Facets) > 0 {
+		var lims AutoYlims
+		var err error
+		if shared != nil {
+			lims = *shared
+		} else if cfg.AutoYlim != nil {
+			if lims, err = PlfmtAutoYlims(cfg, outpre + "_plfmt.bed"); err != nil {
+				return fmt.Errorf("MultiplotPlot: %w", err)
+			}
+		}
+		if lims.Ylim != nil {
+			ylim = lims.Ylim
+		}
+		lims.Facets = facetYlims(cfg.Facets, lims.Facets)
+		margs.FacetYlims = lims.Facets
+		if lims.Facets != nil || len(cfg.Facets) > 0 {
+			path := outpre + "_scales.txt"
+			if err = WriteScalesFile(path, facetScalesYlims(cfg.Facets, lims, ylim)); err != nil {
+				return fmt.Errorf("MultiplotPlot: %w", err)
+			}
+			if _, ok := scalesPlotfuncs[cfg.Plotfunc]; ok {
+				args = autoScalesArgs(args, path)
+			}
+		}
+	}
+
+	if err := WriteHighlights(outpre, cfg.Highlights, margs); err != nil {
+		return fmt.Errorf("MultiplotPlot: %w", err)
 	}
 
 	plotfunc := GetPlotFunc(cfg.Plotfunc)
 
-	err = plotfunc(outpre, ylim, cfg.PlotfuncArgs, mPlotFuncArgs)
+	err := plotfunc(outpre, ylim, args, margs)
 	if err != nil {
-		return fmt.Errorf("Multiplot: during plotfunc: %w", err)
+		return fmt.Errorf("MultiplotPlot: during plotfunc: %w", err)
 	}
 
 	err = GzPath(outpre + "_plfmt.bed", 8)
 	if err != nil {
-		return fmt.Errorf("Multiplot: during GzPath: %w", err)
+		return fmt.Errorf("MultiplotPlot: during GzPath: %w", err)
 	}
 
 	return nil
 }
 
+// Prepare and plot windows, then write the files that cover all of them.
+// With shared automatic y limits, all windows are prepared before any is
+// plotted.
+func MultiplotWins(cfg UltimateConfig, wins []GalleryWin) error {
+	data, err := LoadConfigData(cfg)
+	if err != nil {
+		return fmt.Errorf("MultiplotWins: %w", err)
+	}
+	return MultiplotWinsWithData(cfg, data, wins)
+}
+
+// MultiplotWins with data already loaded for the config. Writes one alias
+// report covering all windows.
+func MultiplotWinsWithData(cfg UltimateConfig, data *ConfigData, wins []GalleryWin) error {
+	h := Handle("MultiplotWins: %w")
+	defer data.Report(os.Stderr)
+	if cfg.AutoYlim == nil || cfg.AutoYlim.Mode != "shared" {
+		for _, w := range wins {
+			if err := MultiplotWithData(cfg, data, w.Chr, w.Start, w.End); err != nil { return h(err) }
+		}
+		if err := FinishMultiplotRunWithData(cfg, data, wins); err != nil { return h(err) }
+		return nil
+	}
+
+	var margs []MultiplotPlotFuncArgs
+	for _, w := range wins {
+		m, err := MultiplotPrepareWithData(cfg, data, w.Chr, w.Start, w.End)
+		if err != nil { return h(err) }
+		margs = append(margs, m)
+	}
+	var paths []string
+	for _, w := range wins {
+		paths = append(paths, WindowOutpre(cfg.Outpre, w.Chr, w.Start, w.End) + "_plfmt.bed")
+	}
+	lims, err := PlfmtAutoYlims(cfg, paths...)
+	if err != nil { return h(err) }
+	for _, m := range margs {
+		if err := MultiplotPlot(m, &lims); err != nil { return h(err) }
+	}
+	if err := FinishMultiplotRunWithData(cfg, data, wins); err != nil { return h(err) }
+	return nil
+}
+
+// The y limits of a config's plots
+func ConfigYlim(cfg UltimateConfig) []float64 {
+	if cfg.Ylim != nil {
+		return cfg.Ylim
+	}
+	return []float64{-300,300}
+}
+
+// FinishMultiplotRunWithData without data already loaded for the config
+func FinishMultiplotRun(cfg UltimateConfig, wins []GalleryWin) error {
+	return FinishMultiplotRunWithData(cfg, nil, wins)
+}
+
+// Write the files that cover all windows of a config: the booklet if
+// requested, and the gallery
+func FinishMultiplotRunWithData(cfg UltimateConfig, data *ConfigData, wins []GalleryWin) error {
+	if cfg.PlotOutput.Booklet {
+		if err := WriteBookletWithData(cfg, wins, data); err != nil {
+			return fmt.Errorf("FinishMultiplotRun: %w", err)
+		}
+	}
+	if err := WriteGallery(cfg.Outpre, wins); err != nil {
+		return fmt.Errorf("FinishMultiplotRun: %w", err)
+	}
+	return nil
+}
+
 // Get the function argument of type "any" from the json input and convet it to
 // [][]int, which is the format used for the subtraction operation
 func ParseSubArgs(args any) ([][]int, error) {
@@ -623,44 +973,33 @@ func NormalizeFloats(in []float64) []float64 {
 	return out
 }
 
-// Normalize all of the data in a set of inputs
-func Normalize(rs []io.Reader, args any) ([]io.Reader, error) {
-	fmt.Println("normalizing now")
-	if len(rs) != 1 {
-		return nil, fmt.Errorf("Normalize: wrong number of paths (%v)", len(rs))
-	}
-	s := bufio.NewScanner(rs[0])
-	s.Buffer([]byte{}, 1e12)
-	var lines [][]string
-	var vals []float64
-	for s.Scan() {
-		line := strings.Split(s.Text(), "\t")
-		if len(line) < 4 {
-			return nil, fmt.Errorf("Normalize: line %v has length %v < 4", line, len(line))
-		}
-		lines = append(lines, line)
-		f, err := strconv.ParseFloat(line[3], 64)
-		if err != nil {
-			f = math.NaN()
+func parseNormalizeArgs(args any) (NormalizeArgs, error) {
+	var nargs NormalizeArgs
+	if args != nil {
+		if err := UnmarshalJsonOut(args, &nargs); err != nil {
+			return nargs, err
 		}
-		vals = append(vals, f)
-	}
-	vals = NormalizeFloats(vals)
-	if len(vals) != len(lines) {
-		return nil, fmt.Errorf("Normalize: len(vals) %v != len(lines) %v", len(vals), len(lines))
 	}
+	return nargs, nil
+}
 
-	var out strings.Builder
-	for i, line := range lines {
-		line[3] = fmt.Sprintf("%f", vals[i])
-		fmt.Fprintln(&out, strings.Join(line, "\t"))
+// Normalize all of the data in a set of inputs. Args may be nil for a z-score, or of type NormalizeArgs.
+func Normalize(rs []io.Reader, args any) ([]io.Reader, error) {
+	fmt.Println("normalizing now")
+	nargs, err := parseNormalizeArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("Normalize: %w", err)
 	}
-	return []io.Reader{strings.NewReader(out.String())}, nil
+	outs, err := NormalizeWith(rs, nargs)
+	if err != nil {
+		return nil, fmt.Errorf("Normalize: %w", err)
+	}
+	return outs, nil
 }
 
 // Plot the whole chromosome, not just a range.
 func MultiplotFullchr(cfg UltimateConfig) error {
-	err := Multiplot(cfg, "full_genome", 0, 0)
+	err := MultiplotWins(cfg, []GalleryWin{{"full_genome", 0, 0}})
 	if err != nil {
 		return fmt.Errorf("MultiplotFullchr: %w", err)
 	}
@@ -673,37 +1012,60 @@ func MultiplotSelectWins(cfg UltimateConfig, wins []BedEntry) error {
 	h := Handle("MultiplotSelectWins: %w")
 	fmt.Printf("MultiplotSelectWins: input: %v\n", wins)
 
+	data, e := LoadConfigData(cfg)
+	if E(e) { return h(e) }
+	if data.Aliases != nil {
+		wins = AliasBedEntries(wins, data.Aliases, cfg.Naming)
+	}
+
+	var gwins []GalleryWin
 	for _, entry := range wins {
-		e := Multiplot(cfg, entry.Chr, int(entry.Start), int(entry.End))
-		if E(e) { return h(e) }
+		gwins = append(gwins, GalleryWin{entry.Chr, int(entry.Start), int(entry.End)})
 	}
+	e = MultiplotWinsWithData(cfg, data, gwins)
+	if E(e) { return h(e) }
 
 	return nil
 }
 
 // Plot sliding windows along the whole genome
 func MultiplotSlide(cfg UltimateConfig, winsize, winstep int) error {
-	chrlens, err := GetChrLens(cfg.Chrlens)
+	data, err := LoadConfigData(cfg)
+	if err != nil {
+		return fmt.Errorf("MultiplotSlide: %w", err)
+	}
+	chrlens, err := GetChrLensAliased(cfg.Chrlens, cfg.Naming, data.Aliases)
 	if err != nil {
 		return fmt.Errorf("MultiplotSlide: %w", err)
 	}
 
+	var wins []GalleryWin
 	for _, chrlenset := range chrlens {
 		chr, chrlen := chrlenset.Chr, chrlenset.Len
 		for start := 0; start < chrlen; start += winstep {
 			end := start + winsize
-			err := Multiplot(cfg, chr, start, end)
-			if err != nil {
-				return fmt.Errorf("MultiplotSlide loop: %w", err)
-			}
+			wins = append(wins, GalleryWin{chr, start, end})
 		}
 	}
+	if err = MultiplotWinsWithData(cfg, data, wins); err != nil {
+		return fmt.Errorf("MultiplotSlide: %w", err)
+	}
 
 	return nil
 }
 
 // Take a set of UltimateConfigs and, for each one, do all necessary plotting (parallel).
 func AllMultiplotParallel(cfgs []UltimateConfig, winsize, winstep, threads int, fullgenome bool, selectWins []BedEntry) error {
+	var invalid Errors
+	for _, cfg := range cfgs {
+		if err := ValidateConfig(cfg); err != nil {
+			invalid = append(invalid, err)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("AllMultiplotParallel: %w", invalid)
+	}
+
 	jobs := make(chan UltimateConfig, len(cfgs))
 	for _, cfg := range cfgs {
 		jobs <- cfg
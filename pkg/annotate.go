@@ -0,0 +1,242 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type AnnotateArgs struct {
+	// Bed or GTF file of features; GTF is detected by a .gtf or .gff extension
+	Path string
+	// "bed" or "gtf", to override the extension
+	Format string
+	// 0-based bed column holding the feature name; default 3
+	NameCol int
+	// GTF attribute holding the feature name; default "gene_name", falling back to "gene_id"
+	Attr string
+	// Only use GTF features of this type (column 3), i.e. "gene"
+	Feature string
+	// If nothing overlaps, annotate with the nearest features, and append a
+	// column with the distance in bp (0 for overlaps, NA if the chromosome has no features)
+	Nearest bool
+	// Separator between names; default ","
+	Sep string
+	// Name written when nothing overlaps; default "."
+	Empty string
+}
+
+// Get the value of a GTF attribute, like gene_name "Adh";
+func GtfAttr(attrs, key string) (string, bool) {
+	for _, field := range strings.Split(attrs, ";") {
+		field = strings.TrimSpace(field)
+		k, v, ok := strings.Cut(field, " ")
+		if ok && k == key {
+			return strings.Trim(strings.TrimSpace(v), `"`), true
+		}
+	}
+	return "", false
+}
+
+// Read features from a GTF file, converting to 0-based half-open spans
+func ReadGtfFeatures(r io.Reader, feature, attr string) ([]BedEntry, error) {
+	h := Handle("ReadGtfFeatures: %w")
+	var out []BedEntry
+
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		if len(s.Text()) == 0 || s.Text()[0] == '#' {
+			continue
+		}
+		line := strings.Split(s.Text(), "\t")
+		if len(line) < 9 {
+			return nil, h(fmt.Errorf("line %v has less than 9 fields", line))
+		}
+		if feature != "" && line[2] != feature {
+			continue
+		}
+		start, e1 := strconv.ParseInt(line[3], 0, 64)
+		end, e2 := strconv.ParseInt(line[4], 0, 64)
+		if e1 != nil || e2 != nil {
+			return nil, h(fmt.Errorf("could not parse span of line %v", line))
+		}
+
+		var name string
+		var ok bool
+		if attr != "" {
+			name, ok = GtfAttr(line[8], attr)
+		} else if name, ok = GtfAttr(line[8], "gene_name"); !ok {
+			name, ok = GtfAttr(line[8], "gene_id")
+		}
+		if !ok {
+			name = line[2]
+		}
+		out = append(out, BedEntry{Chr: line[0], Start: start - 1, End: end, Fields: []string{name}})
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+	return out, nil
+}
+
+// Read features from a bed file, keeping only the name column in Fields
+func ReadBedFeatures(r io.Reader, namecol int) ([]BedEntry, error) {
+	h := Handle("ReadBedFeatures: %w")
+	var out []BedEntry
+
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		if len(s.Text()) == 0 || s.Text()[0] == '#' || strings.HasPrefix(s.Text(), "track") {
+			continue
+		}
+		line := strings.Split(s.Text(), "\t")
+		if len(line) <= namecol || len(line) < 3 {
+			return nil, h(fmt.Errorf("line %v has no name column %v", line, namecol))
+		}
+		start, e1 := strconv.ParseInt(line[1], 0, 64)
+		end, e2 := strconv.ParseInt(line[2], 0, 64)
+		if e1 != nil || e2 != nil {
+			return nil, h(fmt.Errorf("could not parse span of line %v", line))
+		}
+		out = append(out, BedEntry{Chr: line[0], Start: start, End: end, Fields: []string{line[namecol]}})
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+	return out, nil
+}
+
+// Read the features named in args, from either a bed or a GTF file
+func ReadAnnotateFeatures(args AnnotateArgs) ([]BedEntry, error) {
+	h := Handle("ReadAnnotateFeatures: %w")
+
+	format := args.Format
+	if format == "" {
+		format = "bed"
+		p := strings.TrimSuffix(args.Path, ".gz")
+		if strings.HasSuffix(p, ".gtf") || strings.HasSuffix(p, ".gff") {
+			format = "gtf"
+		}
+	}
+
+	r, e := OpenMaybeGz(args.Path)
+	if e != nil { return nil, h(e) }
+	defer r.Close()
+
+	var out []BedEntry
+	switch format {
+	case "bed":
+		out, e = ReadBedFeatures(r, args.NameCol)
+	case "gtf":
+		out, e = ReadGtfFeatures(r, args.Feature, args.Attr)
+	default:
+		e = fmt.Errorf("unknown format %q", format)
+	}
+	if e != nil { return nil, h(e) }
+	return out, nil
+}
+
+// Append the names of the features overlapping each record of r, without
+// repeats. Feature chromosomes are matched to each record's full name, or
+// else its chromosome part. A line without a numeric span is an error.
+func AnnotateOverlapOne(r io.Reader, features *SpanIndex, naming ChrNaming, args AnnotateArgs) io.Reader {
+	h := Handle("AnnotateOverlapOne: %w")
+
+	return PipeWriteErr(func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		total, hits := 0, 0
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			total++
+			start, e1 := strconv.ParseInt(line[1], 0, 64)
+			end, e2 := strconv.ParseInt(line[2], 0, 64)
+			if e1 != nil || e2 != nil {
+				return h(fmt.Errorf("could not parse span of line %v", line))
+			}
+			if end <= start {
+				end = start + 1
+			}
+
+			chr := features.ChrFor(line[0], naming)
+			var idxs []int
+			dist := "NA"
+			if args.Nearest {
+				near, d, ok := features.Nearest(chr, start, end)
+				if ok {
+					idxs = near
+					dist = strconv.FormatInt(d, 10)
+				}
+			} else {
+				idxs = features.Overlaps(chr, start, end)
+			}
+
+			var names []string
+			seen := map[string]struct{}{}
+			for _, i := range idxs {
+				name := features.Entries[i].Fields[0]
+				if _, ok := seen[name]; !ok {
+					seen[name] = struct{}{}
+					names = append(names, name)
+				}
+			}
+			if len(names) > 0 {
+				hits++
+				line = append(line, strings.Join(names, args.Sep))
+			} else {
+				line = append(line, args.Empty)
+			}
+			if args.Nearest {
+				line = append(line, dist)
+			}
+			fmt.Fprintln(bw, strings.Join(line, "\t"))
+		}
+		if s.Err() != nil {
+			return h(s.Err())
+		}
+		fmt.Fprintf(os.Stderr, "AnnotateOverlap: annotated %v of %v lines\n", hits, total)
+		return bw.Flush()
+	})
+}
+
+// Append the names of overlapping (or nearest) features from a bed or GTF
+// file to every record. Anyargs must be of type AnnotateArgs.
+func AnnotateOverlap(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("AnnotateOverlap: %w")
+
+	var args AnnotateArgs
+	err := UnmarshalJsonOut(anyargs, &args)
+	if err != nil { return nil, h(err) }
+	if args.Path == "" {
+		return nil, h(fmt.Errorf("missing Path"))
+	}
+	if args.NameCol == 0 {
+		args.NameCol = 3
+	}
+	if args.Sep == "" {
+		args.Sep = ","
+	}
+	if args.Empty == "" {
+		args.Empty = "."
+	}
+
+	entries, err := ReadAnnotateFeatures(args)
+	if err != nil { return nil, h(err) }
+	features := NewSpanIndex(entries)
+
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		out[i] = AnnotateOverlapOne(r, features, ctx.Cfg.Naming, args)
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package covplots
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runAnnotate(in string, args AnnotateArgs) string {
+	rs, err := AnnotateOverlap([]io.Reader{strings.NewReader(in)}, args, MultiplotFuncCtx{})
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+func TestAnnotateOverlap(t *testing.T) {
+	dir := t.TempDir()
+	bed := filepath.Join(dir, "peaks.bed")
+	if e := os.WriteFile(bed, []byte("chr1\t10\t20\tp1\t5\nchr1\t15\t30\tp2\t7\nchr1\t100\t110\tp3\t1\n"), 0644); e != nil { panic(e) }
+	gtf := filepath.Join(dir, "genes.gtf")
+	gtftext := "#comment\n" +
+		"chr1\tsrc\tgene\t11\t20\t.\t+\t.\tgene_id \"g1\"; gene_name \"Adh\";\n" +
+		"chr1\tsrc\texon\t11\t15\t.\t+\t.\tgene_id \"g1\"; gene_name \"Adh\";\n" +
+		"chr1\tsrc\tgene\t101\t110\t.\t+\t.\tgene_id \"g2\";\n"
+	if e := os.WriteFile(gtf, []byte(gtftext), 0644); e != nil { panic(e) }
+
+	in := "chr1_a\t0\t12\t1\nchr1_a\t40\t60\t2\nchr2_a\t0\t10\t3\n"
+	tests := []struct {
+		args AnnotateArgs
+		expect string
+	}{
+		{AnnotateArgs{Path: bed}, "chr1_a\t0\t12\t1\tp1\nchr1_a\t40\t60\t2\t.\nchr2_a\t0\t10\t3\t.\n"},
+		{AnnotateArgs{Path: bed, Nearest: true}, "chr1_a\t0\t12\t1\tp1\t0\nchr1_a\t40\t60\t2\tp2\t10\nchr2_a\t0\t10\t3\t.\tNA\n"},
+		{AnnotateArgs{Path: gtf, Feature: "gene", Nearest: true}, "chr1_a\t0\t12\t1\tAdh\t0\nchr1_a\t40\t60\t2\tAdh\t20\nchr2_a\t0\t10\t3\t.\tNA\n"},
+		{AnnotateArgs{Path: gtf}, "chr1_a\t0\t12\t1\tAdh\nchr1_a\t40\t60\t2\t.\nchr2_a\t0\t10\t3\t.\n"},
+	}
+	for _, test := range tests {
+		out := runAnnotate(in, test.args)
+		if out != test.expect {
+			t.Errorf("args %v: out %q != expect %q", test.args, out, test.expect)
+		}
+	}
+}
+
+func TestAnnotateOverlapHeader(t *testing.T) {
+	dir := t.TempDir()
+	bed := filepath.Join(dir, "peaks.bed")
+	if e := os.WriteFile(bed, []byte("chr1\t10\t20\tp1\n"), 0644); e != nil { panic(e) }
+
+	rs, err := AnnotateOverlap([]io.Reader{strings.NewReader("chrom\tstart\tend\tval\nchr1\t0\t12\t1\n")}, AnnotateArgs{Path: bed}, MultiplotFuncCtx{})
+	if err != nil {
+		panic(err)
+	}
+	if _, err := io.ReadAll(rs[0]); err == nil {
+		t.Errorf("no error for a header line")
+	}
+}
+
+func TestSpanIndexNearest(t *testing.T) {
+	idx := NewSpanIndex([]BedEntry{
+		BedEntry{"chr1", 0, 100, nil},
+		BedEntry{"chr1", 10, 20, nil},
+		BedEntry{"chr1", 130, 140, nil},
+	})
+	near, dist, ok := idx.Nearest("chr1", 115, 120)
+	if !ok || dist != 10 || len(near) != 1 || near[0] != 2 {
+		t.Errorf("out %v %v %v != expect [2] 10 true", near, dist, ok)
+	}
+	near, dist, ok = idx.Nearest("chr1", 105, 110)
+	if !ok || dist != 5 || len(near) != 1 || near[0] != 0 {
+		t.Errorf("out %v %v %v != expect [0] 5 true", near, dist, ok)
+	}
+}
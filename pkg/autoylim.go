@@ -0,0 +1,286 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Y limits computed from the data
+type AutoYlimCfg struct {
+	// "window" (default) for limits fit to each window, or "shared" for the
+	// same limits in all windows of a config. Shared limits hold every plotted
+	// value of the config in memory at once, 8 bytes per value and again per
+	// facet.
+	Mode string `json:"mode"`
+	// Quantiles of the values at the limits, low then high, each from 0 to 1;
+	// default [0.01, 0.99], so that a few outliers do not squash the rest of
+	// the plot
+	Quantiles []float64 `json:"quantiles"`
+	// Make the limits symmetric around zero
+	Symmetric bool `json:"symmetric"`
+	// The column that facets the plot, for a scales file with limits per
+	// facet: "FACET" or "NAME". The default is FACET for a config with facets,
+	// and otherwise depends on the plot function.
+	Facet string `json:"facet"`
+	// Or the 0-based index of another plfmt column, such as 5 for GENO in
+	// plot_tissues data
+	FacetColumn int `json:"facetcolumn"`
+}
+
+func (a *AutoYlimCfg) setDefaults() {
+	if a.Mode == "" { a.Mode = "window" }
+	if len(a.Quantiles) == 0 { a.Quantiles = []float64{0.01, 0.99} }
+}
+
+func (a AutoYlimCfg) Validate() error {
+	h := Handle("AutoYlimCfg.Validate: %w")
+	if a.Mode != "" && a.Mode != "window" && a.Mode != "shared" {
+		return h(fmt.Errorf("unknown mode %q", a.Mode))
+	}
+	if len(a.Quantiles) > 0 {
+		if len(a.Quantiles) != 2 {
+			return h(fmt.Errorf("quantiles %v are not a low and a high quantile", a.Quantiles))
+		}
+		lo, hi := a.Quantiles[0], a.Quantiles[1]
+		if !(lo >= 0 && hi <= 1 && lo <= hi) {
+			return h(fmt.Errorf("quantiles %v are not in order from 0 to 1", a.Quantiles))
+		}
+	}
+	if a.Facet != "" && a.Facet != "FACET" && a.Facet != "NAME" {
+		return h(fmt.Errorf("unknown facet column %q", a.Facet))
+	}
+	if a.FacetColumn < 0 {
+		return h(fmt.Errorf("negative facet column %v", a.FacetColumn))
+	}
+	return nil
+}
+
+// Y limits for a whole plot, and for each facet in order of appearance
+type AutoYlims struct {
+	Ylim []float64
+	FacetNames []string
+	Facets map[string][]float64
+}
+
+// Plot functions that read a scales file of per-facet limits, given as their
+// args or as the Scales of their args
+var scalesPlotfuncs = map[string]struct{}{
+	"plot_tissues": {}, "plot_tissue": {}, "plot_hybrids": {}, "plot_hybrid": {},
+	"plot_rescue": {}, "plot_vsill": {}, "plot_sawamura": {}, "plot_sawamura_sdist": {},
+	"plot_sawamura_melcolor": {}, "plot_multi_facet_scales": {},
+	"plot_multi_facet_scales_boxed": {}, "plot_multi_facetname_scales": {},
+}
+
+// The plfmt layout of each plot function that does not read PlfmtPlain.
+// plot_multi_facetname_scales reads its fifth column as a color label and
+// draws a panel for each NAME, so it is plain.
+var plotfuncLayouts = map[string]PlfmtLayout{
+	"plot_multi_facet": PlfmtFacet, "plot_multi_facet_scales": PlfmtFacet,
+	"plot_multi_facet_scales_boxed": PlfmtFacet, "plot_vsill": PlfmtFacet,
+	"native_multi_facet": PlfmtFacet, "html_multi_facet": PlfmtFacet,
+	"plot_multi_ribbon": PlfmtRibbon, "native_multi_ribbon": PlfmtRibbon,
+	"html_multi_ribbon": PlfmtRibbon,
+}
+
+// The plot functions that write interactive HTML
+var htmlPlotfuncs = map[string]struct{}{
+	"html": {}, "html_multi": {}, "html_multi_facet": {}, "html_multi_ribbon": {},
+}
+
+// The function that finds a plfmt line's facet for a config, or nil if its
+// plots have no facets
+func autoYlimFacetFunc(cfg UltimateConfig) func(line []string) string {
+	a := *cfg.AutoYlim
+	col := func(i int) func([]string) string {
+		return func(line []string) string {
+			if i < 0 || i >= len(line) {
+				return ""
+			}
+			return line[i]
+		}
+	}
+	fromEnd := func(i int) func([]string) string {
+		return func(line []string) string { return col(len(line) - i)(line) }
+	}
+	spec := cfg.PlotSpec
+	switch {
+	case a.FacetColumn > 0:
+		return col(a.FacetColumn)
+	case a.Facet == "FACET":
+		return col(4)
+	case a.Facet == "NAME":
+		return fromEnd(4)
+	case a.Facet != "":
+		return nil
+	case cfg.Plotfunc == "plotspec" && spec != nil && spec.Facet != "":
+		return func(line []string) string {
+			p, err := ParsePlotPoint(line, PlfmtPlain)
+			if err != nil {
+				return ""
+			}
+			return spec.colString(p, spec.Facet)
+		}
+	case len(cfg.Facets) > 0:
+		return col(4)
+	case cfg.Plotfunc == "plot_multi_facetname_scales":
+		return fromEnd(4)
+	case PlotfuncLayout(cfg.Plotfunc) == PlfmtFacet:
+		return col(4)
+	}
+	return nil
+}
+
+// The values of plfmt data, all together and by facet
+type autoYlimValues struct {
+	all []float64
+	facetNames []string
+	facets map[string][]float64
+}
+
+func (v *autoYlimValues) add(facet string, hasFacet bool, val float64) {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return
+	}
+	v.all = append(v.all, val)
+	if !hasFacet {
+		return
+	}
+	if v.facets == nil {
+		v.facets = map[string][]float64{}
+	}
+	if _, ok := v.facets[facet]; !ok {
+		v.facetNames = append(v.facetNames, facet)
+	}
+	v.facets[facet] = append(v.facets[facet], val)
+}
+
+// Add the values of one window's plfmt data, including ribbon bounds
+func (v *autoYlimValues) read(r io.Reader, cfg UltimateConfig) error {
+	facetOf := autoYlimFacetFunc(cfg)
+	ribbon := PlotfuncLayout(cfg.Plotfunc) == PlfmtRibbon
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		line := strings.Split(s.Text(), "\t")
+		if len(line) < 8 {
+			continue
+		}
+		facet := ""
+		if facetOf != nil {
+			facet = facetOf(line)
+		}
+		v.add(facet, facetOf != nil, AlwaysParseFloat(line[3]))
+		if ribbon && len(line) >= 10 {
+			v.add(facet, facetOf != nil, AlwaysParseFloat(line[4]))
+			v.add(facet, facetOf != nil, AlwaysParseFloat(line[5]))
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("autoYlimValues.read: %w", err)
+	}
+	return nil
+}
+
+func (v *autoYlimValues) readPath(path string, cfg UltimateConfig) error {
+	r, err := OpenMaybeGz(path)
+	if err != nil {
+		return fmt.Errorf("autoYlimValues.readPath: %w", err)
+	}
+	defer r.Close()
+	return v.read(r, cfg)
+}
+
+// The q quantile of sorted values, interpolated as in R's default method
+func quantileSorted(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted) - 1)
+	i := int(math.Floor(pos))
+	if i >= len(sorted) - 1 {
+		return sorted[len(sorted) - 1]
+	}
+	if i < 0 {
+		return sorted[0]
+	}
+	frac := pos - float64(i)
+	return sorted[i] + frac * (sorted[i+1] - sorted[i])
+}
+
+// Limits covering the a.Quantiles of vals, padded by 5% as in ggplot2, or
+// nil without values
+func AutoYlim(vals []float64, a AutoYlimCfg) []float64 {
+	a.setDefaults()
+	if len(vals) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), vals...)
+	sort.Float64s(sorted)
+	lo, hi := quantileSorted(sorted, a.Quantiles[0]), quantileSorted(sorted, a.Quantiles[len(a.Quantiles)-1])
+	if a.Symmetric {
+		hi = math.Max(math.Abs(lo), math.Abs(hi))
+		lo = -hi
+	}
+	if lo == hi {
+		return []float64{lo - 1, hi + 1}
+	}
+	pad := (hi - lo) * 0.05
+	return []float64{lo - pad, hi + pad}
+}
+
+func (v *autoYlimValues) limits(a AutoYlimCfg) AutoYlims {
+	out := AutoYlims{Ylim: AutoYlim(v.all, a), FacetNames: v.facetNames}
+	if v.facets != nil {
+		out.Facets = map[string][]float64{}
+		for _, f := range v.facetNames {
+			out.Facets[f] = AutoYlim(v.facets[f], a)
+		}
+	}
+	return out
+}
+
+// Write a scales file with a header and FACET, MIN, and MAX columns, as
+// read by the facet scales plot functions
+func WriteScalesFile(path string, lims AutoYlims) error {
+	h := Handle("WriteScalesFile: %w")
+	f, err := os.Create(path)
+	if err != nil { return h(err) }
+	bw := bufio.NewWriter(f)
+	fmt.Fprintf(bw, "FACET\tMIN\tMAX\n")
+	for _, facet := range lims.FacetNames {
+		lim := lims.Facets[facet]
+		fmt.Fprintf(bw, "%s\t%g\t%g\n", facet, lim[0], lim[1])
+	}
+	err = bw.Flush()
+	if e := f.Close(); err == nil { err = e }
+	if err != nil { return h(err) }
+	return nil
+}
+
+// Plot function args that use the scales file at path: args that are a path
+// are replaced, and args with a Scales entry get a new one
+func autoScalesArgs(args any, path string) any {
+	m, ok := args.(map[string]any)
+	if !ok {
+		return path
+	}
+	out := map[string]any{}
+	for k, v := range m {
+		out[k] = v
+	}
+	out["Scales"] = path
+	return out
+}
+
+// The y limits of the plfmt data in some files, gzipped or not, all at once
+func PlfmtAutoYlims(cfg UltimateConfig, paths ...string) (AutoYlims, error) {
+	var v autoYlimValues
+	for _, path := range paths {
+		if err := v.readPath(path, cfg); err != nil {
+			return AutoYlims{}, fmt.Errorf("PlfmtAutoYlims: %w", err)
+		}
+	}
+	return v.limits(*cfg.AutoYlim), nil
+}
@@ -0,0 +1,123 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAutoYlim(t *testing.T) {
+	var vals []float64
+	for i := 0; i <= 100; i++ {
+		vals = append(vals, float64(i) - 20)
+	}
+	for _, c := range []struct {
+		cfg AutoYlimCfg
+		expect []float64
+	}{
+		{AutoYlimCfg{Quantiles: []float64{0, 1}}, []float64{-25, 85}},
+		{AutoYlimCfg{Quantiles: []float64{0.1, 0.9}}, []float64{-14, 74}},
+		{AutoYlimCfg{Quantiles: []float64{0, 1}, Symmetric: true}, []float64{-88, 88}},
+	} {
+		out := AutoYlim(vals, c.cfg)
+		if !reflect.DeepEqual(out, c.expect) {
+			t.Errorf("out %v != expect %v", out, c.expect)
+		}
+	}
+	if out := AutoYlim([]float64{3, 3}, AutoYlimCfg{}); !reflect.DeepEqual(out, []float64{2, 4}) {
+		t.Errorf("out %v != expect %v", out, []float64{2, 4})
+	}
+	if out := AutoYlim(nil, AutoYlimCfg{}); out != nil {
+		t.Errorf("out %v != expect %v", out, nil)
+	}
+}
+
+func TestAutoYlimValidate(t *testing.T) {
+	for _, a := range []AutoYlimCfg{{}, {Mode: "shared", Quantiles: []float64{0, 1}, Facet: "NAME"}, {Quantiles: []float64{0.5, 0.5}}} {
+		if e := a.Validate(); e != nil {
+			t.Errorf("error for valid %v: %v", a, e)
+		}
+	}
+	for _, a := range []AutoYlimCfg{
+		{Mode: "shard"},
+		{Quantiles: []float64{0.9}},
+		{Quantiles: []float64{0.01, 0.5, 0.99}},
+		{Quantiles: []float64{-0.1, 0.9}},
+		{Quantiles: []float64{0.1, 1.5}},
+		{Quantiles: []float64{0.9, 0.1}},
+		{Facet: "GENO"},
+		{FacetColumn: -1},
+	} {
+		if e := a.Validate(); e == nil {
+			t.Errorf("no error for invalid %v", a)
+		}
+	}
+
+	var cfg UltimateConfig
+	cfg.AutoYlim = &AutoYlimCfg{Mode: "shard"}
+	if e := ValidateConfig(cfg); e == nil {
+		t.Errorf("no error for config with mode %q", cfg.AutoYlim.Mode)
+	}
+}
+
+func TestPlfmtAutoYlims(t *testing.T) {
+	dir := t.TempDir()
+	path1 := filepath.Join(dir, "win1_plfmt.bed")
+	path2 := filepath.Join(dir, "win2_plfmt.bed")
+	if e := os.WriteFile(path1, []byte("2L\t0\t10\t1\thead\tixw\t2L\t0\t0\t10\n" +
+		"2L\t0\t10\t3\tgonad\tixw\t2L\t0\t0\t10\n"), 0644); e != nil { panic(e) }
+	if e := os.WriteFile(path2, []byte("2L\t10\t20\t-1\thead\tixw\t2L\t0\t10\t20\n" +
+		"2L\t10\t20\tNaN\tgonad\tixw\t2L\t0\t10\t20\n"), 0644); e != nil { panic(e) }
+
+	var cfg UltimateConfig
+	cfg.Plotfunc = "plot_multi_facet_scales"
+	cfg.AutoYlim = &AutoYlimCfg{Quantiles: []float64{0, 1}}
+	out, err := PlfmtAutoYlims(cfg, path1, path2)
+	if err != nil {
+		panic(err)
+	}
+	expect := AutoYlims{
+		Ylim: []float64{-1.2, 3.2},
+		FacetNames: []string{"head", "gonad"},
+		Facets: map[string][]float64{"head": {-1.1, 1.1}, "gonad": {2, 4}},
+	}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	scales := filepath.Join(dir, "scales.txt")
+	if e := WriteScalesFile(scales, out); e != nil { panic(e) }
+	read, err := ReadScalesFile(scales)
+	if err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(read, expect.Facets) {
+		t.Errorf("out %v != expect %v", read, expect.Facets)
+	}
+
+	// Plots without facets have no per-facet limits
+	cfg.Plotfunc = "plot_singlebp_multiline_cov"
+	out, err = PlfmtAutoYlims(cfg, path1)
+	if err != nil {
+		panic(err)
+	}
+	if out.Facets != nil {
+		t.Errorf("out %v != expect %v", out.Facets, nil)
+	}
+}
+
+func TestAutoScalesArgs(t *testing.T) {
+	if out := autoScalesArgs(nil, "s.txt"); out != "s.txt" {
+		t.Errorf("out %v != expect %v", out, "s.txt")
+	}
+	in := map[string]any{"Scales": "old.txt", "Boxes": "b.bed"}
+	out := autoScalesArgs(in, "s.txt")
+	expect := map[string]any{"Scales": "s.txt", "Boxes": "b.bed"}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+	if in["Scales"] != "old.txt" {
+		t.Errorf("autoScalesArgs changed its input")
+	}
+}
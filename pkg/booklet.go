@@ -0,0 +1,224 @@
+package covplots
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The plfmt layout that a plot function reads
+func PlotfuncLayout(plotfunc string) PlfmtLayout {
+	if layout, ok := plotfuncLayouts[plotfunc]; ok {
+		return layout
+	}
+	return PlfmtPlain
+}
+
+// A Canvas that draws onto a w by h area of another Canvas at dx, dy
+type offsetCanvas struct {
+	c Canvas
+	dx, dy, w, h float64
+}
+
+func (o offsetCanvas) Size() (float64, float64) { return o.w, o.h }
+
+func (o offsetCanvas) shift(xs, ys []float64) ([]float64, []float64) {
+	sx, sy := make([]float64, len(xs)), make([]float64, len(ys))
+	for i := range xs {
+		sx[i], sy[i] = xs[i] + o.dx, ys[i] + o.dy
+	}
+	return sx, sy
+}
+
+func (o offsetCanvas) Rect(x, y, w, h float64, st Style) { o.c.Rect(x + o.dx, y + o.dy, w, h, st) }
+
+func (o offsetCanvas) Polyline(xs, ys []float64, st Style) {
+	xs, ys = o.shift(xs, ys)
+	o.c.Polyline(xs, ys, st)
+}
+
+func (o offsetCanvas) Polygon(xs, ys []float64, st Style) {
+	xs, ys = o.shift(xs, ys)
+	o.c.Polygon(xs, ys, st)
+}
+
+func (o offsetCanvas) Circle(x, y, r float64, st Style) { o.c.Circle(x + o.dx, y + o.dy, r, st) }
+
+func (o offsetCanvas) Text(x, y float64, s string, st TextStyle) { o.c.Text(x + o.dx, y + o.dy, s, st) }
+
+// A base pair position with thousands separators
+func FormatBp(bp int) string {
+	s := strconv.Itoa(bp)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s) - i) % 3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
+
+// A window's coordinates for a page header
+func (w GalleryWin) Label() string {
+	if w.Chr == "full_genome" {
+		return "Full genome"
+	}
+	return fmt.Sprintf("%v:%v-%v", w.Chr, FormatBp(w.Start), FormatBp(w.End))
+}
+
+// The plot options for drawing a config natively. Plot functions other than
+// the native ones get the defaults.
+func configNativeArgs(cfg UltimateConfig) (NativePlotArgs, error) {
+	var args NativePlotArgs
+	if strings.HasPrefix(cfg.Plotfunc, "native_") || htmlPlotfunc(cfg.Plotfunc) {
+		if err := UnmarshalJsonOut(cfg.PlotfuncArgs, &args); err != nil {
+			return args, fmt.Errorf("configNativeArgs: %w", err)
+		}
+	}
+	if len(args.Facets) == 0 {
+		args.Facets = cfg.Facets
+	}
+	args.Highlights = appendHighlights(args.Highlights, cfg.Highlights)
+	args.setDefaults()
+	return args, nil
+}
+
+// Read the plfmt data that Multiplot left for a window, gzipped or not. A
+// window without data has no points.
+func readWindowPlotPoints(base string, layout PlfmtLayout) ([]PlotPoint, error) {
+	if path := windowPlfmtPath(base); path != "" {
+		return ReadPlotPointsPath(path, layout)
+	}
+	return nil, nil
+}
+
+// The plfmt data of a window, gzipped or not, or "" if there is none
+func windowPlfmtPath(base string) string {
+	for _, path := range []string{base + "_plfmt.bed.gz", base + "_plfmt.bed"} {
+		if fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// The y limits of each window's booklet page: the config's, or those
+// computed from the data of each window or of all windows, with the limits of
+// the config's facets
+func bookletYlims(cfg UltimateConfig, wins []GalleryWin) ([]AutoYlims, error) {
+	out := make([]AutoYlims, len(wins))
+	var paths []string
+	for i, w := range wins {
+		out[i].Ylim = ConfigYlim(cfg)
+		if path := windowPlfmtPath(WindowOutpre(cfg.Outpre, w.Chr, w.Start, w.End)); path != "" {
+			paths = append(paths, path)
+			if cfg.AutoYlim != nil && cfg.AutoYlim.Mode != "shared" {
+				lims, err := PlfmtAutoYlims(cfg, path)
+				if err != nil {
+					return nil, fmt.Errorf("bookletYlims: %w", err)
+				}
+				out[i] = lims
+			}
+		}
+	}
+	if cfg.AutoYlim != nil && cfg.AutoYlim.Mode == "shared" {
+		lims, err := PlfmtAutoYlims(cfg, paths...)
+		if err != nil {
+			return nil, fmt.Errorf("bookletYlims: %w", err)
+		}
+		for i := range out {
+			out[i] = lims
+		}
+	}
+	for i := range out {
+		if out[i].Ylim == nil {
+			out[i].Ylim = ConfigYlim(cfg)
+		}
+		out[i].Facets = facetYlims(cfg.Facets, out[i].Facets)
+	}
+	return out, nil
+}
+
+// WriteBookletWithData without data already loaded for the config
+func WriteBooklet(cfg UltimateConfig, wins []GalleryWin) error {
+	return WriteBookletWithData(cfg, wins, nil)
+}
+
+// Draw every window of a config, in genomic order, on the pages of
+// outpre_booklet.pdf. Each page has a header with the window's coordinates.
+func WriteBookletWithData(cfg UltimateConfig, wins []GalleryWin, data *ConfigData) error {
+	h := Handle("WriteBooklet: %w")
+	wins = SortGalleryWins(wins)
+	layout := PlotfuncLayout(cfg.Plotfunc)
+	args, err := configNativeArgs(cfg)
+	if err != nil { return h(err) }
+	args.readBed = configHighlightReader(cfg, data)
+
+	out := cfg.PlotOutput
+	out.setDefaults()
+	width, height := args.Width, args.Height
+	var cfgSpec *PlotSpec
+	if cfg.Plotfunc == "plotspec" && cfg.PlotSpec != nil {
+		if cfgSpec, err = configPlotSpec(cfg, data); err != nil { return h(err) }
+		spec := *cfgSpec
+		spec.setDefaults()
+		width, height = spec.Width, spec.Height
+	}
+	if out.Width > 0 { width = out.Width * 96 }
+	if out.Height > 0 { height = out.Height * 96 }
+	fs := args.FontSize
+	header := fs * 2.4
+	ylims, err := bookletYlims(cfg, wins)
+	if err != nil { return h(err) }
+
+	f, err := os.Create(cfg.Outpre + "_booklet.pdf")
+	if err != nil { return h(err) }
+	defer f.Close()
+	doc := NewPDFStream(f)
+
+	title := filepath.Base(cfg.Outpre)
+	for i, w := range wins {
+		base := WindowOutpre(cfg.Outpre, w.Chr, w.Start, w.End)
+		pts, err := readWindowPlotPoints(base, layout)
+		if err != nil { return h(err) }
+		styles, err := ReadPlfmtSetStyles(base)
+		if err != nil { return h(err) }
+
+		page := doc.AddPage(width, height + header)
+		page.Rect(0, 0, width, header, Style{Fill: White})
+		page.Text(fs, header - fs * 0.8, w.Label(), TextStyle{Size: fs * 1.2, Color: Black})
+		page.Text(width - fs, header - fs * 0.8, fmt.Sprintf("%v, page %v of %v", title, i + 1, len(wins)),
+			TextStyle{Size: fs * 0.85, Color: Color{77, 77, 77, 255}, Anchor: "end"})
+		page.Polyline([]float64{0, width}, []float64{header, header}, Style{Stroke: Color{204, 204, 204, 255}, StrokeWidth: 1})
+
+		plot := offsetCanvas{c: page, dy: header, w: width, h: height}
+		if len(pts) == 0 {
+			plot.Rect(0, 0, width, height, Style{Fill: White})
+			plot.Text(width / 2, height / 2, "no data", TextStyle{Size: fs, Color: Color{128, 128, 128, 255}, Anchor: "middle"})
+			continue
+		}
+		lims := ylims[i]
+		if cfgSpec != nil {
+			spec, e := cfgSpec.withConfig(cfg, lims.Facets)
+			if e != nil { return h(e) }
+			spec.Styles = mergeSetStyles(spec.Styles, styles)
+			err = DrawPlotSpec(plot, pts, lims.Ylim, spec)
+		} else {
+			wargs := args
+			wargs.Styles = mergeSetStyles(args.Styles, styles)
+			wargs.FacetYlims = lims.Facets
+			err = DrawMultiplot(plot, pts, lims.Ylim, layout == PlfmtFacet, wargs)
+		}
+		if err != nil { return h(err) }
+	}
+	if err := doc.Close(); err != nil { return h(err) }
+	return nil
+}
@@ -0,0 +1,74 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatBp(t *testing.T) {
+	for in, expect := range map[int]string{0: "0", 999: "999", 1000: "1,000", 12345678: "12,345,678", -1500: "-1,500"} {
+		if out := FormatBp(in); out != expect {
+			t.Errorf("out %v != expect %v", out, expect)
+		}
+	}
+}
+
+func TestWriteBooklet(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t1000\t1010\t1\tixw\t0\t0\t10\n"
+	for _, base := range []string{"_2L_1000_2000", "_2L_0_1000"} {
+		if e := os.WriteFile(outpre + base + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+	}
+	cfg := UltimateConfig{Outpre: outpre, Plotfunc: "native_multi", Ylim: []float64{0, 2}}
+	cfg.PlotOutput.Booklet = true
+	wins := []GalleryWin{{"2L", 1000, 2000}, {"3R", 0, 1000}, {"2L", 0, 1000}}
+
+	if e := FinishMultiplotRun(cfg, wins); e != nil {
+		panic(e)
+	}
+	pdf, err := os.ReadFile(outpre + "_booklet.pdf")
+	if err != nil { panic(err) }
+	if e := checkPDFXref(pdf); e != nil {
+		t.Errorf("bad xref: %v", e)
+	}
+	if !strings.Contains(string(pdf), "/Count 3") {
+		t.Errorf("pdf does not have 3 pages")
+	}
+
+	text := pdfContents(pdf)
+	i0 := strings.Index(text, "(2L:0-1,000) Tj")
+	i1 := strings.Index(text, "(2L:1,000-2,000) Tj")
+	i2 := strings.Index(text, "(3R:0-1,000) Tj")
+	if i0 < 0 || i1 < i0 || i2 < i1 {
+		t.Errorf("page headers missing or out of order: %v %v %v", i0, i1, i2)
+	}
+	for _, want := range []string{"(out, page 1 of 3) Tj", "(no data) Tj", "(ixw) Tj"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("pdf does not contain %q", want)
+		}
+	}
+
+	index, err := os.ReadFile(outpre + "_index.html")
+	if err != nil { panic(err) }
+	if !strings.Contains(string(index), `href="./out_booklet.pdf"`) {
+		t.Errorf("index does not link the booklet")
+	}
+}
+
+func TestPlotfuncLayout(t *testing.T) {
+	for plotfunc, expect := range map[string]PlfmtLayout{
+		"plot_multi_facet": PlfmtFacet,
+		"plot_multi_facet_scales_boxed": PlfmtFacet,
+		"plot_multi_facetname_scales": PlfmtPlain,
+		"native_multi_ribbon": PlfmtRibbon,
+		"html_multi_facet": PlfmtFacet,
+		"html_ribbon": PlfmtPlain,
+		"plot_multi": PlfmtPlain,
+	} {
+		if out := PlotfuncLayout(plotfunc); out != expect {
+			t.Errorf("%v: out %v != expect %v", plotfunc, out, expect)
+		}
+	}
+}
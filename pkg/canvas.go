@@ -0,0 +1,241 @@
+package covplots
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An RGBA color. A color with A == 0 is not drawn.
+type Color struct {
+	R, G, B, A uint8
+}
+
+var (
+	Black = Color{0, 0, 0, 255}
+	White = Color{255, 255, 255, 255}
+	NoColor = Color{}
+)
+
+var namedColors = map[string]Color {
+	"black": Black,
+	"white": White,
+	"red": Color{228, 26, 28, 255},
+	"blue": Color{55, 126, 184, 255},
+	"green": Color{77, 175, 74, 255},
+	"purple": Color{152, 78, 163, 255},
+	"orange": Color{255, 127, 0, 255},
+	"yellow": Color{255, 221, 51, 255},
+	"brown": Color{166, 86, 40, 255},
+	"pink": Color{247, 129, 191, 255},
+	"gray": Color{128, 128, 128, 255},
+	"grey": Color{128, 128, 128, 255},
+	"darkgray": Color{64, 64, 64, 255},
+	"darkgrey": Color{64, 64, 64, 255},
+	"lightgray": Color{211, 211, 211, 255},
+	"lightgrey": Color{211, 211, 211, 255},
+	"none": NoColor,
+	"transparent": NoColor,
+}
+
+// Parse a color name, or hex color as "#rgb", "#rrggbb", or "#rrggbbaa"
+func ParseColor(s string) (Color, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if !strings.HasPrefix(s, "#") || len(hex) != 8 {
+		return NoColor, fmt.Errorf("ParseColor: unknown color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return NoColor, fmt.Errorf("ParseColor: %w", err)
+	}
+	return Color{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// The same color with its alpha multiplied by a (0 to 1)
+func (c Color) Fade(a float64) Color {
+	c.A = uint8(math.Round(float64(c.A) * a))
+	return c
+}
+
+// A color from the HCL (polar CIE-Luv) color space, as used by ggplot2's default palette
+func HclColor(h, c, l float64) Color {
+	const xn, yn, zn = 95.047, 100.000, 108.883
+	hr := h * math.Pi / 180
+	u, v := c * math.Cos(hr), c * math.Sin(hr)
+
+	y := yn * l / 903.3
+	if l > 8 {
+		y = yn * math.Pow((l + 16) / 116, 3)
+	}
+	un := 4 * xn / (xn + 15 * yn + 3 * zn)
+	vn := 9 * yn / (xn + 15 * yn + 3 * zn)
+	up := u / (13 * l) + un
+	vp := v / (13 * l) + vn
+	x := 9 * y * up / (4 * vp)
+	z := y * (12 - 3 * up - 20 * vp) / (4 * vp)
+	x, y, z = x / 100, y / 100, z / 100
+
+	gamma := func(lin float64) uint8 {
+		var s float64
+		if lin <= 0.0031308 {
+			s = 12.92 * lin
+		} else {
+			s = 1.055 * math.Pow(lin, 1 / 2.4) - 0.055
+		}
+		return uint8(math.Round(255 * math.Max(0, math.Min(1, s))))
+	}
+	return Color{
+		gamma(3.240479 * x - 1.537150 * y - 0.498535 * z),
+		gamma(-0.969256 * x + 1.875992 * y + 0.041556 * z),
+		gamma(0.055648 * x - 0.204043 * y + 1.057311 * z),
+		255,
+	}
+}
+
+// Evenly spaced hues, like ggplot2's default discrete color scale
+func HuePalette(n int) []Color {
+	out := make([]Color, n)
+	for i := range out {
+		out[i] = HclColor(15 + 360 * float64(i) / float64(n), 100, 65)
+	}
+	return out
+}
+
+// How shapes are filled and outlined
+type Style struct {
+	Fill Color
+	Stroke Color
+	StrokeWidth float64
+	// Lengths of alternating dashes and gaps in pixels; nil for solid lines
+	Dash []float64
+}
+
+type TextStyle struct {
+	Size float64
+	Color Color
+	// "start", "middle", or "end"
+	Anchor string
+	// Degrees clockwise
+	Rotate float64
+}
+
+// Something that plots can be drawn on. Coordinates are in pixels from the
+// top left.
+type Canvas interface {
+	Size() (w, h float64)
+	Rect(x, y, w, h float64, st Style)
+	Polyline(xs, ys []float64, st Style)
+	Polygon(xs, ys []float64, st Style)
+	Circle(x, y, r float64, st Style)
+	Text(x, y float64, s string, st TextStyle)
+}
+
+// Width of text in Helvetica, which all output formats use for layout
+func TextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w * size
+}
+
+// A Canvas that builds an SVG document
+type SVGCanvas struct {
+	W, H float64
+	b strings.Builder
+}
+
+func NewSVGCanvas(w, h float64) *SVGCanvas {
+	return &SVGCanvas{W: w, H: h}
+}
+
+func (c *SVGCanvas) Size() (float64, float64) { return c.W, c.H }
+
+func svgPaint(attr string, col Color) string {
+	if col.A == 0 {
+		return fmt.Sprintf(` %s="none"`, attr)
+	}
+	if col.A == 255 {
+		return fmt.Sprintf(` %s="%s"`, attr, col.Hex())
+	}
+	return fmt.Sprintf(` %s="%s" %s-opacity="%.3g"`, attr, col.Hex(), attr, float64(col.A) / 255)
+}
+
+func svgStyle(st Style) string {
+	s := svgPaint("fill", st.Fill) + svgPaint("stroke", st.Stroke)
+	if st.Stroke.A != 0 && st.StrokeWidth != 0 {
+		s += fmt.Sprintf(` stroke-width="%.3g"`, st.StrokeWidth)
+	}
+	if st.Stroke.A != 0 && len(st.Dash) > 0 {
+		var dash []string
+		for _, d := range st.Dash {
+			dash = append(dash, fmt.Sprintf("%.3g", d))
+		}
+		s += fmt.Sprintf(` stroke-dasharray="%s"`, strings.Join(dash, " "))
+	}
+	return s
+}
+
+func svgPoints(xs, ys []float64) string {
+	var b strings.Builder
+	for i := range xs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.2f,%.2f", xs[i], ys[i])
+	}
+	return b.String()
+}
+
+func (c *SVGCanvas) Rect(x, y, w, h float64, st Style) {
+	fmt.Fprintf(&c.b, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\"%s/>\n", x, y, w, h, svgStyle(st))
+}
+
+func (c *SVGCanvas) Polyline(xs, ys []float64, st Style) {
+	st.Fill = NoColor
+	fmt.Fprintf(&c.b, "<polyline points=\"%s\"%s stroke-linejoin=\"round\"/>\n", svgPoints(xs, ys), svgStyle(st))
+}
+
+func (c *SVGCanvas) Polygon(xs, ys []float64, st Style) {
+	fmt.Fprintf(&c.b, "<polygon points=\"%s\"%s/>\n", svgPoints(xs, ys), svgStyle(st))
+}
+
+func (c *SVGCanvas) Circle(x, y, r float64, st Style) {
+	fmt.Fprintf(&c.b, "<circle cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\"%s/>\n", x, y, r, svgStyle(st))
+}
+
+func (c *SVGCanvas) Text(x, y float64, s string, st TextStyle) {
+	anchor := st.Anchor
+	if anchor == "" {
+		anchor = "start"
+	}
+	transform := ""
+	if st.Rotate != 0 {
+		transform = fmt.Sprintf(` transform="rotate(%.3g %.2f %.2f)"`, st.Rotate, x, y)
+	}
+	fmt.Fprintf(&c.b, "<text x=\"%.2f\" y=\"%.2f\" font-size=\"%.3g\" text-anchor=\"%s\"%s%s>%s</text>\n",
+		x, y, st.Size, anchor, svgPaint("fill", st.Color), transform, html.EscapeString(s))
+}
+
+// Write the finished SVG document
+func (c *SVGCanvas) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"Helvetica, Arial, sans-serif\">\n%s</svg>\n",
+		c.W, c.H, c.W, c.H, c.b.String())
+	return int64(n), err
+}
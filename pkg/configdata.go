@@ -0,0 +1,65 @@
+package covplots
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Data read once per config and shared by all of its windows, so that each
+// window does not re-read the same files
+type ConfigData struct {
+	Aliases ChrAliases
+	// Records with chromosomes not in Aliases, summed over all windows
+	AliasReport *AliasReport
+
+	mu sync.Mutex
+	cache map[string]any
+}
+
+// Read the data that all windows of cfg share
+func LoadConfigData(cfg UltimateConfig) (*ConfigData, error) {
+	aliases, err := GetConfigAliases(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("LoadConfigData: %w", err)
+	}
+	d := &ConfigData{Aliases: aliases}
+	if aliases != nil {
+		d.AliasReport = new(AliasReport)
+	}
+	return d, nil
+}
+
+// Write the alias report of all windows, if the config has aliases
+func (d *ConfigData) Report(w io.Writer) {
+	if d != nil && d.AliasReport != nil {
+		fmt.Fprintln(w, d.AliasReport)
+	}
+}
+
+// The value stored under key, loaded on first use. Failed loads are not
+// stored. A nil ConfigData stores nothing, and load runs on every call. Load
+// may itself use configCached.
+func configCached[T any](d *ConfigData, key string, load func() (T, error)) (T, error) {
+	if d == nil {
+		return load()
+	}
+	d.mu.Lock()
+	v, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok {
+		return v.(T), nil
+	}
+
+	loaded, err := load()
+	if err != nil {
+		return loaded, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cache == nil {
+		d.cache = map[string]any{}
+	}
+	d.cache[key] = loaded
+	return loaded, nil
+}
@@ -0,0 +1,45 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDataWindows(t *testing.T) {
+	dir := t.TempDir()
+	aliases := filepath.Join(dir, "aliases.txt")
+	if e := os.WriteFile(aliases, []byte(aliastable), 0644); e != nil { panic(e) }
+	in := filepath.Join(dir, "in.bed")
+	if e := os.WriteFile(in, []byte(aliasin), 0644); e != nil { panic(e) }
+
+	var cfg UltimateConfig
+	cfg.Outpre = filepath.Join(dir, "out")
+	cfg.ChrAliases = aliases
+	cfg.InputSets = []InputSet{{Paths: []string{in}, Name: "in", Functions: []string{"unchanged"}}}
+	data, err := LoadConfigData(cfg)
+	if err != nil {
+		panic(err)
+	}
+	// Both windows add to the one report of the config
+	for _, start := range []int{0, 100} {
+		if _, e := MultiplotPrepareWithData(cfg, data, "2L", start, start + 100); e != nil { panic(e) }
+	}
+	if data.AliasReport.Total != 8 || data.AliasReport.Unmapped["chr4_ISO1"] != 2 {
+		t.Errorf("unexpected report %v", data.AliasReport)
+	}
+
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return loads, nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, _ := configCached(data, "key", load); v != 1 {
+			t.Errorf("out %v != expect %v", v, 1)
+		}
+	}
+	if v, _ := configCached(nil, "key", load); v != 2 {
+		t.Errorf("out %v != expect %v", v, 2)
+	}
+}
@@ -29,6 +29,14 @@ func GetPlotFunc(fstr string) func(outpre string, ylim []float64, args any, marg
 	case "plot_multi_pretty_blue": return PlotMultiPrettyBlueAny
 	case "plot_multi_pretty_colorseries": return PlotMultiPrettyColorseriesAny
 	case "plot_multi_facet": return PlotMultiFacetAny
+	case "plot_multi_ribbon": return PlotMultiRibbonAny
+	case "native_multi": return PlotNativeAny(PlfmtPlain)
+	case "native_multi_facet": return PlotNativeAny(PlfmtFacet)
+	case "native_multi_ribbon": return PlotNativeAny(PlfmtRibbon)
+	case "plotspec": return PlotSpecAny
+	case "html", "html_multi": return PlotHTMLAny(PlfmtPlain)
+	case "html_multi_facet": return PlotHTMLAny(PlfmtFacet)
+	case "html_multi_ribbon": return PlotHTMLAny(PlfmtRibbon)
 	case "plot_multi_facet_scales": return PlotMultiFacetScalesAny
 	case "plot_multi_facet_scales_boxed": return PlotMultiFacetScalesBoxedAny
 	case "plot_multi_facetname_scales": return PlotMultiFacetnameScalesAny
@@ -58,6 +66,10 @@ func PlotMultiAny(outpre string, ylim []float64, args any, margs MultiplotPlotFu
 	return PlotMulti(outpre, ylim)
 }
 
+func PlotMultiRibbonAny(outpre string, ylim []float64, args any, margs MultiplotPlotFuncArgs) error {
+	return PlotMultiRibbon(outpre, ylim)
+}
+
 func PlotMultiFixedOrderAny(outpre string, ylim []float64, args any, margs MultiplotPlotFuncArgs) error {
 	return PlotMultiFixedOrder(outpre, ylim)
 }
@@ -129,10 +129,21 @@ func SubsetDumbSome(rs []io.Reader, args any) ([]io.Reader, error) {
 
 func RunDumbSubset() {
 	spanpathp := flag.String("s", "", "Subset spans")
+	modep := flag.String("m", "exact", "Mode: exact (keep exact span matches), keep (keep overlapping records), or mask (drop overlapping records)")
+	fracp := flag.Float64("f", 0, "Minimum fraction of a record that spans must cover to count as overlapping (keep and mask modes)")
+	trimp := flag.Bool("t", false, "Trim partially overlapping records instead of keeping or dropping them whole (keep and mask modes)")
 	flag.Parse()
 	if *spanpathp == "" { panic("missing -s") }
 
-	outarr, err := SubsetDumb([]io.Reader{os.Stdin}, *spanpathp)
+	var outarr []io.Reader
+	var err error
+	rargs := RegionArgs{Bed: *spanpathp, MinFrac: *fracp, Trim: *trimp}
+	switch *modep {
+	case "exact": outarr, err = SubsetDumb([]io.Reader{os.Stdin}, *spanpathp)
+	case "keep": outarr, err = RegionFilter([]io.Reader{os.Stdin}, rargs, ChrNaming{Parent: "none"}, true)
+	case "mask": outarr, err = RegionFilter([]io.Reader{os.Stdin}, rargs, ChrNaming{Parent: "none"}, false)
+	default: panic(fmt.Errorf("unknown mode -m %v", *modep))
+	}
 	if err != nil { panic(err) }
 	if len(outarr) != 1 {
 		panic(fmt.Errorf("len(outarr) %v != 1", len(outarr)))
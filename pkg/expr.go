@@ -0,0 +1,803 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A compiled arithmetic expression over a set of numbered variables
+type Expr struct {
+	root exprNode
+}
+
+type exprNode interface {
+	eval(vars []float64) float64
+}
+
+type numNode float64
+
+func (n numNode) eval(vars []float64) float64 { return float64(n) }
+
+type varNode int
+
+func (n varNode) eval(vars []float64) float64 { return vars[n] }
+
+type negNode struct { x exprNode }
+
+func (n negNode) eval(vars []float64) float64 { return -n.x.eval(vars) }
+
+type binNode struct {
+	op byte
+	x, y exprNode
+	divzero DivZeroMode
+}
+
+func (n binNode) eval(vars []float64) float64 {
+	x, y := n.x.eval(vars), n.y.eval(vars)
+	switch n.op {
+	case '+': return x + y
+	case '-': return x - y
+	case '*': return x * y
+	case '^': return math.Pow(x, y)
+	case '/', '%':
+		if y == 0 {
+			switch n.divzero {
+			case DivZeroZero: return 0
+			case DivZeroNaN: return math.NaN()
+			}
+		}
+		if n.op == '%' {
+			return math.Mod(x, y)
+		}
+		return x / y
+	}
+	panic(fmt.Errorf("binNode: unknown operator %c", n.op))
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// True if x is neither 0 nor NaN
+func isTrue(x float64) bool {
+	return x != 0 && !math.IsNaN(x)
+}
+
+// A comparison; comparisons with NaN are false, except that NaN != NaN
+type cmpNode struct {
+	op byte
+	x, y exprNode
+}
+
+func (n cmpNode) eval(vars []float64) float64 {
+	x, y := n.x.eval(vars), n.y.eval(vars)
+	switch n.op {
+	case '<': return truth(x < y)
+	case 'L': return truth(x <= y)
+	case '>': return truth(x > y)
+	case 'G': return truth(x >= y)
+	case '=': return truth(x == y)
+	case 'N': return truth(x != y)
+	}
+	panic(fmt.Errorf("cmpNode: unknown operator %c", n.op))
+}
+
+type logicNode struct {
+	op byte
+	x, y exprNode
+}
+
+func (n logicNode) eval(vars []float64) float64 {
+	if n.op == '&' {
+		return truth(isTrue(n.x.eval(vars)) && isTrue(n.y.eval(vars)))
+	}
+	return truth(isTrue(n.x.eval(vars)) || isTrue(n.y.eval(vars)))
+}
+
+type notNode struct { x exprNode }
+
+func (n notNode) eval(vars []float64) float64 { return truth(!isTrue(n.x.eval(vars))) }
+
+type callNode struct {
+	f func(args []float64) float64
+	args []exprNode
+	buf []float64
+}
+
+func (n *callNode) eval(vars []float64) float64 {
+	n.buf = n.buf[:0]
+	for _, arg := range n.args {
+		n.buf = append(n.buf, arg.eval(vars))
+	}
+	return n.f(n.buf)
+}
+
+// What to do when dividing by zero
+type DivZeroMode int
+
+const (
+	// Return NaN (default)
+	DivZeroNaN DivZeroMode = iota
+	// Return +/-Inf, or NaN for 0/0, as in IEEE arithmetic
+	DivZeroInf
+	// Return 0
+	DivZeroZero
+)
+
+func ParseDivZeroMode(s string) (DivZeroMode, error) {
+	switch s {
+	case "", "nan": return DivZeroNaN, nil
+	case "inf": return DivZeroInf, nil
+	case "zero": return DivZeroZero, nil
+	}
+	return DivZeroNaN, fmt.Errorf("ParseDivZeroMode: unknown mode %q", s)
+}
+
+type exprFunc struct {
+	nargs int // -1 for any number >= 1
+	f func(args []float64) float64
+}
+
+func oneArg(f func(float64) float64) exprFunc {
+	return exprFunc{1, func(a []float64) float64 { return f(a[0]) }}
+}
+
+var exprFuncs = map[string]exprFunc {
+	"log": oneArg(math.Log),
+	"log2": oneArg(math.Log2),
+	"log10": oneArg(math.Log10),
+	"exp": oneArg(math.Exp),
+	"sqrt": oneArg(math.Sqrt),
+	"abs": oneArg(math.Abs),
+	"floor": oneArg(math.Floor),
+	"ceil": oneArg(math.Ceil),
+	"round": oneArg(math.Round),
+	"isnan": oneArg(func(x float64) float64 {
+		if math.IsNaN(x) {
+			return 1
+		}
+		return 0
+	}),
+	"ifnan": exprFunc{2, func(a []float64) float64 {
+		if math.IsNaN(a[0]) {
+			return a[1]
+		}
+		return a[0]
+	}},
+	"clamp": exprFunc{3, func(a []float64) float64 {
+		if math.IsNaN(a[0]) {
+			return a[0]
+		}
+		return math.Max(a[1], math.Min(a[2], a[0]))
+	}},
+	"min": exprFunc{-1, func(a []float64) float64 {
+		out := a[0]
+		for _, x := range a[1:] {
+			out = math.Min(out, x)
+		}
+		return out
+	}},
+	"max": exprFunc{-1, func(a []float64) float64 {
+		out := a[0]
+		for _, x := range a[1:] {
+			out = math.Max(out, x)
+		}
+		return out
+	}},
+}
+
+var exprConsts = map[string]float64 {
+	"nan": math.NaN(),
+	"inf": math.Inf(1),
+	"pi": math.Pi,
+}
+
+type exprToken struct {
+	// 'n' number, 'i' identifier, 's' string, the operator character, or for
+	// two-character operators '=' (==), 'N' (!=), 'L' (<=), 'G' (>=), '&' (&&),
+	// and '|' (||); 0 at the end
+	kind byte
+	text string
+	pos int
+}
+
+var exprOps2 = map[string]byte {
+	"==": '=',
+	"!=": 'N',
+	"<=": 'L',
+	">=": 'G',
+	"&&": '&',
+	"||": '|',
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var out []exprToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				k := j + 1
+				if k < len(src) && (src[k] == '+' || src[k] == '-') {
+					k++
+				}
+				if k < len(src) && unicode.IsDigit(rune(src[k])) {
+					for j = k; j < len(src) && unicode.IsDigit(rune(src[j])); j++ {}
+				}
+			}
+			out = append(out, exprToken{'n', src[i:j], i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			out = append(out, exprToken{'i', src[i:j], i})
+			i = j
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(src[i+1:], src[i])
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at position %v", i)
+			}
+			out = append(out, exprToken{'s', src[i+1:i+1+j], i})
+			i += j + 2
+		case i + 1 < len(src) && exprOps2[src[i:i+2]] != 0:
+			out = append(out, exprToken{exprOps2[src[i:i+2]], src[i:i+2], i})
+			i += 2
+		case strings.ContainsRune("+-*/%^(),<>!", c):
+			out = append(out, exprToken{byte(c), string(c), i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %v", c, i)
+		}
+	}
+	out = append(out, exprToken{0, "end of expression", len(src)})
+	return out, nil
+}
+
+type exprParser struct {
+	toks []exprToken
+	pos int
+	vars func(name string) (int, bool)
+	strVars func(name, lit string) (int, bool)
+	divzero DivZeroMode
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) expect(kind byte) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("expected %q at position %v, got %q", kind, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == 'i' && t.text == word
+}
+
+// or := and (('||' | 'or') and)*
+func (p *exprParser) parseOr() (exprNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == '|' || p.isKeyword("or") {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = logicNode{op: '|', x: x, y: y}
+	}
+	return x, nil
+}
+
+// and := not (('&&' | 'and') not)*
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == '&' || p.isKeyword("and") {
+		p.next()
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = logicNode{op: '&', x: x, y: y}
+	}
+	return x, nil
+}
+
+// not := ('!' | 'not') not | cmp
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.peek().kind == '!' || p.isKeyword("not") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.parseCmp()
+}
+
+func isCmpOp(kind byte) bool {
+	return kind == '<' || kind == '>' || kind == '=' || kind == 'N' || kind == 'L' || kind == 'G'
+}
+
+// cmp := expr (cmpop expr)? | name ('==' | '!=') string | string ('==' | '!=') name
+func (p *exprParser) parseCmp() (exprNode, error) {
+	if x, ok, err := p.parseStrCmp(); ok || err != nil {
+		return x, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if isCmpOp(p.peek().kind) {
+		op := p.next().kind
+		y, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return cmpNode{op: op, x: x, y: y}, nil
+	}
+	return x, nil
+}
+
+// Parse a string comparison if the next three tokens are one
+func (p *exprParser) parseStrCmp() (exprNode, bool, error) {
+	if p.pos + 2 >= len(p.toks) {
+		return nil, false, nil
+	}
+	a, op, b := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+	if a.kind == 's' && b.kind == 'i' {
+		a, b = b, a
+	}
+	if a.kind != 'i' || b.kind != 's' || (op.kind != '=' && op.kind != 'N') {
+		return nil, false, nil
+	}
+	if p.strVars == nil {
+		return nil, true, fmt.Errorf("string comparisons are not available at position %v", a.pos)
+	}
+	idx, ok := p.strVars(a.text, b.text)
+	if !ok {
+		return nil, true, fmt.Errorf("unknown column %q at position %v", a.text, a.pos)
+	}
+	p.pos += 3
+	var x exprNode = varNode(idx)
+	if op.kind == 'N' {
+		x = notNode{x}
+	}
+	return x, true, nil
+}
+
+// expr := term (('+' | '-') term)*
+func (p *exprParser) parseExpr() (exprNode, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == '+' || p.peek().kind == '-' {
+		op := p.next().kind
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = binNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// term := unary (('*' | '/' | '%') unary)*
+func (p *exprParser) parseTerm() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == '*' || p.peek().kind == '/' || p.peek().kind == '%' {
+		op := p.next().kind
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = binNode{op: op, x: x, y: y, divzero: p.divzero}
+	}
+	return x, nil
+}
+
+// unary := '-' unary | power
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek().kind == '-' {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x}, nil
+	}
+	return p.parsePower()
+}
+
+// power := primary ('^' unary)?
+func (p *exprParser) parsePower() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == '^' {
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return binNode{op: '^', x: x, y: y}, nil
+	}
+	return x, nil
+}
+
+// primary := number | name | name '(' expr (',' expr)* ')' | '(' expr ')'
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case 'n':
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at position %v", t.text, t.pos)
+		}
+		return numNode(f), nil
+	case '(':
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(')')
+	case 's':
+		return nil, fmt.Errorf("string %q at position %v can only be compared to a column with == or !=", t.text, t.pos)
+	case 'i':
+		if p.peek().kind == '(' {
+			return p.parseCall(t)
+		}
+		if idx, ok := p.vars(t.text); ok {
+			return varNode(idx), nil
+		}
+		if c, ok := exprConsts[t.text]; ok {
+			return numNode(c), nil
+		}
+		return nil, fmt.Errorf("unknown name %q at position %v", t.text, t.pos)
+	}
+	return nil, fmt.Errorf("unexpected %q at position %v", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	f, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %v", name.text, name.pos)
+	}
+	p.next()
+
+	var args []exprNode
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().kind != ',' {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+
+	if (f.nargs < 0 && len(args) < 1) || (f.nargs >= 0 && len(args) != f.nargs) {
+		return nil, fmt.Errorf("wrong number of arguments (%v) to %v at position %v", len(args), name.text, name.pos)
+	}
+	return &callNode{f: f.f, args: args}, nil
+}
+
+// Compile an expression. Vars maps each variable name to its index in the
+// slice passed to Eval; names it does not know are an error. String
+// comparisons are an error unless CompileExprStrings is used.
+func CompileExpr(src string, vars func(name string) (int, bool), divzero DivZeroMode) (*Expr, error) {
+	return CompileExprStrings(src, vars, nil, divzero)
+}
+
+// Like CompileExpr, but strVars maps a column name compared to a string
+// literal with == to the index of a variable that is 1 where they are equal
+func CompileExprStrings(src string, vars func(name string) (int, bool), strVars func(name, lit string) (int, bool), divzero DivZeroMode) (*Expr, error) {
+	toks, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, fmt.Errorf("CompileExpr: %w", err)
+	}
+	p := &exprParser{toks: toks, vars: vars, strVars: strVars, divzero: divzero}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("CompileExpr: %w", err)
+	}
+	if t := p.peek(); t.kind != 0 {
+		return nil, fmt.Errorf("CompileExpr: unexpected %q at position %v", t.text, t.pos)
+	}
+	return &Expr{root: root}, nil
+}
+
+// Evaluate the expression with the given variable values. Not safe for concurrent use.
+func (e *Expr) Eval(vars []float64) float64 {
+	return e.root.eval(vars)
+}
+
+var colVarRe = regexp.MustCompile(`^c([0-9]+)$`)
+var trackVarRe = regexp.MustCompile(`^t([0-9]+)$`)
+
+// The variables available to an expression evaluated on tab-separated lines,
+// and how to get each one's value from a line
+type ExprVars struct {
+	names map[string]int
+	getters []func(line []string) float64
+	// Columns that can be compared to strings
+	cols map[string]int
+}
+
+func colGetter(col int) func(line []string) float64 {
+	return func(line []string) float64 {
+		if len(line) <= col {
+			return math.NaN()
+		}
+		return AlwaysParseFloat(line[col])
+	}
+}
+
+// Set up the standard variables: start, end, len (end - start), val and x
+// (column 3), cN (column N), tN (column 3 + N, the Nth joined track), plus
+// the named columns in cols. Chr (column 0) can only be compared to strings.
+func NewExprVars(cols map[string]int) *ExprVars {
+	v := &ExprVars{names: map[string]int{}, cols: map[string]int{"chr": 0, "val": 3, "x": 3}}
+	v.add("start", colGetter(1))
+	v.add("end", colGetter(2))
+	v.add("val", colGetter(3))
+	v.add("x", colGetter(3))
+	startf, endf := colGetter(1), colGetter(2)
+	v.add("len", func(line []string) float64 { return endf(line) - startf(line) })
+	for name, col := range cols {
+		v.add(name, colGetter(col))
+		v.cols[name] = col
+	}
+	return v
+}
+
+// Find the index of a variable that is 1 where column name equals lit, and 0 elsewhere
+func (v *ExprVars) StrEqual(name, lit string) (int, bool) {
+	col, ok := v.cols[name]
+	if !ok {
+		if m := colVarRe.FindStringSubmatch(name); m != nil {
+			col, _ = strconv.Atoi(m[1])
+		} else if m := trackVarRe.FindStringSubmatch(name); m != nil {
+			col, _ = strconv.Atoi(m[1])
+			col += 3
+		} else {
+			return 0, false
+		}
+	}
+
+	key := name + "==" + strconv.Quote(lit)
+	v.add(key, func(line []string) float64 {
+		return truth(len(line) > col && line[col] == lit)
+	})
+	return v.names[key], true
+}
+
+func (v *ExprVars) add(name string, getter func([]string) float64) {
+	if idx, ok := v.names[name]; ok {
+		v.getters[idx] = getter
+		return
+	}
+	v.names[name] = len(v.getters)
+	v.getters = append(v.getters, getter)
+}
+
+// Find the index of a variable, adding cN and tN variables as they are used
+func (v *ExprVars) Lookup(name string) (int, bool) {
+	if idx, ok := v.names[name]; ok {
+		return idx, true
+	}
+	if m := colVarRe.FindStringSubmatch(name); m != nil {
+		col, _ := strconv.Atoi(m[1])
+		v.add(name, colGetter(col))
+		return v.names[name], true
+	}
+	if m := trackVarRe.FindStringSubmatch(name); m != nil {
+		track, _ := strconv.Atoi(m[1])
+		v.add(name, colGetter(3 + track))
+		return v.names[name], true
+	}
+	return 0, false
+}
+
+// Fill vals with the value of every variable for line
+func (v *ExprVars) Values(vals []float64, line []string) []float64 {
+	vals = vals[:0]
+	for _, get := range v.getters {
+		vals = append(vals, get(line))
+	}
+	return vals
+}
+
+type ExprArgs struct {
+	// The expression to evaluate, i.e. "log2((a+1)/(b+1))"
+	Expr string
+	// Extra variable names, each mapped to a 0-based column index or a header name.
+	// Header names consume the first line of each input as a header.
+	Vars map[string]any
+	// Join all inputs on exact span matches into one stream, with input N as variable tN
+	Join bool
+	// What x/0 gives: "nan" (default), "inf", or "zero"
+	DivZero string
+	// Drop records where the result is NaN instead of writing them
+	DropNaN bool
+}
+
+// Evaluate an arithmetic expression on every line of every reader, writing
+// chr, start, end, and the result. Anyargs must be of type ExprArgs.
+func ExprFunc(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	h := Handle("ExprFunc: %w")
+
+	var args ExprArgs
+	if e := UnmarshalJsonOut(anyargs, &args); e != nil {
+		return nil, h(e)
+	}
+	divzero, e := ParseDivZeroMode(args.DivZero)
+	if e != nil { return nil, h(e) }
+
+	specs := []any{}
+	for _, spec := range args.Vars {
+		specs = append(specs, spec)
+	}
+	if HasNamedCols(specs) && args.Join {
+		return nil, h(fmt.Errorf("header column names cannot be used with Join"))
+	}
+
+	if args.Join && len(rs) > 1 {
+		rs = []io.Reader{JoinSpans(rs...)}
+	}
+
+	var out []io.Reader
+	for _, r := range rs {
+		var vars *ExprVars
+		vars, r, e = ResolveExprVars(r, args.Vars)
+		if e != nil { return nil, h(e) }
+		ex, e := CompileExprStrings(args.Expr, vars.Lookup, vars.StrEqual, divzero)
+		if e != nil { return nil, h(e) }
+
+		out = append(out, ExprOne(r, ex, vars, args.DropNaN))
+	}
+	return out, nil
+}
+
+// Set up the variables for expressions on r, with extra variables mapped to
+// column indices or header names. If any are header names, the header is
+// read from r, and the rest of r is returned.
+func ResolveExprVars(r io.Reader, varspecs map[string]any) (*ExprVars, io.Reader, error) {
+	h := Handle("ResolveExprVars: %w")
+
+	specs := []any{}
+	names := []string{}
+	for name, spec := range varspecs {
+		names = append(names, name)
+		specs = append(specs, spec)
+	}
+
+	var cols []int
+	if HasNamedCols(specs) {
+		header, rest, e := ReadHeader(r)
+		if e != nil { return nil, nil, h(e) }
+		r = rest
+		cols, e = ResolveCols(header, specs)
+		if e != nil { return nil, nil, h(e) }
+	} else {
+		for _, spec := range specs {
+			col, ok := spec.(float64)
+			if !ok { return nil, nil, h(fmt.Errorf("column %v is not an index", spec)) }
+			cols = append(cols, int(col))
+		}
+	}
+
+	colmap := map[string]int{}
+	for i, name := range names {
+		colmap[name] = cols[i]
+	}
+	return NewExprVars(colmap), r, nil
+}
+
+// Evaluate a compiled expression on every line of r
+func ExprOne(r io.Reader, ex *Expr, vars *ExprVars, dropNaN bool) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+
+		var vals []float64
+		i := 0
+		j := 0
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			i++
+			vals = vars.Values(vals, line)
+			val := ex.Eval(vals)
+			if dropNaN && math.IsNaN(val) {
+				continue
+			}
+			j++
+			fmt.Fprintf(bw, "%v\t%v\t%v\t%v\n", line[0], line[1], line[2], val)
+		}
+		fmt.Fprintf(os.Stderr, "Expr: printed %v of %v lines\n", j, i)
+	})
+}
+
+// Join readers on exact span matches. Each output line is chr, start, end,
+// then the value column of each reader in order, or NaN if that reader had no
+// value for the span. Spans are written in the order they are first seen.
+func JoinSpans(rs ...io.Reader) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		var spans []Span
+		vals := map[Span][]string{}
+		for i, r := range rs {
+			s := bufio.NewScanner(r)
+			s.Buffer([]byte{}, 1e12)
+			for s.Scan() {
+				entry, err := CollectEntryDumb(s.Text())
+				if err != nil {
+					continue
+				}
+				v, ok := vals[entry.Span]
+				if !ok {
+					v = make([]string, len(rs))
+					for j, _ := range v {
+						v[j] = "NaN"
+					}
+					vals[entry.Span] = v
+					spans = append(spans, entry.Span)
+				}
+				v[i] = entry.Val
+			}
+		}
+
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+		for _, span := range spans {
+			fmt.Fprintf(bw, "%v\t%v\t%v\t%v\n", span.Chr, span.Start, span.End, strings.Join(vals[span], "\t"))
+		}
+	})
+}
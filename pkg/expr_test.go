@@ -0,0 +1,65 @@
+package covplots
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCompileExpr(t *testing.T) {
+	vars := NewExprVars(map[string]int{"a": 3, "b": 4})
+	line := []string{"2L", "100", "200", "3", "1"}
+
+	type test struct {
+		src string
+		expect float64
+	}
+	tests := []test{
+		test{"log2((a+1)/(b+1))", 1},
+		test{"a/(a+b)", 0.75},
+		test{"clamp(-a*10, -5, 5)", -5},
+		test{"len / 2 + start", 150},
+		test{"2^3^2", 512},
+		test{"-a^2", -9},
+		test{"max(a, b, c4 * 10)", 10},
+		test{"ifnan(a/0, -1)", -1},
+	}
+
+	for _, tst := range tests {
+		ex, e := CompileExpr(tst.src, vars.Lookup, DivZeroNaN)
+		if e != nil { panic(e) }
+		out := ex.Eval(vars.Values(nil, line))
+		if out != tst.expect {
+			t.Errorf("%v: out %v != expect %v", tst.src, out, tst.expect)
+		}
+	}
+
+	ex, e := CompileExpr("a / (b - 1)", vars.Lookup, DivZeroNaN)
+	if e != nil { panic(e) }
+	if out := ex.Eval(vars.Values(nil, line)); !math.IsNaN(out) {
+		t.Errorf("division by zero gave %v, not NaN", out)
+	}
+
+	for _, bad := range []string{"a +", "foo(a)", "unknown * 2", "(a", "clamp(a, 1)"} {
+		if _, e := CompileExpr(bad, vars.Lookup, DivZeroNaN); e == nil {
+			t.Errorf("bad expression %v compiled", bad)
+		}
+	}
+}
+
+func TestExprJoin(t *testing.T) {
+	r1 := strings.NewReader("2L\t0\t10\t3\n2L\t10\t20\t1\n")
+	r2 := strings.NewReader("2L\t10\t20\t1\n2L\t0\t10\t1\n")
+	args := map[string]any{"Expr": "t0 / (t0 + t1)", "Join": true}
+
+	rs, e := ExprFunc([]io.Reader{r1, r2}, args)
+	if e != nil { panic(e) }
+
+	var b strings.Builder
+	io.Copy(&b, rs[0])
+	expect := "2L\t0\t10\t0.75\n2L\t10\t20\t0.5\n"
+	if b.String() != expect {
+		t.Errorf("b.String() %v != expect %v", b.String(), expect)
+	}
+}
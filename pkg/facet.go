@@ -116,7 +116,7 @@ func PlfmtPath(inpath, outpre string, margs MultiplotPlotFuncArgs) error {
 	var r io.Reader = fp
 
 	if !margs.Fullchr {
-		r2, err := FilterMulti(margs.Chr, margs.Start, margs.End, r)
+		r2, err := FilterMulti(margs.Cfg.Naming, margs.Chr, margs.Start, margs.End, r)
 		if err != nil {
 			return h(err)
 		}
@@ -0,0 +1,174 @@
+package covplots
+
+import (
+	"fmt"
+	"strings"
+)
+
+// One panel of a faceted plot, in a config's "facets" section
+type FacetCfg struct {
+	Name string `json:"name"`
+	// Names of the input sets drawn in this panel
+	InputSets []string `json:"inputsets"`
+	// Y range of the panel; default the config's automatic or fixed limits
+	Ylim []float64 `json:"ylim"`
+	// Height relative to the other panels; default 1
+	Height float64 `json:"height"`
+	// Strip label; default Name
+	Title string `json:"title"`
+}
+
+// Whether plotfunc reads the FACET column that a config's facets add to the
+// plfmt data. The other R plot functions would read it as their own columns.
+func facetPlotfunc(plotfunc string) bool {
+	return PlotfuncLayout(plotfunc) == PlfmtFacet || nativePlotfunc(plotfunc) || htmlPlotfunc(plotfunc)
+}
+
+// Check that a config's facets name every input set exactly once, and
+// nothing else, and that its plot function draws facets. All problems are
+// reported together.
+func ValidateFacets(cfg UltimateConfig) error {
+	if len(cfg.Facets) == 0 {
+		return nil
+	}
+	var errs Errors
+	if !facetPlotfunc(cfg.Plotfunc) {
+		errs = append(errs, fmt.Errorf("plot function %q does not read facets", cfg.Plotfunc))
+	}
+	sets := map[string]bool{}
+	for _, set := range cfg.InputSets {
+		sets[set.Name] = true
+		for _, f := range set.Functions {
+			if f == "add_facet" {
+				errs = append(errs, fmt.Errorf("input set %q uses add_facet, but the config has facets", set.Name))
+			}
+		}
+	}
+
+	facetOf := map[string]string{}
+	names := map[string]bool{}
+	for i, f := range cfg.Facets {
+		switch {
+		case f.Name == "":
+			errs = append(errs, fmt.Errorf("facet %v has no name", i))
+		case strings.ContainsAny(f.Name, " \t\n"):
+			errs = append(errs, fmt.Errorf("facet name %q contains whitespace", f.Name))
+		case names[f.Name]:
+			errs = append(errs, fmt.Errorf("facet %q appears more than once", f.Name))
+		}
+		names[f.Name] = true
+		if len(f.InputSets) == 0 {
+			errs = append(errs, fmt.Errorf("facet %q has no input sets", f.Name))
+		}
+		for _, set := range f.InputSets {
+			if !sets[set] {
+				errs = append(errs, fmt.Errorf("facet %q: no input set named %q", f.Name, set))
+			} else if other, ok := facetOf[set]; ok {
+				errs = append(errs, fmt.Errorf("input set %q is in facets %q and %q", set, other, f.Name))
+			} else {
+				facetOf[set] = f.Name
+			}
+		}
+		if f.Ylim != nil && (len(f.Ylim) != 2 || f.Ylim[0] >= f.Ylim[1]) {
+			errs = append(errs, fmt.Errorf("facet %q: ylim %v is not a low and a higher high value", f.Name, f.Ylim))
+		}
+		if f.Height < 0 {
+			errs = append(errs, fmt.Errorf("facet %q: negative height %v", f.Name, f.Height))
+		}
+		if strings.ContainsAny(f.Title, "\t\n") {
+			errs = append(errs, fmt.Errorf("facet %q: title %q contains a tab or newline", f.Name, f.Title))
+		}
+	}
+	for _, set := range cfg.InputSets {
+		if _, ok := facetOf[set.Name]; !ok && sets[set.Name] {
+			errs = append(errs, fmt.Errorf("input set %q is not in any facet", set.Name))
+			sets[set.Name] = false
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("ValidateFacets: config %q: %w", cfg.Outpre, errs)
+	}
+	return nil
+}
+
+// The facet of each input set, by name
+func facetsBySet(facets []FacetCfg) map[string]string {
+	out := map[string]string{}
+	for _, f := range facets {
+		for _, set := range f.InputSets {
+			out[set] = f.Name
+		}
+	}
+	return out
+}
+
+// A facet scale with the facets' order, titles, and heights
+func facetsScale(facets []FacetCfg) SpecScale {
+	var out SpecScale
+	for _, f := range facets {
+		title := f.Title
+		if title == "" {
+			title = f.Name
+		}
+		height := f.Height
+		if height == 0 {
+			height = 1
+		}
+		out.Breaks = append(out.Breaks, f.Name)
+		out.Labels = append(out.Labels, title)
+		out.Heights = append(out.Heights, height)
+	}
+	return out
+}
+
+// The y limits of single facets: those set in facets, or else those in auto.
+// Nil if neither has any.
+func facetYlims(facets []FacetCfg, auto map[string][]float64) map[string][]float64 {
+	var out map[string][]float64
+	set := func(facet string, lim []float64) {
+		if out == nil {
+			out = map[string][]float64{}
+		}
+		out[facet] = lim
+	}
+	for facet, lim := range auto {
+		set(facet, lim)
+	}
+	for _, f := range facets {
+		if f.Ylim != nil {
+			set(f.Name, f.Ylim)
+		}
+	}
+	return out
+}
+
+// Limits for a scales file: one row for each configured facet, in order, then
+// the other facets with limits. Facets without limits get ylim.
+func facetScalesYlims(facets []FacetCfg, lims AutoYlims, ylim []float64) AutoYlims {
+	out := AutoYlims{Ylim: lims.Ylim, Facets: map[string][]float64{}}
+	for _, f := range facets {
+		out.FacetNames = append(out.FacetNames, f.Name)
+	}
+	for _, name := range lims.FacetNames {
+		if _, ok := lims.Facets[name]; ok && !containsString(out.FacetNames, name) {
+			out.FacetNames = append(out.FacetNames, name)
+		}
+	}
+	for _, name := range out.FacetNames {
+		out.Facets[name] = ylim
+		if lim, ok := lims.Facets[name]; ok {
+			out.Facets[name] = lim
+		}
+	}
+	return out
+}
+
+func containsString(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
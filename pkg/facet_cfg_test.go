@@ -0,0 +1,151 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func facetTestConfig() UltimateConfig {
+	var cfg UltimateConfig
+	cfg.Outpre = "out"
+	cfg.Plotfunc = "plot_multi_facet"
+	cfg.InputSets = []InputSet{{Name: "ixw"}, {Name: "ixa"}, {Name: "pfst"}}
+	cfg.Facets = []FacetCfg{
+		{Name: "cov", InputSets: []string{"ixw", "ixa"}, Ylim: []float64{0, 100}, Height: 2, Title: "Coverage"},
+		{Name: "fst", InputSets: []string{"pfst"}},
+	}
+	return cfg
+}
+
+func TestValidateFacets(t *testing.T) {
+	if e := ValidateFacets(facetTestConfig()); e != nil {
+		t.Errorf("error for valid facets: %v", e)
+	}
+
+	for _, plotfunc := range []string{"native_multi", "html", "plotspec", "plot_vsill"} {
+		cfg := facetTestConfig()
+		cfg.Plotfunc = plotfunc
+		if e := ValidateFacets(cfg); e != nil {
+			t.Errorf("error for facets with %q: %v", plotfunc, e)
+		}
+	}
+
+	cfg := facetTestConfig()
+	cfg.Plotfunc = "plot_multi"
+	cfg.InputSets = append(cfg.InputSets, InputSet{Name: "extra"}, InputSet{Name: "old", Functions: []string{"add_facet"}})
+	cfg.Facets[0].InputSets = append(cfg.Facets[0].InputSets, "missing", "pfst")
+	cfg.Facets[1].Ylim = []float64{1, 0}
+	err := ValidateFacets(cfg)
+	if err == nil {
+		t.Errorf("no error for invalid facets")
+		return
+	}
+	for _, want := range []string{`"old" uses add_facet`, `no input set named "missing"`, `"pfst" is in facets "cov" and "fst"`,
+		`"extra" is not in any facet`, `"old" is not in any facet`, "ylim [1 0]", `"plot_multi" does not read facets`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateFacetsFacetname(t *testing.T) {
+	// plot_multi_facetname_scales would read the FACET column as its labels
+	cfg := facetTestConfig()
+	cfg.Plotfunc = "plot_multi_facetname_scales"
+	if e := ValidateFacets(cfg); e == nil || !strings.Contains(e.Error(), "does not read facets") {
+		t.Errorf("error %v for facets with plot_multi_facetname_scales", e)
+	}
+}
+
+func TestFacetScalesYlims(t *testing.T) {
+	facets := facetTestConfig().Facets
+	auto := AutoYlims{Ylim: []float64{-1, 1}, FacetNames: []string{"fst", "other"}, Facets: map[string][]float64{"cov": {0, 1}, "other": {2, 3}}}
+	auto.Facets = facetYlims(facets, auto.Facets)
+	out := facetScalesYlims(facets, auto, []float64{-5, 5})
+	expect := AutoYlims{
+		Ylim: []float64{-1, 1},
+		FacetNames: []string{"cov", "fst", "other"},
+		Facets: map[string][]float64{"cov": {0, 100}, "fst": {-5, 5}, "other": {2, 3}},
+	}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+	if out := facetYlims(nil, nil); out != nil {
+		t.Errorf("out %v != expect %v", out, nil)
+	}
+}
+
+func TestPlotNativeFacets(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t0\t10\t0.5\tfst\tpfst\t0\t0\t10\n" +
+		"2L\t0\t10\t50\tcov\tixw\t0\t0\t10\n" +
+		"2L\t0\t10\t60\tcov\tixa\t0\t0\t10\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+
+	margs := MultiplotPlotFuncArgs{Cfg: facetTestConfig()}
+	if e := PlotNativeAny(PlfmtFacet)(outpre, []float64{0, 1}, nil, margs); e != nil { panic(e) }
+	svg, err := os.ReadFile(outpre + "_plotted.svg")
+	if err != nil {
+		panic(err)
+	}
+	s := string(svg)
+	cov, fst := strings.Index(s, ">Coverage<"), strings.Index(s, ">fst<")
+	if cov < 0 || fst < 0 || cov > fst {
+		t.Errorf("facet titles not drawn in order: %v, %v", cov, fst)
+	}
+	// The coverage panel has its own y range, which has a tick at 100
+	if !strings.Contains(s, ">100<") {
+		t.Errorf("svg does not contain a tick at %v", 100)
+	}
+}
+
+func TestValidatePlotOutput(t *testing.T) {
+	var cfg UltimateConfig
+	cfg.Outpre = "out"
+	cfg.Plotfunc = "native_multi"
+	cfg.PlotOutput = PlotOutputCfg{Formats: []string{"png"}, Width: 8, Height: 3, DPI: 300}
+	if e := ValidatePlotOutput(cfg); e != nil {
+		t.Errorf("error for native plot output: %v", e)
+	}
+
+	cfg.Plotfunc = "plot_multi"
+	cfg.PlotOutput = PlotOutputCfg{Width: 8, Height: 3, Booklet: true}
+	if e := ValidatePlotOutput(cfg); e != nil {
+		t.Errorf("error for booklet size: %v", e)
+	}
+
+	cfg.PlotOutput = PlotOutputCfg{Formats: []string{"pdf"}, Width: 8, DPI: 300}
+	err := ValidateConfig(cfg)
+	if err == nil {
+		t.Errorf("no error for plot output an R plot ignores")
+		return
+	}
+	for _, want := range []string{"formats [pdf]", "dpi 300", "width and height"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateStyles(t *testing.T) {
+	var cfg UltimateConfig
+	cfg.Outpre = "out"
+	cfg.InputSets = []InputSet{{Name: "ixw", Style: SetStyle{Color: "red"}}, {Name: "ixa"}}
+	for _, plotfunc := range []string{"", "plot_multi", "native_multi_facet", "html", "plotspec"} {
+		cfg.Plotfunc = plotfunc
+		if e := ValidateStyles(cfg); e != nil {
+			t.Errorf("error for styles with %q: %v", plotfunc, e)
+		}
+	}
+
+	cfg.Plotfunc = "plot_sawamura"
+	err := ValidateConfig(cfg)
+	if err == nil {
+		t.Errorf("no error for styles with %q", cfg.Plotfunc)
+	} else if !strings.Contains(err.Error(), `["ixw"]`) {
+		t.Errorf("error %q does not name %q", err, "ixw")
+	}
+}
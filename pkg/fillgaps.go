@@ -0,0 +1,288 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type FillGapsArgs struct {
+	// Width of inserted records; gaps are cut at multiples of Resolution.
+	// If 0, each gap is filled by one record.
+	Resolution int64
+	// "nan" (the default) or "zero"
+	Fill string
+}
+
+type gapRec struct {
+	line []string
+	start int64
+	end int64
+}
+
+// Write fill records covering start to end, cut at multiples of res if res > 0
+func writeGap(w io.Writer, chr string, start, end, res int64, ncols int, fill string) int {
+	n := 0
+	for start < end {
+		stop := end
+		if res > 0 {
+			if next := (start / res + 1) * res; next < stop {
+				stop = next
+			}
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v", chr, start, stop)
+		for i := 3; i < ncols; i++ {
+			fmt.Fprintf(w, "\t%v", fill)
+		}
+		fmt.Fprintf(w, "\n")
+		start = stop
+		n++
+	}
+	return n
+}
+
+// The full chromosome names that an input uses, for naming the fill records
+// of chromosomes that have no records
+type ChrNames struct {
+	// Full names by chromosome without its parent, in order of first appearance
+	Names map[string][]string
+	// Parents in order of first appearance
+	Parents []string
+}
+
+func (c *ChrNames) Add(name string, naming ChrNaming) {
+	chr, parent := naming.Split(name)
+	if c.Names == nil {
+		c.Names = map[string][]string{}
+	}
+	if !containsString(c.Names[chr], name) {
+		c.Names[chr] = append(c.Names[chr], name)
+	}
+	if !containsString(c.Parents, parent) {
+		c.Parents = append(c.Parents, parent)
+	}
+}
+
+// The names to fill chr under: the input's names for it, or else chr with
+// each parent the input uses, or chr itself if the input has no names
+func (c ChrNames) fillNames(chr string, naming ChrNaming) []string {
+	if names := c.Names[chr]; len(names) > 0 {
+		return names
+	}
+	if len(c.Parents) == 0 {
+		return []string{chr}
+	}
+	var out []string
+	for _, parent := range c.Parents {
+		out = append(out, naming.Join(chr, parent))
+	}
+	return out
+}
+
+// Read the chromosome names of every reader
+func ReadChrNames(rs []io.Reader, naming ChrNaming) ([]ChrNames, error) {
+	out := make([]ChrNames, len(rs))
+	for i, r := range rs {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			_, e1 := strconv.ParseInt(line[1], 0, 64)
+			_, e2 := strconv.ParseInt(line[2], 0, 64)
+			if e1 != nil || e2 != nil {
+				continue
+			}
+			out[i].Add(line[0], naming)
+		}
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("ReadChrNames: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// Insert fill records into every region of r not covered by a record.
+// Regions returns the start and end of the region to fill on each input
+// chromosome, and ok = false to leave that chromosome alone. Records are
+// sorted by start within each chromosome. Fill records have as many columns
+// as the first record on their chromosome.
+func FillGapsOne(r io.Reader, regions func(chr string) (start, end int64, ok bool), res int64, fill string) io.Reader {
+	return FillGapsOneChrs(r, regions, res, fill, nil, ChrNames{}, ChrNaming{})
+}
+
+// Like FillGapsOne, but also fill the whole region of each of fillChrs, given
+// without parents, that no chromosome of r has, with 4-column records. They
+// are named as known and r name that chromosome, or else with the parents
+// that known and r use, so that they join the rest of the input's records.
+func FillGapsOneChrs(r io.Reader, regions func(chr string) (start, end int64, ok bool), res int64, fill string, fillChrs []string, known ChrNames, naming ChrNaming) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+
+		var chrs []string
+		recs := map[string][]gapRec{}
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			start, e1 := strconv.ParseInt(line[1], 0, 64)
+			end, e2 := strconv.ParseInt(line[2], 0, 64)
+			if e1 != nil || e2 != nil {
+				fmt.Fprintf(os.Stderr, "FillGaps: could not parse span of line %v\n", line)
+				continue
+			}
+			if _, ok := recs[line[0]]; !ok {
+				chrs = append(chrs, line[0])
+			}
+			recs[line[0]] = append(recs[line[0]], gapRec{line, start, end})
+		}
+
+		filled := 0
+		for _, chr := range chrs {
+			rs := recs[chr]
+			sort.SliceStable(rs, func(i, j int) bool { return rs[i].start < rs[j].start })
+			ncols := len(rs[0].line)
+			if ncols < 4 {
+				ncols = 4
+			}
+
+			rstart, rend, ok := regions(chr)
+			cursor := rstart
+			for _, rec := range rs {
+				if ok && rec.start > cursor && cursor < rend {
+					stop := rec.start
+					if stop > rend {
+						stop = rend
+					}
+					filled += writeGap(bw, chr, cursor, stop, res, ncols, fill)
+				}
+				if rec.end > cursor {
+					cursor = rec.end
+				}
+				fmt.Fprintln(bw, strings.Join(rec.line, "\t"))
+			}
+			if ok && cursor < rend {
+				filled += writeGap(bw, chr, cursor, rend, res, ncols, fill)
+			}
+		}
+
+		seen := map[string]bool{}
+		var names ChrNames
+		for _, chr := range chrs {
+			seen[naming.Chr(chr)] = true
+			names.Add(chr, naming)
+		}
+		for _, parent := range known.Parents {
+			if !containsString(names.Parents, parent) {
+				names.Parents = append(names.Parents, parent)
+			}
+		}
+		for _, chr := range fillChrs {
+			if seen[chr] {
+				continue
+			}
+			fillNames := known.Names[chr]
+			if len(fillNames) == 0 {
+				fillNames = names.fillNames(chr, naming)
+			}
+			for _, name := range fillNames {
+				if rstart, rend, ok := regions(name); ok {
+					filled += writeGap(bw, name, rstart, rend, res, 4, fill)
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "FillGaps: inserted %v records\n", filled)
+	})
+}
+
+// Fill uncovered regions with NaN or zero records: the plot window, or each
+// whole chromosome from chrlens if the plot is fullchr. The window, or each
+// chromosome in chrlens, is filled even if it has no records. Anyargs must be
+// of type FillGapsArgs.
+func FillGaps(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("FillGaps: %w")
+
+	var args FillGapsArgs
+	err := UnmarshalJsonOut(anyargs, &args)
+	if err != nil { return nil, h(err) }
+
+	fill := "NaN"
+	switch args.Fill {
+	case "", "nan":
+	case "zero": fill = "0"
+	default: return nil, h(fmt.Errorf("unknown Fill %q", args.Fill))
+	}
+	if args.Resolution < 0 {
+		return nil, h(fmt.Errorf("negative Resolution %v", args.Resolution))
+	}
+
+	chrlens, err := ctx.chrLens()
+	if err != nil { return nil, h(err) }
+	if chrlens == nil && ctx.Fullchr && !ctx.wholeGenomePass {
+		return nil, h(fmt.Errorf("fullchr plots need chrlens"))
+	}
+	lens := chrLenMap(chrlens)
+
+	var chrs []string
+	if ctx.Fullchr {
+		for _, cl := range chrlens {
+			chrs = append(chrs, cl.Chr)
+		}
+	} else {
+		chrs = []string{ctx.Chr}
+	}
+
+	regions := func(name string) (int64, int64, bool) {
+		chr := ctx.Cfg.Naming.Chr(name)
+		chrlen, haslen := lens[chr]
+		if ctx.Fullchr {
+			return 0, chrlen, haslen
+		}
+		if chr != ctx.Chr {
+			return 0, 0, false
+		}
+		end := int64(ctx.End)
+		if haslen && chrlen < end {
+			end = chrlen
+		}
+		return int64(ctx.Start), end, true
+	}
+
+	known, err := fillChrNames(ctx, len(rs))
+	if err != nil { return nil, h(err) }
+
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		out[i] = FillGapsOneChrs(r, regions, args.Resolution, fill, chrs, known[i], ctx.Cfg.Naming)
+	}
+	return out, nil
+}
+
+// The chromosome names of each reader of the function's input over the whole
+// genome, read once per config, or empty names without that input
+func fillChrNames(ctx MultiplotFuncCtx, n int) ([]ChrNames, error) {
+	if ctx.wholeInput == nil || ctx.wholeGenomePass {
+		return make([]ChrNames, n), nil
+	}
+	names, err := configCached(ctx.Data, ctx.wholeInputKey + " chromosome names", func() ([]ChrNames, error) {
+		rs, closers, err := ctx.wholeInput()
+		if err != nil { return nil, err }
+		defer CloseAny(closers...)
+		return ReadChrNames(rs, ctx.Cfg.Naming)
+	})
+	if err != nil { return nil, fmt.Errorf("fillChrNames: %w", err) }
+	if len(names) != n {
+		return nil, fmt.Errorf("fillChrNames: %v readers over the whole genome, but %v in the window", len(names), n)
+	}
+	return names, nil
+}
@@ -0,0 +1,96 @@
+package covplots
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runFillGaps(in string, args FillGapsArgs, ctx MultiplotFuncCtx) string {
+	rs, err := FillGaps([]io.Reader{strings.NewReader(in)}, args, ctx)
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+func TestFillGapsWindow(t *testing.T) {
+	in := "chr1\t20\t30\t1\nchr1\t50\t60\t2\nchr2\t0\t10\t3\n"
+	ctx := MultiplotFuncCtx{Chr: "chr1", Start: 10, End: 70}
+	expect := "chr1\t10\t20\tNaN\nchr1\t20\t30\t1\nchr1\t30\t40\tNaN\nchr1\t40\t50\tNaN\nchr1\t50\t60\t2\nchr1\t60\t70\tNaN\nchr2\t0\t10\t3\n"
+
+	out := runFillGaps(in, FillGapsArgs{Resolution: 10}, ctx)
+	if out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
+
+func TestFillGapsFullchr(t *testing.T) {
+	chrlens := filepath.Join(t.TempDir(), "chrlens.bed")
+	if e := os.WriteFile(chrlens, []byte("chr1\t0\t100\n"), 0644); e != nil { panic(e) }
+
+	in := "chr1_a\t20\t30\t1\n"
+	ctx := MultiplotFuncCtx{Cfg: UltimateConfig{Chrlens: chrlens}, Fullchr: true}
+	expect := "chr1_a\t0\t20\t0\nchr1_a\t20\t30\t1\nchr1_a\t30\t100\t0\n"
+
+	out := runFillGaps(in, FillGapsArgs{Fill: "zero"}, ctx)
+	if out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
+
+func TestFillGapsEmpty(t *testing.T) {
+	// A track without records in the window is filled across all of it, with
+	// the parent of its other records
+	ctx := MultiplotFuncCtx{Chr: "chr1", Start: 10, End: 30}
+	expect := "chr2_a\t0\t10\t3\nchr1_a\t10\t20\tNaN\nchr1_a\t20\t30\tNaN\n"
+	if out := runFillGaps("chr2_a\t0\t10\t3\n", FillGapsArgs{Resolution: 10}, ctx); out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+	expect = "chr1\t10\t20\tNaN\nchr1\t20\t30\tNaN\n"
+	if out := runFillGaps("", FillGapsArgs{Resolution: 10}, ctx); out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+
+	chrlens := filepath.Join(t.TempDir(), "chrlens.bed")
+	if e := os.WriteFile(chrlens, []byte("chr1\t0\t100\nchr2\t0\t50\n"), 0644); e != nil { panic(e) }
+	ctx = MultiplotFuncCtx{Cfg: UltimateConfig{Chrlens: chrlens}, Fullchr: true}
+	expect = "chr1_a\t0\t100\t1\nchr2_a\t0\t50\t0\n"
+	if out := runFillGaps("chr1_a\t0\t100\t1\n", FillGapsArgs{Fill: "zero"}, ctx); out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
+
+func TestFillGapsEmptyWindow(t *testing.T) {
+	dir := t.TempDir()
+	near := filepath.Join(dir, "near.bed")
+	if e := os.WriteFile(near, []byte("chr1_ISO1\t50\t60\t1\n"), 0644); e != nil { panic(e) }
+	other := filepath.Join(dir, "other.bed")
+	if e := os.WriteFile(other, []byte("chr2_ISO1\t0\t10\t3\n"), 0644); e != nil { panic(e) }
+
+	// Neither set has records in the window, which is filled under the names
+	// the sets use elsewhere rather than as a separate chromosome "chr1"
+	ctx := MultiplotFuncCtx{Outpre: filepath.Join(dir, "out"), Chr: "chr1", Start: 10, End: 30, Data: new(ConfigData)}
+	for _, path := range []string{near, other} {
+		set := InputSet{Paths: []string{path}, Name: "in", Functions: []string{"fill_gaps"}, FunctionArgs: []any{map[string]any{"Resolution": 10}}}
+		r, closers, err := MultiplotInputSet(set, ctx)
+		if err != nil {
+			panic(err)
+		}
+		out, err := io.ReadAll(r)
+		CloseAny(closers...)
+		if err != nil {
+			panic(err)
+		}
+		expect := "chr1_ISO1\t10\t20\tNaN\nchr1_ISO1\t20\t30\tNaN\n"
+		if string(out) != expect {
+			t.Errorf("%v: out %q != expect %q", path, out, expect)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type FilterArgs struct {
+	// Conditions each record must meet, i.e. "val > 2" or "not isnan(val)".
+	// They use the same syntax and variables as expr.
+	Conditions []string
+	// "and" (the default) keeps records meeting every condition; "or" keeps
+	// records meeting any condition
+	Combine string
+	// Extra variable names, as in ExprArgs
+	Vars map[string]any
+	// What x/0 gives: "nan" (default), "inf", or "zero"
+	DivZero string
+}
+
+// Keep the lines of r that meet the conditions. With and, each dropped line
+// is counted against the first condition it fails; with or, every condition
+// a dropped line fails is counted.
+func FilterConditionsOne(r io.Reader, conds []*Expr, srcs []string, vars *ExprVars, or bool) io.Reader {
+	return PipeWrite(func(w io.Writer) {
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+
+		var vals []float64
+		dropped := make([]int, len(conds))
+		total, kept := 0, 0
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			total++
+			vals = vars.Values(vals, line)
+			if !filterKeep(vals, conds, or, dropped) {
+				continue
+			}
+			kept++
+			fmt.Fprintln(bw, s.Text())
+		}
+
+		for i, src := range srcs {
+			fmt.Fprintf(os.Stderr, "Filter: condition %q dropped %v lines\n", src, dropped[i])
+		}
+		fmt.Fprintf(os.Stderr, "Filter: kept %v of %v lines\n", kept, total)
+	})
+}
+
+// Whether a line with vals meets the conditions. If not, count it in dropped
+// against the first condition it fails with and, or against every condition
+// with or.
+func filterKeep(vals []float64, conds []*Expr, or bool, dropped []int) bool {
+	for i, cond := range conds {
+		pass := isTrue(cond.Eval(vals))
+		if or && pass {
+			return true
+		}
+		if !or && !pass {
+			dropped[i]++
+			return false
+		}
+	}
+	if !or {
+		return true
+	}
+	for i := range dropped {
+		dropped[i]++
+	}
+	return false
+}
+
+// Keep records meeting typed conditions on their columns. Anyargs must be of type FilterArgs.
+func FilterConditions(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	h := Handle("FilterConditions: %w")
+
+	var args FilterArgs
+	if e := UnmarshalJsonOut(anyargs, &args); e != nil {
+		return nil, h(e)
+	}
+	if len(args.Conditions) == 0 {
+		return nil, h(fmt.Errorf("no Conditions"))
+	}
+	var or bool
+	switch args.Combine {
+	case "", "and":
+	case "or": or = true
+	default: return nil, h(fmt.Errorf("unknown Combine %q", args.Combine))
+	}
+	divzero, e := ParseDivZeroMode(args.DivZero)
+	if e != nil { return nil, h(e) }
+
+	var out []io.Reader
+	for _, r := range rs {
+		var vars *ExprVars
+		vars, r, e = ResolveExprVars(r, args.Vars)
+		if e != nil { return nil, h(e) }
+
+		var conds []*Expr
+		for _, src := range args.Conditions {
+			cond, e := CompileExprStrings(src, vars.Lookup, vars.StrEqual, divzero)
+			if e != nil { return nil, h(e) }
+			conds = append(conds, cond)
+		}
+		out = append(out, FilterConditionsOne(r, conds, args.Conditions, vars, or))
+	}
+	return out, nil
+}
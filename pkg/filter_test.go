@@ -0,0 +1,94 @@
+package covplots
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileConditions(t *testing.T) {
+	vars := NewExprVars(map[string]int{"name": 4})
+	line := []string{"2L", "100", "1200", "-3", "Adh"}
+
+	type test struct {
+		src string
+		expect float64
+	}
+	tests := []test{
+		test{"val > 2", 0},
+		test{"abs(val) < 10 && end - start >= 1000", 1},
+		test{"val > 0 or not isnan(val)", 1},
+		test{"!(val <= -3)", 0},
+		test{"val / 0 == val / 0", 0},
+		test{"name == 'Adh' and chr != \"2R\"", 1},
+		test{"\"Adh\" != c4", 0},
+		test{"(1 < 2) + (2 < 1) * 5", 1},
+	}
+
+	for _, tst := range tests {
+		ex, e := CompileExprStrings(tst.src, vars.Lookup, vars.StrEqual, DivZeroNaN)
+		if e != nil { panic(e) }
+		out := ex.Eval(vars.Values(nil, line))
+		if out != tst.expect {
+			t.Errorf("%v: out %v != expect %v", tst.src, out, tst.expect)
+		}
+	}
+
+	if _, e := CompileExprStrings("val + 'x'", vars.Lookup, vars.StrEqual, DivZeroNaN); e == nil {
+		t.Errorf("string arithmetic compiled without error")
+	}
+}
+
+func TestFilterConditions(t *testing.T) {
+	in := "chr1\t0\t10\t1\nchr1\t10\t2000\t5\nchr1\t2000\t3000\tNaN\nchr2\t0\t5000\t20\n"
+	args := FilterArgs{Conditions: []string{"not isnan(val)", "len >= 1000", "val < 10"}}
+	expect := "chr1\t10\t2000\t5\n"
+
+	rs, err := FilterConditions([]io.Reader{strings.NewReader(in)}, args)
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	if string(out) != expect {
+		t.Errorf("out %q != expect %q", string(out), expect)
+	}
+}
+
+func TestFilterKeep(t *testing.T) {
+	vars := NewExprVars(nil)
+	var conds []*Expr
+	for _, src := range []string{"val > 2", "val < 0"} {
+		cond, e := CompileExprStrings(src, vars.Lookup, vars.StrEqual, DivZeroNaN)
+		if e != nil { panic(e) }
+		conds = append(conds, cond)
+	}
+	vals := func(val string) []float64 {
+		return vars.Values(nil, []string{"chr1", "0", "10", val})
+	}
+
+	type test struct {
+		val string
+		or bool
+		keep bool
+		dropped []int
+	}
+	tests := []test{
+		// The first condition fails, but the second passes
+		test{"-1", true, true, []int{0, 0}},
+		test{"5", true, true, []int{0, 0}},
+		test{"1", true, false, []int{1, 1}},
+		test{"5", false, false, []int{0, 1}},
+		test{"1", false, false, []int{1, 0}},
+	}
+	for _, tst := range tests {
+		dropped := make([]int, len(conds))
+		keep := filterKeep(vals(tst.val), conds, tst.or, dropped)
+		if keep != tst.keep || !reflect.DeepEqual(dropped, tst.dropped) {
+			t.Errorf("val %v, or %v: out %v %v != expect %v %v", tst.val, tst.or, keep, dropped, tst.keep, tst.dropped)
+		}
+	}
+}
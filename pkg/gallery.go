@@ -0,0 +1,234 @@
+package covplots
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The output prefix of one plotted window
+func WindowOutpre(outpre, chr string, start, end int) string {
+	return fmt.Sprintf("%s_%v_%v_%v", outpre, chr, start, end)
+}
+
+// One plotted window
+type GalleryWin struct {
+	Chr string
+	Start int
+	End int
+}
+
+// Sort windows by chromosome, in order of first appearance, then by position,
+// and remove duplicates
+func SortGalleryWins(wins []GalleryWin) []GalleryWin {
+	rank := map[string]int{}
+	for _, w := range wins {
+		if _, ok := rank[w.Chr]; !ok {
+			rank[w.Chr] = len(rank)
+		}
+	}
+	out := append([]GalleryWin(nil), wins...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Chr != b.Chr {
+			return rank[a.Chr] < rank[b.Chr]
+		}
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.End < b.End
+	})
+	var dedup []GalleryWin
+	for i, w := range out {
+		if i == 0 || w != out[i-1] {
+			dedup = append(dedup, w)
+		}
+	}
+	return dedup
+}
+
+// A file that a window produced
+type galleryFile struct {
+	Label string
+	Href string
+}
+
+// A window as shown in the gallery. Hrefs are relative to the index page.
+type galleryItem struct {
+	GalleryWin
+	Page string
+	Image string
+	Plots []galleryFile
+	Data []galleryFile
+	// Window pages, relative to this window's page
+	Prev string
+	Next string
+}
+
+type galleryChr struct {
+	Chr string
+	Items []galleryItem
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Find the plots and data that a window produced
+func galleryWinItem(outpre string, w GalleryWin) galleryItem {
+	base := WindowOutpre(outpre, w.Chr, w.Start, w.End)
+	name := filepath.Base(base)
+	it := galleryItem{GalleryWin: w, Page: "./" + name + "/index.html"}
+	for _, ext := range []string{"png", "svg", "pdf", "html"} {
+		suffix := "_plotted." + ext
+		if fileExists(base + suffix) {
+			it.Plots = append(it.Plots, galleryFile{ext, "./" + name + suffix})
+			if it.Image == "" && (ext == "png" || ext == "svg") {
+				it.Image = "./" + name + suffix
+			}
+		}
+	}
+	for _, suffix := range []string{"_plfmt.bed.gz", "_plfmt.bed"} {
+		if fileExists(base + suffix) {
+			it.Data = append(it.Data, galleryFile{"plfmt data", "./" + name + suffix})
+		}
+	}
+	return it
+}
+
+// Write outpre_index.html, with thumbnails of every window, and a page in
+// each window's directory with links to the previous and next windows
+func WriteGallery(outpre string, wins []GalleryWin) error {
+	h := Handle("WriteGallery: %w")
+	wins = SortGalleryWins(wins)
+
+	items := make([]galleryItem, len(wins))
+	for i, w := range wins {
+		items[i] = galleryWinItem(outpre, w)
+	}
+	for i := range items {
+		if i > 0 {
+			items[i].Prev = "../" + filepath.Base(WindowOutpre(outpre, wins[i-1].Chr, wins[i-1].Start, wins[i-1].End)) + "/index.html"
+		}
+		if i < len(items) - 1 {
+			items[i].Next = "../" + filepath.Base(WindowOutpre(outpre, wins[i+1].Chr, wins[i+1].Start, wins[i+1].End)) + "/index.html"
+		}
+	}
+
+	var chrs []galleryChr
+	for _, it := range items {
+		if len(chrs) == 0 || chrs[len(chrs)-1].Chr != it.Chr {
+			chrs = append(chrs, galleryChr{Chr: it.Chr})
+		}
+		chrs[len(chrs)-1].Items = append(chrs[len(chrs)-1].Items, it)
+	}
+
+	title := filepath.Base(outpre)
+	index := outpre + "_index.html"
+	booklet := ""
+	if fileExists(outpre + "_booklet.pdf") {
+		booklet = "./" + title + "_booklet.pdf"
+	}
+	err := writeTemplate(index, galleryIndexTemplate, map[string]any{"Title": title, "Chrs": chrs, "N": len(items), "Booklet": booklet})
+	if err != nil { return h(err) }
+
+	for _, it := range items {
+		dir := WindowOutpre(outpre, it.Chr, it.Start, it.End)
+		if err := os.MkdirAll(dir, 0776); err != nil { return h(err) }
+		err := writeTemplate(filepath.Join(dir, "index.html"), galleryWinTemplate, map[string]any{
+			"Title": title,
+			"Index": "../" + filepath.Base(index),
+			"Win": it,
+		})
+		if err != nil { return h(err) }
+	}
+	return nil
+}
+
+func writeTemplate(path string, t *template.Template, data any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writeTemplate: %w", err)
+	}
+	err = t.Execute(f, data)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return fmt.Errorf("writeTemplate: %w", err)
+	}
+	return nil
+}
+
+// Links from a window page are one directory down from the index
+func galleryUp(href string) string {
+	if href == "" {
+		return ""
+	}
+	return "../" + strings.TrimPrefix(href, "./")
+}
+
+const galleryStyle = `<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 16px; }
+.grid { display: flex; flex-wrap: wrap; gap: 12px; }
+.card { width: 320px; border: 1px solid #ccc; padding: 6px; font-size: 13px; }
+.card img { width: 100%; display: block; }
+.noimg { height: 120px; background: #eee; display: flex; align-items: center; justify-content: center; color: #666; }
+.links a { margin-right: 8px; }
+nav a { margin-right: 16px; }
+.big { max-width: 100%; }
+</style>`
+
+var galleryIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + galleryStyle + `
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.N}} windows{{if .Booklet}}, also as a <a href="{{.Booklet}}">PDF booklet</a>{{end}}</p>
+{{range .Chrs}}
+<h2>{{.Chr}}</h2>
+<div class="grid">
+{{range .Items}}<div class="card">
+<a href="{{.Page}}">{{if .Image}}<img src="{{.Image}}" loading="lazy" alt="{{.Chr}}:{{.Start}}-{{.End}}">{{else}}<div class="noimg">no image</div>{{end}}</a>
+<div><a href="{{.Page}}">{{.Chr}}:{{.Start}}-{{.End}}</a></div>
+<div class="links">{{range .Plots}}<a href="{{.Href}}">{{.Label}}</a>{{end}}{{range .Data}}<a href="{{.Href}}">{{.Label}}</a>{{end}}</div>
+</div>
+{{end}}</div>
+{{end}}
+</body>
+</html>
+`))
+
+var galleryWinTemplate = template.Must(template.New("win").Funcs(template.FuncMap{"up": galleryUp}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Win.Chr}}:{{.Win.Start}}-{{.Win.End}} - {{.Title}}</title>
+` + galleryStyle + `
+</head>
+<body>
+<nav>
+{{if .Win.Prev}}<a id="prev" href="{{.Win.Prev}}">&larr; previous</a>{{else}}<span>&larr; previous</span>{{end}}
+<a href="{{.Index}}">index</a>
+{{if .Win.Next}}<a id="next" href="{{.Win.Next}}">next &rarr;</a>{{else}}<span>next &rarr;</span>{{end}}
+</nav>
+<h1>{{.Win.Chr}}:{{.Win.Start}}-{{.Win.End}}</h1>
+{{if .Win.Image}}<p><img class="big" src="{{up .Win.Image}}" alt="{{.Win.Chr}}:{{.Win.Start}}-{{.Win.End}}"></p>{{end}}
+<p class="links">{{range .Win.Plots}}<a href="{{up .Href}}">{{.Label}}</a>{{end}}{{range .Win.Data}}<a href="{{up .Href}}">{{.Label}}</a>{{end}}</p>
+<script>
+document.addEventListener("keydown", function(e) {
+	var a = document.getElementById(e.key === "ArrowLeft" ? "prev" : e.key === "ArrowRight" ? "next" : "");
+	if (a) { window.location.href = a.href; }
+});
+</script>
+</body>
+</html>
+`))
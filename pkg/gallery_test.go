@@ -0,0 +1,65 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortGalleryWins(t *testing.T) {
+	in := []GalleryWin{{"2R", 0, 10}, {"2L", 10, 20}, {"2R", 0, 10}, {"2L", 0, 10}}
+	out := SortGalleryWins(in)
+	expect := []GalleryWin{{"2R", 0, 10}, {"2L", 0, 10}, {"2L", 10, 20}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestWriteGallery(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	wins := []GalleryWin{{"2L", 10, 20}, {"2L", 0, 10}, {"3R", 0, 10}}
+	for _, w := range wins {
+		base := WindowOutpre(outpre, w.Chr, w.Start, w.End)
+		if e := os.MkdirAll(base, 0776); e != nil { panic(e) }
+		if e := os.WriteFile(base + "_plfmt.bed.gz", nil, 0644); e != nil { panic(e) }
+		ext := "_plotted.png"
+		if w.Chr == "3R" {
+			ext = "_plotted.html"
+		}
+		if e := os.WriteFile(base + ext, nil, 0644); e != nil { panic(e) }
+	}
+
+	if e := WriteGallery(outpre, wins); e != nil {
+		panic(e)
+	}
+
+	index, err := os.ReadFile(outpre + "_index.html")
+	if err != nil { panic(err) }
+	s := string(index)
+	i0, i1, i2 := strings.Index(s, `src="./out_2L_0_10_plotted.png"`), strings.Index(s, `src="./out_2L_10_20_plotted.png"`), strings.Index(s, `href="./out_3R_0_10/index.html"`)
+	if i0 < 0 || i1 < i0 || i2 < i1 {
+		t.Errorf("index thumbnails missing or out of order: %v %v %v", i0, i1, i2)
+	}
+	for _, want := range []string{`href="./out_3R_0_10_plotted.html"`, `href="./out_2L_0_10_plfmt.bed.gz"`, "no image"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("index does not contain %q", want)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(outpre + "_2L_10_20", "index.html"))
+	if err != nil { panic(err) }
+	s = string(page)
+	for _, want := range []string{`href="../out_2L_0_10/index.html"`, `href="../out_3R_0_10/index.html"`, `href="../out_index.html"`, `src="../out_2L_10_20_plotted.png"`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("window page does not contain %q", want)
+		}
+	}
+
+	page, err = os.ReadFile(filepath.Join(outpre + "_2L_0_10", "index.html"))
+	if err != nil { panic(err) }
+	if strings.Contains(string(page), `id="prev"`) {
+		t.Errorf("first window has a previous link")
+	}
+}
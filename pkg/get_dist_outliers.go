@@ -31,7 +31,14 @@ func GetBedEntryLabels(labeller func(BedEntry) string, entries ...BedEntry) []st
 }
 
 func LabelByDistOutliers(cols, distcols []int, outlierPerc, lowOutlierPerc float64, entries []BedEntry, dist []BedEntry) ([]string, error) {
-	h := Handle("LbelByDistOutliers: %w")
+	return LabelByDistOutliersWeighted(cols, distcols, outlierPerc, lowOutlierPerc, false, entries, dist)
+}
+
+// Like LabelByDistOutliers, but if bpWeighted, weight each dist entry by its
+// span length. The thresholds come from all of dist, however few entries are
+// labelled, so pass the whole distribution rather than one window of it.
+func LabelByDistOutliersWeighted(cols, distcols []int, outlierPerc, lowOutlierPerc float64, bpWeighted bool, entries []BedEntry, dist []BedEntry) ([]string, error) {
+	h := Handle("LabelByDistOutliersWeighted: %w")
 
 	getval := MustGetColSums(cols...)
 	getdistval := MustGetColSums(distcols...)
@@ -39,10 +46,18 @@ func LabelByDistOutliers(cols, distcols []int, outlierPerc, lowOutlierPerc float
 	vals, err := GetBedVals(getdistval, dist...)
 	if err != nil { return nil, h(err) }
 
-	lowthresh, err := stats.Percentile(vals, lowOutlierPerc)
+	percentile := stats.Percentile
+	if bpWeighted {
+		lens := BedSpanLens(dist...)
+		percentile = func(vals stats.Float64Data, perc float64) (float64, error) {
+			return WeightedPercentile(vals, lens, perc)
+		}
+	}
+
+	lowthresh, err := percentile(vals, lowOutlierPerc)
 	if err != nil { return nil, h(err) }
 
-	hithresh, err := stats.Percentile(vals, 100.0 - outlierPerc)
+	hithresh, err := percentile(vals, 100.0 - outlierPerc)
 	if err != nil { return nil, h(err) }
 
 	labeller := func(b BedEntry) string {
@@ -77,6 +92,7 @@ type LabellerArgs struct {
 	LowThreshPerc float64
 	DistCols []int
 	DistPath string
+	BpWeighted bool
 }
 
 func SplitCommas(in string) []int {
@@ -104,6 +120,7 @@ func ReadLabellerFlags() LabellerArgs {
 	flag.StringVar(&a.DistPath, "dp", "", "distribution input path")
 	flag.StringVar(&percstring, "p", "", "Percent to keep")
 	flag.StringVar(&lowpercstring, "lp", "", "Lower percent to keep (if different from high)")
+	flag.BoolVar(&a.BpWeighted, "bp", false, "Weight each distribution line by its length in bp")
 	flag.Parse()
 
 	if a.TestPath == "" { panic(fmt.Errorf("missing -tp")) }
@@ -138,7 +155,7 @@ func FullLabelOutliers() error {
 	distEntries, err := ReadPathBed(args.DistPath)
 	if err != nil { return err }
 
-	labels, err := LabelByDistOutliers(args.TestCols, args.DistCols, args.ThreshPerc, args.LowThreshPerc, testEntries, distEntries)
+	labels, err := LabelByDistOutliersWeighted(args.TestCols, args.DistCols, args.ThreshPerc, args.LowThreshPerc, args.BpWeighted, testEntries, distEntries)
 	if err != nil { return err }
 
 	AppendLabels(testEntries, labels)
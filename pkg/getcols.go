@@ -13,6 +13,9 @@ func Columns(rs []io.Reader, args any) ([]io.Reader, error) {
 	if !ok {
 		return nil, fmt.Errorf("wrong argument %v to Columns; args is not of type []any", args)
 	}
+	if HasNamedCols(anycols) {
+		return GetMultipleHeaderCols(rs, anycols)
+	}
 	var cols []int
 	for _, acol := range anycols {
 		fcol, ok := acol.(float64)
@@ -53,13 +56,20 @@ func ToIntSliceSlice(a any) [][]int {
 }
 
 func ColumnsSome(rs []io.Reader, args any) ([]io.Reader, error) {
-	colsandreaders := ToIntSliceSlice(args)
-	cols := colsandreaders[0]
-	readers := colsandreaders[1]
+	argsl, ok := args.([]any)
+	if !ok || len(argsl) != 2 {
+		return nil, fmt.Errorf("wrong argument %v to ColumnsSome; args is not a pair of lists", args)
+	}
+	anycols, ok := argsl[0].([]any)
+	if !ok {
+		return nil, fmt.Errorf("wrong argument %v to ColumnsSome; cols %v is not of type []any", args, argsl[0])
+	}
+	readers := ToIntSlice(argsl[1])
+	if HasNamedCols(anycols) {
+		return GetMultipleHeaderColsSome(rs, anycols, readers)
+	}
+	cols := ToIntSlice(anycols)
 	fmt.Printf("ColumnsSome: putting rs %v into GetMultiple Cols, with cols %v and reader indices %v\n", rs, cols, readers)
-	// if !ok {
-	// 	return nil, fmt.Errorf("wrong argument %v to Columns", args)
-	// }
 	return GetMultipleColsSome(rs, cols, readers), nil
 }
 
@@ -104,3 +104,54 @@ func TestHicPairCols(t *testing.T) {
 		t.Errorf("b2.String() %v != hicout %v", b2.String(), hicout)
 	}
 }
+
+var hicnamedout = `2L	-900	100	4
+2L	-800	200	10
+2L	-700	300	21
+2L	-600	400	33
+2L	-500	500	41
+2L	-400	600	43
+2L	-300	700	55
+`
+
+func TestNamedColumns(t *testing.T) {
+	r := strings.NewReader(hictxt)
+	frs, err := Columns([]io.Reader{r}, []any{"chrom", "start", "end", "hits"})
+	if err != nil {
+		panic(err)
+	}
+
+	var b strings.Builder
+	io.Copy(&b, frs[0])
+	if b.String() != hicnamedout {
+		t.Errorf("b.String() %v != hicnamedout %v", b.String(), hicnamedout)
+	}
+}
+
+func TestNamedColumnsMissing(t *testing.T) {
+	r := strings.NewReader(hictxt)
+	_, err := Columns([]io.Reader{r}, []any{"chrom", "start", "end", "nonexistent"})
+	if err == nil {
+		t.Errorf("missing column did not produce an error")
+	}
+}
+
+func TestPresetColumnsSome(t *testing.T) {
+	presets := map[string][]any{"hic_pair": []any{"chrom", "start", "end", "hits"}}
+	r1 := strings.NewReader(hictxt)
+	r2 := strings.NewReader(intxt)
+
+	frs, err := PresetColumnsSome([]io.Reader{r1, r2}, []any{"hic_pair", []any{0.0}}, presets)
+	if err != nil {
+		panic(err)
+	}
+
+	var b1 strings.Builder
+	io.Copy(&b1, frs[0])
+	if b1.String() != hicnamedout {
+		t.Errorf("b1.String() %v != hicnamedout %v", b1.String(), hicnamedout)
+	}
+	if frs[1] != io.Reader(r2) {
+		t.Errorf("frs[1] was modified")
+	}
+}
@@ -2,6 +2,7 @@ package covplots
 
 import (
 	"bufio"
+	"strings"
 	"io"
 	"fmt"
 )
@@ -39,3 +40,142 @@ func StripHeaderSome(rs []io.Reader, args any) ([]io.Reader, error) {
 	}
 	return out, nil
 }
+
+// Check whether any column specifier is a header name rather than an index
+func HasNamedCols(specs []any) bool {
+	for _, spec := range specs {
+		if _, ok := spec.(string); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the first line of r as a tab-separated header. The returned reader
+// contains the rest of the stream.
+func ReadHeader(r io.Reader) ([]string, io.Reader, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("ReadHeader: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, nil, fmt.Errorf("ReadHeader: missing header line")
+	}
+	line = strings.TrimPrefix(line, "#")
+	return strings.Split(line, "\t"), br, nil
+}
+
+// Convert column specifiers (0-based indices or header names) to indices.
+// Columns that are not in the header are an error.
+func ResolveCols(header []string, specs []any) ([]int, error) {
+	idx := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := idx[name]; !ok {
+			idx[name] = i
+		}
+	}
+
+	var cols []int
+	for _, spec := range specs {
+		switch s := spec.(type) {
+		case string:
+			col, ok := idx[s]
+			if !ok {
+				return nil, fmt.Errorf("ResolveCols: column %q not in header %v", s, header)
+			}
+			cols = append(cols, col)
+		case float64:
+			if int(s) < 0 || int(s) >= len(header) {
+				return nil, fmt.Errorf("ResolveCols: column %v out of range for header %v", s, header)
+			}
+			cols = append(cols, int(s))
+		case int:
+			if s < 0 || s >= len(header) {
+				return nil, fmt.Errorf("ResolveCols: column %v out of range for header %v", s, header)
+			}
+			cols = append(cols, s)
+		default:
+			return nil, fmt.Errorf("ResolveCols: column specifier %v is not a name or index", spec)
+		}
+	}
+	return cols, nil
+}
+
+// Consume the header of r and extract the columns named in specs. The output has no header.
+func HeaderCols(r io.Reader, specs []any) (io.Reader, error) {
+	header, rest, err := ReadHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("HeaderCols: %w", err)
+	}
+	cols, err := ResolveCols(header, specs)
+	if err != nil {
+		return nil, fmt.Errorf("HeaderCols: %w", err)
+	}
+	return GetCols(rest, cols), nil
+}
+
+func GetMultipleHeaderCols(rs []io.Reader, specs []any) ([]io.Reader, error) {
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		outr, err := HeaderCols(r, specs)
+		if err != nil {
+			return nil, fmt.Errorf("GetMultipleHeaderCols: reader %v: %w", i, err)
+		}
+		out[i] = outr
+	}
+	return out, nil
+}
+
+func GetMultipleHeaderColsSome(rs []io.Reader, specs []any, rs_to_subset []int) ([]io.Reader, error) {
+	out := make([]io.Reader, len(rs))
+	copy(out, rs)
+	for _, ridx := range rs_to_subset {
+		outr, err := HeaderCols(rs[ridx], specs)
+		if err != nil {
+			return nil, fmt.Errorf("GetMultipleHeaderColsSome: reader %v: %w", ridx, err)
+		}
+		out[ridx] = outr
+	}
+	return out, nil
+}
+
+// Look up a named set of columns in the config's column presets
+func GetColumnPreset(name string, presets map[string][]any) ([]any, error) {
+	specs, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("GetColumnPreset: no column preset named %q", name)
+	}
+	return specs, nil
+}
+
+// Extract the columns of a config-defined preset from every reader. Args is the preset name.
+func PresetColumns(rs []io.Reader, args any, presets map[string][]any) ([]io.Reader, error) {
+	name, ok := args.(string)
+	if !ok {
+		return nil, fmt.Errorf("PresetColumns: args %v not a string", args)
+	}
+	specs, err := GetColumnPreset(name, presets)
+	if err != nil {
+		return nil, fmt.Errorf("PresetColumns: %w", err)
+	}
+	return Columns(rs, specs)
+}
+
+// Like PresetColumns, but only for the readers indexed in args, which has the form [name, [indices]].
+func PresetColumnsSome(rs []io.Reader, args any, presets map[string][]any) ([]io.Reader, error) {
+	argsl, ok := args.([]any)
+	if !ok || len(argsl) != 2 {
+		return nil, fmt.Errorf("PresetColumnsSome: args %v not of form [name, [indices]]", args)
+	}
+	name, ok := argsl[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("PresetColumnsSome: preset name %v not a string", argsl[0])
+	}
+	specs, err := GetColumnPreset(name, presets)
+	if err != nil {
+		return nil, fmt.Errorf("PresetColumnsSome: %w", err)
+	}
+	return ColumnsSome(rs, []any{specs, argsl[1]})
+}
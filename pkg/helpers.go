@@ -273,9 +273,15 @@ func (f *Filterer) Read(out []byte) (n int, err error) {
 	return n, err
 }
 
+// Filter with the default chromosome naming scheme
 func Filter(r io.Reader, chr string, start, end int) (*Filterer, error) {
-	re, err := regexp.Compile("^" + chr + "_")
-	if err != nil {
+	return FilterNamed(r, ChrNaming{}, chr, start, end)
+}
+
+// Keep only lines whose chromosome (without its parent) is exactly chr and
+// whose span overlaps start to end. Start or end of -1 is unbounded.
+func FilterNamed(r io.Reader, naming ChrNaming, chr string, start, end int) (*Filterer, error) {
+	if err := naming.Validate(); err != nil {
 		return nil, err
 	}
 
@@ -290,7 +296,7 @@ func Filter(r io.Reader, chr string, start, end int) (*Filterer, error) {
 		if len(line) < 3 {
 			return false
 		}
-		if !re.MatchString(line[0]) {
+		if naming.Chr(line[0]) != chr {
 			return false
 		}
 
@@ -319,29 +325,43 @@ func Filter(r io.Reader, chr string, start, end int) (*Filterer, error) {
 	return f, nil
 }
 
+// ReChrNamed with the default chromosome naming scheme
 func ReChr(rs []io.Reader, abiolines any) ([]io.Reader, error) {
+	return ReChrNamed(rs, abiolines, ChrNaming{})
+}
+
+func ReChrNamed(rs []io.Reader, abiolines any, naming ChrNaming) ([]io.Reader, error) {
 	biolines, ok := abiolines.([]string)
 	if !ok {
 		return nil, fmt.Errorf("abiolines %v not of type []string", abiolines)
 	}
 	var outs []io.Reader
 	for _, r := range rs {
-		outs = append(outs, ReChrSingle(r, biolines))
+		outs = append(outs, ReChrSingleNamed(r, biolines, naming))
 	}
 	return outs, nil
 }
 
+// ReChrSingleNamed with the default chromosome naming scheme
 func ReChrSingle(r io.Reader, biolines []string) (io.Reader) {
-	chrre := regexp.MustCompile(`^[^	]*`)
+	return ReChrSingleNamed(r, biolines, ChrNaming{})
+}
+
+// Add each of biolines, in order, as a parent name to the chromosome of
+// every line
+func ReChrSingleNamed(r io.Reader, biolines []string, naming ChrNaming) (io.Reader) {
 	s := bufio.NewScanner(r)
 	s.Buffer([]byte{}, 1e12)
 	rout := PipeWrite(func(w io.Writer) {
 		for s.Scan() {
-			out := s.Text()
+			chr, rest, found := strings.Cut(s.Text(), "\t")
 			for _, l := range biolines {
-				out = chrre.ReplaceAllString(out, `$0` + "_" + l)
+				chr = naming.Join(chr, l)
+			}
+			if found {
+				chr += "\t" + rest
 			}
-			fmt.Println(w, out)
+			fmt.Fprintln(w, chr)
 		}
 	})
 	return rout
@@ -0,0 +1,194 @@
+package covplots
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Regions of a BED file to mark in every panel of a plot. Features of at most
+// one base pair are drawn as vertical lines, and longer ones as shaded boxes.
+type Highlight struct {
+	Bed string `json:"bed"`
+	// Legend label; without one, the highlight is not in the legend
+	Label string `json:"label"`
+	// Default "#5555dd"
+	Color string `json:"color"`
+	// Opacity of boxes; default 0.3
+	Alpha float64 `json:"alpha"`
+}
+
+func (hl *Highlight) setDefaults() {
+	if hl.Color == "" { hl.Color = "#5555dd" }
+	if hl.Alpha == 0 { hl.Alpha = 0.3 }
+}
+
+func (hl Highlight) Validate() error {
+	h := Handle("Highlight.Validate: %w")
+	if hl.Bed == "" {
+		return h(fmt.Errorf("highlight %q has no bed", hl.Label))
+	}
+	if _, err := ParseColor(hl.Color); hl.Color != "" && err != nil { return h(err) }
+	if hl.Alpha < 0 || hl.Alpha > 1 {
+		return h(fmt.Errorf("alpha %v not between 0 and 1", hl.Alpha))
+	}
+	if strings.ContainsAny(hl.Label, "\t\n") {
+		return h(fmt.Errorf("label %q contains a tab or newline", hl.Label))
+	}
+	return nil
+}
+
+// Reads the features of a highlight's bed file
+type highlightReader func(path string) ([]BedEntry, error)
+
+// Read a highlight's features with read, or straight from the file if read is nil
+func (read highlightReader) read(path string) ([]BedEntry, error) {
+	if read == nil {
+		return ReadBedPath(path)
+	}
+	return read(path)
+}
+
+// A reader that reads each highlight bed file once per config, and renames
+// its chromosomes as the config's data are renamed: to canonical names with
+// the config's aliases, then without parents with noparent. Without data,
+// every call reads the files again.
+func configHighlightReader(cfg UltimateConfig, d *ConfigData) highlightReader {
+	return func(path string) ([]BedEntry, error) {
+		return configCached(d, "highlight bed " + path, func() ([]BedEntry, error) {
+			h := Handle("configHighlightReader: %w")
+			bed, err := ReadBedPath(path)
+			if err != nil { return nil, h(err) }
+			var aliases ChrAliases
+			if d != nil {
+				aliases = d.Aliases
+			} else if aliases, err = GetConfigAliases(cfg); err != nil {
+				return nil, h(err)
+			}
+			if aliases != nil {
+				bed = AliasBedEntries(bed, aliases, cfg.Naming)
+			}
+			if cfg.NoParent {
+				for i := range bed {
+					bed[i].Chr = cfg.Naming.Chr(bed[i].Chr)
+				}
+			}
+			return bed, nil
+		})
+	}
+}
+
+// Whether a BED entry is drawn as a line rather than a box
+func isPointFeature(b BedEntry) bool {
+	return b.End - b.Start <= 1
+}
+
+// One marked region of a plot, read from a Highlight or from plotspec boxes
+type specMark struct {
+	BedEntry
+	style Style
+	line bool
+}
+
+// Read the regions of highlights with read, with their styles, and legend
+// keys for the labelled ones
+func readHighlightMarks(hls []Highlight, read highlightReader) ([]specMark, []legendKey, error) {
+	h := Handle("readHighlightMarks: %w")
+	var marks []specMark
+	var keys []legendKey
+	for _, hl := range hls {
+		if err := hl.Validate(); err != nil { return nil, nil, h(err) }
+		hl.setDefaults()
+		col, err := ParseColor(hl.Color)
+		if err != nil { return nil, nil, h(err) }
+		bed, err := read.read(hl.Bed)
+		if err != nil { return nil, nil, h(err) }
+		boxes := false
+		for _, b := range bed {
+			m := specMark{BedEntry: b, line: isPointFeature(b)}
+			if m.line {
+				m.style = Style{Stroke: col, StrokeWidth: 1.5}
+			} else {
+				m.style = Style{Fill: col.Fade(hl.Alpha)}
+				boxes = true
+			}
+			marks = append(marks, m)
+		}
+		if hl.Label != "" {
+			k := legendKey{label: hl.Label, style: seriesStyle{color: col, geom: "line", size: 1.5}}
+			if boxes {
+				k.style = seriesStyle{color: col.Fade(hl.Alpha), geom: "bar"}
+			}
+			keys = append(keys, k)
+		}
+	}
+	return marks, keys, nil
+}
+
+// The highlights of a window's plfmt data, with the same chromosome offsets
+// as the data, are kept in this file next to it
+func HighlightsPath(outpre string) string {
+	return outpre + "_highlights_plfmt.bed"
+}
+
+// Write the features of highlights that fall in a window, clipped to it, with
+// LABEL, COLOR, and ALPHA columns, placed by the window's Plformatter like its
+// plfmt data. Chromosomes are renamed as the config's data are. Without
+// highlights, remove the file left by an earlier run.
+func WriteHighlights(outpre string, hls []Highlight, margs MultiplotPlotFuncArgs) error {
+	h := Handle("WriteHighlights: %w")
+	path := HighlightsPath(outpre)
+	if len(hls) == 0 || margs.Plformatter == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) { return h(err) }
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil { return h(err) }
+	bw := bufio.NewWriter(f)
+	pf := margs.Plformatter
+	read := configHighlightReader(margs.Cfg, margs.Data)
+	for _, hl := range hls {
+		if err = hl.Validate(); err != nil { break }
+		hl.setDefaults()
+		var bed []BedEntry
+		if bed, err = read(hl.Bed); err != nil { break }
+		for _, b := range bed {
+			off, ok := pf.Chroffs[b.Chr]
+			if !ok {
+				continue
+			}
+			if pf.UseManualChrs {
+				if _, ok := pf.Chrset[b.Chr]; !ok {
+					continue
+				}
+			}
+			if !margs.Fullchr {
+				if b.End <= int64(margs.Start) || b.Start >= int64(margs.End) {
+					continue
+				}
+				if b.Start < int64(margs.Start) { b.Start = int64(margs.Start) }
+				if b.End > int64(margs.End) { b.End = int64(margs.End) }
+			}
+			fmt.Fprintf(bw, "%s\t%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\n", b.Chr, b.Start, b.End,
+				naString(hl.Label), hl.Color, strconv.FormatFloat(hl.Alpha, 'g', -1, 64),
+				pf.Chrnums[b.Chr], int64(off) + b.Start, int64(off) + b.End)
+		}
+	}
+	if err == nil { err = bw.Flush() }
+	if e := f.Close(); err == nil { err = e }
+	if err != nil { return h(err) }
+	return nil
+}
+
+// Highlights from a and b, with a's first
+func appendHighlights(a, b []Highlight) []Highlight {
+	if len(b) == 0 {
+		return a
+	}
+	return append(append([]Highlight(nil), a...), b...)
+}
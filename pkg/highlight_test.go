@@ -0,0 +1,141 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHighlights(t *testing.T) {
+	dir := t.TempDir()
+	outpre := filepath.Join(dir, "out")
+	bed := filepath.Join(dir, "peaks.bed")
+	if e := os.WriteFile(bed, []byte("2L\t120\t150\n2L\t160\t161\n2L\t300\t400\nX\t100\t110\n"), 0644); e != nil { panic(e) }
+
+	margs := MultiplotPlotFuncArgs{
+		Plformatter: &Plformatter{Chroffs: map[string]int{"2L": -100}, Chrnums: map[string]int{"2L": 0}},
+		Chr: "2L",
+		Start: 100,
+		End: 200,
+	}
+	hls := []Highlight{{Bed: bed, Label: "peaks"}, {Bed: bed, Color: "#ff0000", Alpha: 0.5}}
+	if e := WriteHighlights(outpre, hls, margs); e != nil { panic(e) }
+	out, err := os.ReadFile(HighlightsPath(outpre))
+	if err != nil {
+		panic(err)
+	}
+	expect := "2L\t120\t150\tpeaks\t#5555dd\t0.3\t0\t20\t50\n" +
+		"2L\t160\t161\tpeaks\t#5555dd\t0.3\t0\t60\t61\n" +
+		"2L\t120\t150\tNA\t#ff0000\t0.5\t0\t20\t50\n" +
+		"2L\t160\t161\tNA\t#ff0000\t0.5\t0\t60\t61\n"
+	if string(out) != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+
+	// Without highlights, the file of an earlier run is removed
+	if e := WriteHighlights(outpre, nil, margs); e != nil { panic(e) }
+	if _, err := os.Stat(HighlightsPath(outpre)); err == nil {
+		t.Errorf("highlights file not removed")
+	}
+
+	if e := WriteHighlights(outpre, []Highlight{{Bed: bed, Alpha: 2}}, margs); e == nil {
+		t.Errorf("no error for alpha %v", 2)
+	}
+}
+
+func TestWriteHighlightsAliased(t *testing.T) {
+	dir := t.TempDir()
+	outpre := filepath.Join(dir, "out")
+	bed := filepath.Join(dir, "peaks.bed")
+	if e := os.WriteFile(bed, []byte("chr2L_ixw\t90\t150\nchr2L_ixw\t190\t260\n2R_ixw\t120\t130\n"), 0644); e != nil { panic(e) }
+
+	var margs MultiplotPlotFuncArgs
+	margs.Plformatter = &Plformatter{Chroffs: map[string]int{"2L": -100}, Chrnums: map[string]int{"2L": 0}}
+	margs.Chr, margs.Start, margs.End = "2L", 100, 200
+	margs.Cfg.NoParent = true
+	margs.Data = &ConfigData{Aliases: ChrAliases{"chr2L": "2L"}}
+	hls := []Highlight{{Bed: bed, Label: "peaks"}}
+	if e := WriteHighlights(outpre, hls, margs); e != nil { panic(e) }
+	expect := "2L\t100\t150\tpeaks\t#5555dd\t0.3\t0\t0\t50\n" +
+		"2L\t190\t200\tpeaks\t#5555dd\t0.3\t0\t90\t100\n"
+	out, err := os.ReadFile(HighlightsPath(outpre))
+	if err != nil {
+		panic(err)
+	}
+	if string(out) != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+
+	// Later windows use the features read for the first one
+	if e := os.Remove(bed); e != nil { panic(e) }
+	if e := WriteHighlights(outpre, hls, margs); e != nil {
+		t.Errorf("highlights read again: %v", e)
+	}
+}
+
+func TestValidateHighlights(t *testing.T) {
+	var cfg UltimateConfig
+	cfg.Outpre = "out"
+	cfg.Highlights = []Highlight{{Bed: "peaks.bed"}}
+	for _, plotfunc := range []string{"", "plot_multi_ribbon", "plot_multi_facetname_scales", "native_multi", "html_multi_facet", "plotspec"} {
+		cfg.Plotfunc = plotfunc
+		if e := ValidateHighlights(cfg); e != nil {
+			t.Errorf("error for highlights with %q: %v", plotfunc, e)
+		}
+	}
+
+	cfg.Plotfunc = "plot_sawamura"
+	cfg.Highlights = append(cfg.Highlights, Highlight{Bed: "snps.bed", Alpha: 2})
+	err := ValidateConfig(cfg)
+	if err == nil {
+		t.Errorf("no error for invalid highlights")
+		return
+	}
+	for _, want := range []string{`"plot_sawamura" does not draw highlights`, "alpha 2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestPlotNativeHighlights(t *testing.T) {
+	dir := t.TempDir()
+	outpre := filepath.Join(dir, "out")
+	plfmt := "2L\t100\t110\t1\tixw\t0\t0\t10\n" +
+		"2L\t150\t160\t2\tixw\t0\t50\t60\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+	regions := filepath.Join(dir, "regions.bed")
+	if e := os.WriteFile(regions, []byte("2L\t120\t140\n"), 0644); e != nil { panic(e) }
+	snps := filepath.Join(dir, "snps.bed")
+	if e := os.WriteFile(snps, []byte("2L\t130\t131\n"), 0644); e != nil { panic(e) }
+
+	var margs MultiplotPlotFuncArgs
+	margs.Cfg.Highlights = []Highlight{
+		{Bed: regions, Label: "Inversion", Color: "#00ff00", Alpha: 0.5},
+		{Bed: snps, Label: "SNP", Color: "#ff0000"},
+	}
+	if e := PlotNativeAny(PlfmtPlain)(outpre, []float64{0, 5}, nil, margs); e != nil { panic(e) }
+	svg, err := os.ReadFile(outpre + "_plotted.svg")
+	if err != nil {
+		panic(err)
+	}
+	s := string(svg)
+	for _, want := range []string{`fill="#00ff00" fill-opacity="0.502"`, `stroke="#ff0000"`, ">Highlights<", ">Inversion<", ">SNP<"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("svg does not contain %q", want)
+		}
+	}
+
+	if e := PlotHTMLAny(PlfmtPlain)(outpre, nil, nil, margs); e != nil { panic(e) }
+	page, err := os.ReadFile(outpre + "_plotted.html")
+	if err != nil {
+		panic(err)
+	}
+	for _, want := range []string{`"label":"Inversion","color":"#00ff00","alpha":0.5,"geom":"bar","x0":[20],"x1":[40]`,
+		`"label":"SNP","color":"#ff0000","alpha":0.3,"geom":"line","x0":[30],"x1":[31]`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("html does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,740 @@
+package covplots
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A number that is written as null in JSON if it is NaN or infinite
+type jsonNum float64
+
+func (n jsonNum) MarshalJSON() ([]byte, error) {
+	f := float64(n)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+}
+
+// The points of one input set in one facet, as parallel columns
+type htmlSeries struct {
+	Name string `json:"name"`
+	Facet string `json:"facet"`
+	Color string `json:"color"`
+	// From the set's style; empty for the plot's defaults
+	Label string `json:"label,omitempty"`
+	Geom string `json:"geom,omitempty"`
+	Dash []float64 `json:"dash,omitempty"`
+	Alpha float64 `json:"alpha,omitempty"`
+	Width float64 `json:"width,omitempty"`
+	// Index into htmlPlotData.Chrs
+	Chr []int `json:"chr"`
+	Bp0 []int64 `json:"bp0"`
+	Bp1 []int64 `json:"bp1"`
+	// Genome-wide positions
+	X0 []jsonNum `json:"x0"`
+	X1 []jsonNum `json:"x1"`
+	Val []jsonNum `json:"val"`
+	Lower []jsonNum `json:"lower,omitempty"`
+	Upper []jsonNum `json:"upper,omitempty"`
+}
+
+// The features of one highlight, at genome-wide positions. Features of at
+// most one base pair are drawn as lines.
+type htmlHighlight struct {
+	Label string `json:"label,omitempty"`
+	Color string `json:"color"`
+	Alpha float64 `json:"alpha"`
+	// The legend key: "bar" if there are boxes, else "line"
+	Geom string `json:"geom"`
+	X0 []float64 `json:"x0"`
+	X1 []float64 `json:"x1"`
+}
+
+type htmlChr struct {
+	Name string `json:"name"`
+	// Genome-wide position of base pair 0
+	Shift float64 `json:"shift"`
+	// Genome-wide extent of the data
+	X0 float64 `json:"x0"`
+	X1 float64 `json:"x1"`
+}
+
+// Everything the HTML page needs to draw a plot
+type htmlPlotData struct {
+	Title string `json:"title"`
+	XLabel string `json:"xlabel"`
+	YLabel string `json:"ylabel"`
+	LegendTitle string `json:"legendtitle"`
+	Geom string `json:"geom"`
+	Size float64 `json:"size"`
+	FontSize float64 `json:"fontsize"`
+	Height float64 `json:"height"`
+	Ylim []float64 `json:"ylim"`
+	Names []string `json:"names"`
+	Facets []string `json:"facets"`
+	Chrs []htmlChr `json:"chrs"`
+	Series []htmlSeries `json:"series"`
+	Highlights []htmlHighlight `json:"highlights"`
+}
+
+func makeHTMLPlotData(pts []PlotPoint, ylim []float64, layout PlfmtLayout, args NativePlotArgs) (htmlPlotData, error) {
+	args.setDefaults()
+	d := htmlPlotData{
+		Title: args.Title,
+		XLabel: args.XLabel,
+		YLabel: args.YLabel,
+		LegendTitle: args.LegendTitle,
+		Geom: args.Geom,
+		Size: args.Size,
+		FontSize: args.FontSize,
+		Height: args.Height,
+	}
+	if len(ylim) == 2 && ylim[0] != ylim[1] {
+		d.Ylim = ylim
+	}
+
+	sorted := args.Order != "input"
+	var allNames, allFacets []string
+	for _, p := range pts {
+		allNames = append(allNames, p.Name)
+		allFacets = append(allFacets, p.Facet)
+	}
+	d.Names = uniqueStrings(allNames, sorted)
+	d.Facets = []string{""}
+	if layout == PlfmtFacet {
+		d.Facets = uniqueStrings(allFacets, sorted)
+		if len(args.Facets) > 0 {
+			d.Facets = facetsScale(args.Facets).Breaks
+		}
+	}
+	colors, err := nameColors(d.Names, args.Colors)
+	if err != nil {
+		return d, fmt.Errorf("makeHTMLPlotData: %w", err)
+	}
+	newSeries := func(p PlotPoint) (htmlSeries, error) {
+		s := htmlSeries{Name: p.Name, Facet: p.Facet, Color: colors[p.Name].Hex()}
+		st, ok := args.Styles[p.Name]
+		if !ok {
+			return s, nil
+		}
+		if err := st.Validate(); err != nil {
+			return s, fmt.Errorf("style of %q: %w", p.Name, err)
+		}
+		if st.Color != "" {
+			c, _ := ParseColor(st.Color)
+			s.Color = c.Hex()
+		}
+		if st.Linetype != "" {
+			dash, _ := ParseLinetype(st.Linetype)
+			s.Dash = dash
+		}
+		s.Label, s.Geom, s.Alpha, s.Width = st.Label, st.Geom, st.Alpha, st.Width
+		return s, nil
+	}
+
+	chrIdx := map[string]int{}
+	type key struct { name, facet string }
+	seriesIdx := map[key]int{}
+	for _, p := range pts {
+		ci, ok := chrIdx[p.Chr]
+		if !ok {
+			ci = len(d.Chrs)
+			chrIdx[p.Chr] = ci
+			d.Chrs = append(d.Chrs, htmlChr{Name: p.Chr, Shift: p.Start - float64(p.BpStart), X0: p.Start, X1: p.End})
+		}
+		d.Chrs[ci].X0 = math.Min(d.Chrs[ci].X0, p.Start)
+		d.Chrs[ci].X1 = math.Max(d.Chrs[ci].X1, p.End)
+
+		k := key{p.Name, p.Facet}
+		si, ok := seriesIdx[k]
+		if !ok {
+			si = len(d.Series)
+			seriesIdx[k] = si
+			s, err := newSeries(p)
+			if err != nil {
+				return d, fmt.Errorf("makeHTMLPlotData: %w", err)
+			}
+			d.Series = append(d.Series, s)
+		}
+		s := &d.Series[si]
+		s.Chr = append(s.Chr, ci)
+		s.Bp0 = append(s.Bp0, p.BpStart)
+		s.Bp1 = append(s.Bp1, p.BpEnd)
+		s.X0 = append(s.X0, jsonNum(p.Start))
+		s.X1 = append(s.X1, jsonNum(p.End))
+		s.Val = append(s.Val, jsonNum(p.Val))
+		if layout == PlfmtRibbon {
+			s.Lower = append(s.Lower, jsonNum(p.Lower))
+			s.Upper = append(s.Upper, jsonNum(p.Upper))
+		}
+	}
+
+	d.Highlights = []htmlHighlight{}
+	for _, hl := range args.Highlights {
+		if err := hl.Validate(); err != nil {
+			return d, fmt.Errorf("makeHTMLPlotData: %w", err)
+		}
+		hl.setDefaults()
+		col, err := ParseColor(hl.Color)
+		if err != nil {
+			return d, fmt.Errorf("makeHTMLPlotData: %w", err)
+		}
+		bed, err := args.readBed.read(hl.Bed)
+		if err != nil {
+			return d, fmt.Errorf("makeHTMLPlotData: %w", err)
+		}
+		m := htmlHighlight{Label: hl.Label, Color: col.Hex(), Alpha: hl.Alpha, Geom: "line", X0: []float64{}, X1: []float64{}}
+		for _, b := range bed {
+			ci, ok := chrIdx[b.Chr]
+			if !ok {
+				continue
+			}
+			shift := d.Chrs[ci].Shift
+			m.X0 = append(m.X0, float64(b.Start) + shift)
+			m.X1 = append(m.X1, float64(b.End) + shift)
+			if !isPointFeature(b) {
+				m.Geom = "bar"
+			}
+		}
+		d.Highlights = append(d.Highlights, m)
+	}
+	return d, nil
+}
+
+// Write a self-contained interactive HTML page of the plot
+func WriteHTMLPlot(w io.Writer, pts []PlotPoint, ylim []float64, layout PlfmtLayout, args NativePlotArgs) error {
+	h := Handle("WriteHTMLPlot: %w")
+	d, err := makeHTMLPlotData(pts, ylim, layout, args)
+	if err != nil { return h(err) }
+	// json.Marshal escapes <, >, and &, so the data cannot end the script element
+	data, err := json.Marshal(d)
+	if err != nil { return h(err) }
+
+	title := d.Title
+	if title == "" {
+		title = "Coverage plot"
+	}
+	page := strings.NewReplacer("{{TITLE}}", html.EscapeString(title), "{{DATA}}", string(data)).Replace(htmlPlotTemplate)
+	if _, err = io.WriteString(w, page); err != nil { return h(err) }
+	return nil
+}
+
+// Read outpre_plfmt.bed and write an interactive plot to outpre_plotted.html
+func PlotHTML(outpre string, ylim []float64, layout PlfmtLayout, args NativePlotArgs) error {
+	h := Handle("PlotHTML: %w")
+
+	pts, err := ReadPlotPointsPath(outpre + "_plfmt.bed", layout)
+	if err != nil { return h(err) }
+	styles, err := ReadPlfmtSetStyles(outpre)
+	if err != nil { return h(err) }
+	args.Styles = mergeSetStyles(args.Styles, styles)
+
+	f, err := os.Create(outpre + "_plotted.html")
+	if err != nil { return h(err) }
+	err = WriteHTMLPlot(f, pts, ylim, layout, args)
+	if e := f.Close(); err == nil { err = e }
+	if err != nil { return h(err) }
+	return nil
+}
+
+// Get an HTML plot function for a plfmt layout. Args must be of type NativePlotArgs.
+func PlotHTMLAny(layout PlfmtLayout) func(outpre string, ylim []float64, args any, margs MultiplotPlotFuncArgs) error {
+	return func(outpre string, ylim []float64, anyargs any, margs MultiplotPlotFuncArgs) error {
+		var args NativePlotArgs
+		if err := UnmarshalJsonOut(anyargs, &args); err != nil {
+			return fmt.Errorf("PlotHTMLAny: %w", err)
+		}
+		if len(args.Facets) == 0 {
+			args.Facets = margs.Cfg.Facets
+		}
+		args.Highlights = appendHighlights(args.Highlights, margs.Cfg.Highlights)
+		args.readBed = configHighlightReader(margs.Cfg, margs.Data)
+		return PlotHTML(outpre, ylim, layout, args)
+	}
+}
+
+const htmlPlotTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{TITLE}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; margin: 8px; }
+#bar { font-size: 12px; color: #444; margin-bottom: 4px; }
+#bar button { font-size: 12px; }
+#wrap { position: relative; }
+#plot { display: block; width: 100%; cursor: crosshair; }
+#tip { position: absolute; display: none; pointer-events: none; background: rgba(255, 255, 255, 0.95);
+	border: 1px solid #888; padding: 4px 6px; font-size: 12px; white-space: pre; }
+</style>
+</head>
+<body>
+<div id="bar">
+<button id="reset">Reset zoom</button>
+<button id="showall">Show all</button>
+Scroll to zoom (hold shift for the y axis), drag to pan, double-click to reset, click the legend to show or hide sets.
+</div>
+<div id="wrap"><canvas id="plot"></canvas><div id="tip"></div></div>
+<script type="application/json" id="plotdata">{{DATA}}</script>
+<script>
+(function() {
+"use strict";
+var D = JSON.parse(document.getElementById("plotdata").textContent);
+var cv = document.getElementById("plot");
+var ctx = cv.getContext("2d");
+var tip = document.getElementById("tip");
+var fs = D.fontsize;
+var font = "Helvetica, Arial, sans-serif";
+var hidden = {};
+var full = fullView();
+var view = copyView(full);
+var L = null;
+var drag = null;
+var pending = false;
+
+function copyView(v) { return {x0: v.x0, x1: v.x1, y0: v.y0, y1: v.y1}; }
+
+function fullView() {
+	var x0 = Infinity, x1 = -Infinity, y0 = Infinity, y1 = -Infinity;
+	function addY(v) { if (v !== null) { y0 = Math.min(y0, v); y1 = Math.max(y1, v); } }
+	D.series.forEach(function(s) {
+		for (var i = 0; i < s.val.length; i++) {
+			x0 = Math.min(x0, s.x0[i]);
+			x1 = Math.max(x1, s.x1[i]);
+			addY(s.val[i]);
+			if (s.lower) { addY(s.lower[i]); addY(s.upper[i]); }
+		}
+	});
+	if (!isFinite(x0)) { x0 = 0; x1 = 1; }
+	if (x0 === x1) { x0 -= 0.5; x1 += 0.5; }
+	var ypad = 0;
+	if (D.ylim) {
+		y0 = D.ylim[0]; y1 = D.ylim[1];
+	} else {
+		if (!isFinite(y0)) { y0 = 0; y1 = 1; }
+		if (y0 === y1) { y0 -= 0.5; y1 += 0.5; }
+		ypad = (y1 - y0) * 0.05;
+	}
+	var xpad = (x1 - x0) * 0.02;
+	return {x0: x0 - xpad, x1: x1 + xpad, y0: y0 - ypad, y1: y1 + ypad};
+}
+
+// Evenly spaced round numbers covering lo to hi
+function ticks(lo, hi, n) {
+	var span = hi - lo;
+	if (!(span > 0)) { return [lo]; }
+	var raw = span / n, mag = Math.pow(10, Math.floor(Math.log10(raw))), step = 10 * mag;
+	[1, 2, 5].some(function(m) { if (m * mag >= raw) { step = m * mag; return true; } return false; });
+	var out = [], first = Math.ceil(lo / step);
+	for (var i = 0; (first + i) * step <= hi + step * 1e-9; i++) {
+		var t = (first + i) * step;
+		out.push(Math.abs(t) < step * 1e-9 ? 0 : t);
+	}
+	out.step = step;
+	return out;
+}
+
+function fmtTick(t, step) {
+	var d = Math.max(0, -Math.floor(Math.log10(step) + 1e-9));
+	return t.toFixed(Math.min(d, 20));
+}
+
+function fmtBp(v) { return Math.round(v).toLocaleString("en-US"); }
+
+function fmtVal(v) { return v === null ? "NA" : String(Number(v.toPrecision(6))); }
+
+function setFont(size) { ctx.font = size + "px " + font; }
+
+function textW(s, size) { setFont(size); return ctx.measureText(s).width; }
+
+function sx(x) { return L.left + (x - view.x0) / (view.x1 - view.x0) * (L.right - L.left); }
+
+function sy(v, p) { return p.bottom - (v - view.y0) / (view.y1 - view.y0) * (p.bottom - p.top); }
+
+// Chromosomes with data in view
+function visibleChrs() {
+	return D.chrs.filter(function(c) { return c.x1 >= view.x0 && c.x0 <= view.x1; });
+}
+
+// Call f(a, b) for each run of points [a, b) that pass ok and share a chromosome
+function runs(s, ok, f) {
+	var a = -1, n = s.val.length;
+	for (var i = 0; i < n; i++) {
+		if (!ok(i)) {
+			if (a >= 0) { f(a, i); }
+			a = -1;
+		} else if (a < 0) {
+			a = i;
+		} else if (s.chr[i] !== s.chr[a]) {
+			f(a, i);
+			a = i;
+		}
+	}
+	if (a >= 0) { f(a, n); }
+}
+
+function mid(s, i) { return (s.x0[i] + s.x1[i]) / 2; }
+
+function drawHighlight(m, p) {
+	for (var i = 0; i < m.x0.length; i++) {
+		if (m.x1[i] < view.x0 || m.x0[i] > view.x1) { continue; }
+		if (m.x1[i] - m.x0[i] <= 1) {
+			var x = sx((m.x0[i] + m.x1[i]) / 2);
+			ctx.strokeStyle = m.color;
+			ctx.lineWidth = 1.5;
+			ctx.beginPath();
+			ctx.moveTo(x, p.top);
+			ctx.lineTo(x, p.bottom);
+			ctx.stroke();
+			continue;
+		}
+		ctx.globalAlpha = m.alpha;
+		ctx.fillStyle = m.color;
+		ctx.fillRect(sx(m.x0[i]), p.top, sx(m.x1[i]) - sx(m.x0[i]), p.bottom - p.top);
+		ctx.globalAlpha = 1;
+	}
+}
+
+function drawSeries(s, p) {
+	var geom = s.geom || D.geom, size = s.width || D.size, alpha = s.alpha || 1;
+	if (s.lower) {
+		ctx.fillStyle = s.color;
+		ctx.globalAlpha = 0.3;
+		runs(s, function(i) { return s.lower[i] !== null && s.upper[i] !== null; }, function(a, b) {
+			ctx.beginPath();
+			for (var i = a; i < b; i++) { ctx.lineTo(sx(mid(s, i)), sy(s.upper[i], p)); }
+			for (var i = b - 1; i >= a; i--) { ctx.lineTo(sx(mid(s, i)), sy(s.lower[i], p)); }
+			ctx.closePath();
+			ctx.fill();
+		});
+		ctx.globalAlpha = 1;
+	}
+	ctx.globalAlpha = alpha;
+	ctx.fillStyle = s.color;
+	// Bars and areas start from zero, or the nearest edge of the view
+	var base = sy(Math.min(Math.max(0, view.y0), view.y1), p);
+	if (geom === "line") {
+		ctx.strokeStyle = s.color;
+		ctx.lineWidth = size;
+		ctx.lineJoin = "round";
+		ctx.setLineDash((s.dash || []).map(function(d) { return d * Math.max(size, 1); }));
+		runs(s, function(i) { return s.val[i] !== null; }, function(a, b) {
+			ctx.beginPath();
+			for (var i = a; i < b; i++) { ctx.lineTo(sx(mid(s, i)), sy(s.val[i], p)); }
+			ctx.stroke();
+		});
+		ctx.setLineDash([]);
+	} else if (geom === "area") {
+		runs(s, function(i) { return s.val[i] !== null; }, function(a, b) {
+			ctx.beginPath();
+			for (var i = a; i < b; i++) { ctx.lineTo(sx(mid(s, i)), sy(s.val[i], p)); }
+			ctx.lineTo(sx(mid(s, b - 1)), base);
+			ctx.lineTo(sx(mid(s, a)), base);
+			ctx.closePath();
+			ctx.fill();
+		});
+	} else {
+		for (var i = 0; i < s.val.length; i++) {
+			if (s.val[i] === null || s.x1[i] < view.x0 || s.x0[i] > view.x1) { continue; }
+			var x = sx(mid(s, i)), y = sy(s.val[i], p);
+			if (geom === "bar") {
+				var x0 = sx(s.x0[i]), x1 = sx(s.x1[i]);
+				ctx.fillRect(x0, Math.min(y, base), Math.max(x1 - x0, 1), Math.abs(y - base));
+				continue;
+			}
+			ctx.beginPath();
+			ctx.arc(x, y, size, 0, 2 * Math.PI);
+			ctx.fill();
+		}
+	}
+	ctx.globalAlpha = 1;
+}
+
+// The series that stands for a name in the legend
+function nameSeries() {
+	var out = {};
+	D.series.forEach(function(s) { if (!out[s.name]) { out[s.name] = s; } });
+	return out;
+}
+
+function label(s) { return s.label || s.name; }
+
+function drawKey(s, x, y, faded) {
+	var geom = s.geom || D.geom;
+	ctx.fillStyle = s.color;
+	ctx.strokeStyle = s.color;
+	ctx.globalAlpha = faded ? 0.3 : (s.alpha || 1);
+	ctx.beginPath();
+	if (geom === "line") {
+		ctx.lineWidth = Math.max(s.width || D.size, 1.5);
+		ctx.setLineDash((s.dash || []).map(function(d) { return d * Math.max(s.width || D.size, 1); }));
+		ctx.moveTo(x - fs * 0.1, y);
+		ctx.lineTo(x + fs * 1.1, y);
+		ctx.stroke();
+		ctx.setLineDash([]);
+	} else if (geom === "bar" || geom === "area") {
+		ctx.fillRect(x + fs * 0.1, y - fs * 0.4, fs * 0.8, fs * 0.8);
+	} else {
+		ctx.arc(x + fs * 0.5, y, fs * 0.35, 0, 2 * Math.PI);
+		ctx.fill();
+	}
+	ctx.globalAlpha = 1;
+}
+
+function draw() {
+	pending = false;
+	var dpr = window.devicePixelRatio || 1;
+	var w = cv.clientWidth, h = D.height;
+	cv.style.height = h + "px";
+	if (cv.width !== Math.round(w * dpr) || cv.height !== Math.round(h * dpr)) {
+		cv.width = Math.round(w * dpr);
+		cv.height = Math.round(h * dpr);
+	}
+	ctx.setTransform(dpr, 0, 0, dpr, 0, 0);
+
+	var yt = ticks(view.y0, view.y1, 5);
+	var byName = nameSeries();
+	var legendW = textW(D.legendtitle, fs);
+	D.names.forEach(function(n) { legendW = Math.max(legendW, textW(label(byName[n]), fs) + fs * 1.5); });
+	var keys = D.highlights.filter(function(m) { return m.label; });
+	keys.forEach(function(m) { legendW = Math.max(legendW, textW(m.label, fs) + fs * 1.5); });
+	var tickW = 0;
+	yt.forEach(function(t) { tickW = Math.max(tickW, textW(fmtTick(t, yt.step), fs * 0.85)); });
+	var faceted = D.facets.length > 1 || D.facets[0] !== "";
+	L = {top: fs + (D.title ? fs * 1.8 : 0), left: fs * 2.2 + tickW + 8, right: w - legendW - fs * 2, bottom: h - fs * 3.4};
+	if (faceted) { L.right -= fs * 1.6; }
+	var gap = fs * 0.5, n = D.facets.length;
+	var ph = (L.bottom - L.top - gap * (n - 1)) / n;
+	L.panels = D.facets.map(function(f, i) { return {facet: f, top: L.top + i * (ph + gap), bottom: L.top + i * (ph + gap) + ph}; });
+
+	ctx.fillStyle = "#ffffff";
+	ctx.fillRect(0, 0, w, h);
+	ctx.fillStyle = "#000000";
+	ctx.textBaseline = "alphabetic";
+	if (D.title) {
+		setFont(fs * 1.2);
+		ctx.textAlign = "center";
+		ctx.fillText(D.title, (L.left + L.right) / 2, fs * 1.6);
+	}
+
+	// Genome-wide chromosome labels, or base pair ticks when zoomed into one chromosome
+	var chrs = visibleChrs(), xt = [], xlabel = D.xlabel;
+	if (chrs.length === 1) {
+		var c = chrs[0], bt = ticks(view.x0 - c.shift, view.x1 - c.shift, 6);
+		bt.forEach(function(t) { xt.push({x: t + c.shift, label: fmtBp(t)}); });
+		xlabel = c.name + " position (bp)";
+	} else {
+		chrs.forEach(function(c) {
+			xt.push({x: (Math.max(c.x0, view.x0) + Math.min(c.x1, view.x1)) / 2, label: c.name});
+		});
+	}
+
+	L.panels.forEach(function(p) {
+		ctx.strokeStyle = "#ebebeb";
+		ctx.lineWidth = 1;
+		ctx.beginPath();
+		yt.forEach(function(t) { ctx.moveTo(L.left, sy(t, p)); ctx.lineTo(L.right, sy(t, p)); });
+		xt.forEach(function(t) { ctx.moveTo(sx(t.x), p.top); ctx.lineTo(sx(t.x), p.bottom); });
+		ctx.stroke();
+
+		setFont(fs * 0.85);
+		ctx.fillStyle = "#4d4d4d";
+		ctx.textAlign = "right";
+		ctx.textBaseline = "middle";
+		yt.forEach(function(t) { ctx.fillText(fmtTick(t, yt.step), L.left - 6, sy(t, p)); });
+
+		ctx.save();
+		ctx.beginPath();
+		ctx.rect(L.left, p.top, L.right - L.left, p.bottom - p.top);
+		ctx.clip();
+		D.highlights.forEach(function(m) { drawHighlight(m, p); });
+		D.series.forEach(function(s) {
+			if (s.facet === p.facet && !hidden[s.name]) { drawSeries(s, p); }
+		});
+		ctx.restore();
+
+		ctx.strokeStyle = "#333333";
+		ctx.strokeRect(L.left, p.top, L.right - L.left, p.bottom - p.top);
+		if (faceted) {
+			ctx.fillStyle = "#d9d9d9";
+			ctx.fillRect(L.right, p.top, fs * 1.6, p.bottom - p.top);
+			ctx.strokeRect(L.right, p.top, fs * 1.6, p.bottom - p.top);
+			ctx.save();
+			ctx.translate(L.right + fs * 0.8, (p.top + p.bottom) / 2);
+			ctx.rotate(Math.PI / 2);
+			ctx.fillStyle = "#1a1a1a";
+			ctx.textAlign = "center";
+			ctx.fillText(p.facet, 0, 0);
+			ctx.restore();
+		}
+	});
+
+	setFont(fs * 0.85);
+	ctx.fillStyle = "#4d4d4d";
+	ctx.textAlign = "center";
+	ctx.textBaseline = "top";
+	xt.forEach(function(t) { ctx.fillText(t.label, sx(t.x), L.bottom + 5); });
+	setFont(fs);
+	ctx.fillStyle = "#000000";
+	ctx.textBaseline = "alphabetic";
+	ctx.fillText(xlabel, (L.left + L.right) / 2, h - fs * 0.6);
+	ctx.save();
+	ctx.translate(fs * 1.2, (L.top + L.bottom) / 2);
+	ctx.rotate(-Math.PI / 2);
+	ctx.fillText(D.ylabel, 0, 0);
+	ctx.restore();
+
+	var rows = D.names.length + 1 + (keys.length > 0 ? keys.length + 1 : 0);
+	var lx = L.right + (faceted ? fs * 1.6 : 0) + fs, ly = (L.top + L.bottom) / 2 - rows * fs * 1.4 / 2;
+	ctx.textAlign = "left";
+	ctx.textBaseline = "middle";
+	ctx.fillText(D.legendtitle, lx, ly + fs * 0.7);
+	L.legend = D.names.map(function(name, i) {
+		var y = ly + (i + 1.5) * fs * 1.4, s = byName[name];
+		drawKey(s, lx, y, hidden[name]);
+		ctx.fillStyle = hidden[name] ? "#aaaaaa" : "#000000";
+		ctx.fillText(label(s), lx + fs * 1.5, y);
+		return {name: name, x: lx, y: y - fs * 0.7, w: textW(label(s), fs) + fs * 1.5, h: fs * 1.4};
+	});
+	if (keys.length > 0) {
+		var ky = ly + (D.names.length + 1) * fs * 1.4;
+		ctx.fillStyle = "#000000";
+		ctx.fillText("Highlights", lx, ky + fs * 0.7);
+		keys.forEach(function(m, i) {
+			var y = ky + (i + 1.5) * fs * 1.4;
+			drawKey(m, lx, y, false);
+			ctx.fillStyle = "#000000";
+			ctx.fillText(m.label, lx + fs * 1.5, y);
+		});
+	}
+}
+
+function redraw() {
+	if (!pending) {
+		pending = true;
+		window.requestAnimationFrame(draw);
+	}
+}
+
+function mousePos(e) {
+	var r = cv.getBoundingClientRect();
+	return {x: e.clientX - r.left, y: e.clientY - r.top};
+}
+
+function panelAt(m) {
+	if (!L || m.x < L.left || m.x > L.right) { return null; }
+	for (var i = 0; i < L.panels.length; i++) {
+		var p = L.panels[i];
+		if (m.y >= p.top && m.y <= p.bottom) { return p; }
+	}
+	return null;
+}
+
+function hover(m) {
+	var p = panelAt(m);
+	var best = null, bd = 100;
+	if (p) {
+		D.series.forEach(function(s) {
+			if (s.facet !== p.facet || hidden[s.name]) { return; }
+			for (var i = 0; i < s.val.length; i++) {
+				if (s.val[i] === null) { continue; }
+				var dx = sx(mid(s, i)) - m.x, dy = sy(s.val[i], p) - m.y, d = dx * dx + dy * dy;
+				if (d < bd) { bd = d; best = {s: s, i: i}; }
+			}
+		});
+	}
+	if (!best) {
+		tip.style.display = "none";
+		return;
+	}
+	var s = best.s, i = best.i;
+	var lines = [label(s)];
+	if (s.facet) { lines.push("Facet: " + s.facet); }
+	lines.push("Chromosome: " + D.chrs[s.chr[i]].name);
+	lines.push("Start: " + fmtBp(s.bp0[i]));
+	lines.push("End: " + fmtBp(s.bp1[i]));
+	lines.push("Value: " + fmtVal(s.val[i]));
+	if (s.lower) { lines.push("Range: " + fmtVal(s.lower[i]) + " to " + fmtVal(s.upper[i])); }
+	tip.textContent = lines.join("\n");
+	tip.style.display = "block";
+	var tx = m.x + 14, ty = m.y + 14;
+	if (tx + tip.offsetWidth > cv.clientWidth) { tx = m.x - tip.offsetWidth - 14; }
+	if (ty + tip.offsetHeight > cv.clientHeight) { ty = m.y - tip.offsetHeight - 14; }
+	tip.style.left = tx + "px";
+	tip.style.top = ty + "px";
+}
+
+cv.addEventListener("wheel", function(e) {
+	var m = mousePos(e), p = panelAt(m);
+	if (!p) { return; }
+	e.preventDefault();
+	var k = e.deltaY < 0 ? 0.8 : 1.25;
+	if (e.shiftKey) {
+		var y = view.y0 + (p.bottom - m.y) / (p.bottom - p.top) * (view.y1 - view.y0);
+		view.y0 = y - (y - view.y0) * k;
+		view.y1 = y + (view.y1 - y) * k;
+	} else {
+		var x = view.x0 + (m.x - L.left) / (L.right - L.left) * (view.x1 - view.x0);
+		view.x0 = x - (x - view.x0) * k;
+		view.x1 = x + (view.x1 - x) * k;
+	}
+	redraw();
+}, {passive: false});
+
+cv.addEventListener("mousedown", function(e) {
+	var m = mousePos(e), p = panelAt(m);
+	if (p) {
+		drag = {m: m, view: copyView(view), p: p};
+		e.preventDefault();
+	}
+});
+
+window.addEventListener("mousemove", function(e) {
+	var m = mousePos(e);
+	if (!drag) {
+		hover(m);
+		return;
+	}
+	tip.style.display = "none";
+	var v = drag.view, p = drag.p;
+	var dx = (m.x - drag.m.x) / (L.right - L.left) * (v.x1 - v.x0);
+	var dy = (m.y - drag.m.y) / (p.bottom - p.top) * (v.y1 - v.y0);
+	view = {x0: v.x0 - dx, x1: v.x1 - dx, y0: v.y0 + dy, y1: v.y1 + dy};
+	redraw();
+});
+
+window.addEventListener("mouseup", function() { drag = null; });
+
+cv.addEventListener("mouseleave", function() { if (!drag) { tip.style.display = "none"; } });
+
+cv.addEventListener("click", function(e) {
+	var m = mousePos(e);
+	(L.legend || []).forEach(function(item) {
+		if (m.x >= item.x && m.x <= item.x + item.w && m.y >= item.y && m.y <= item.y + item.h) {
+			hidden[item.name] = !hidden[item.name];
+			redraw();
+		}
+	});
+});
+
+cv.addEventListener("dblclick", function() { view = copyView(full); redraw(); });
+
+document.getElementById("reset").addEventListener("click", function() { view = copyView(full); redraw(); });
+
+document.getElementById("showall").addEventListener("click", function() { hidden = {}; redraw(); });
+
+window.addEventListener("resize", redraw);
+
+draw();
+})();
+</script>
+</body>
+</html>
+`
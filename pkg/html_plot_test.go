@@ -0,0 +1,65 @@
+package covplots
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlotHTML(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t0\t10\t1\tf1\tixw\t0\t0\t10\n" +
+		"2L\t10\t20\tNA\tf1\tixw\t0\t10\t20\n" +
+		"3R\t5\t15\t3\tf2\ta</script>\t1\t25\t35\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+
+	if e := PlotHTMLAny(PlfmtFacet)(outpre, nil, map[string]any{"Title": "t"}, MultiplotPlotFuncArgs{}); e != nil {
+		panic(e)
+	}
+	page, err := os.ReadFile(outpre + "_plotted.html")
+	if err != nil {
+		panic(err)
+	}
+	s := string(page)
+	if strings.Count(s, "</script>") != 2 || strings.Contains(s, "src=") || strings.Contains(s, "http") {
+		t.Errorf("page is not self-contained or data was not escaped")
+	}
+
+	start := strings.Index(s, `id="plotdata">`) + len(`id="plotdata">`)
+	end := strings.Index(s[start:], "</script>")
+	var d struct {
+		Facets []string
+		Chrs []htmlChr
+		Series []struct {
+			Name string
+			Facet string
+			Bp0 []int64
+			Val []*float64
+		}
+	}
+	if err := json.Unmarshal([]byte(s[start:start+end]), &d); err != nil {
+		t.Fatalf("invalid plot data: %v", err)
+	}
+	if len(d.Series) != 2 || d.Series[0].Name != "ixw" || d.Series[1].Name != "a</script>" || d.Series[1].Facet != "f2" {
+		t.Fatalf("series %v", d.Series)
+	}
+	if d.Series[0].Val[1] != nil || *d.Series[0].Val[0] != 1 {
+		t.Errorf("out %v != expect %v", d.Series[0].Val, "[1 null]")
+	}
+	if d.Chrs[1].Name != "3R" || d.Chrs[1].Shift != 20 || d.Series[1].Bp0[0] != 5 {
+		t.Errorf("out %v != expect 3R shifted by 20", d.Chrs)
+	}
+}
+
+func TestJsonNum(t *testing.T) {
+	out, err := json.Marshal([]jsonNum{1.5, jsonNum(math.NaN()), jsonNum(math.Inf(1))})
+	if err != nil {
+		panic(err)
+	}
+	if string(out) != "[1.5,null,null]" {
+		t.Errorf("out %v != expect %v", string(out), "[1.5,null,null]")
+	}
+}
@@ -0,0 +1,268 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// One ungapped block of a UCSC chain, in the coordinates of both assemblies.
+// QStart is on the forward strand of the query even if the chain is reversed.
+type ChainBlock struct {
+	TChr string
+	TStart int
+	TEnd int
+	QChr string
+	QStart int
+	QEnd int
+	QReverse bool
+}
+
+// All chain blocks, indexed by their spans on the target (old assembly)
+type ChainIndex struct {
+	Blocks []ChainBlock
+	spans *SpanIndex
+}
+
+func NewChainIndex(blocks []ChainBlock) ChainIndex {
+	entries := make([]BedEntry, len(blocks))
+	for i, b := range blocks {
+		entries[i] = BedEntry{Chr: b.TChr, Start: int64(b.TStart), End: int64(b.TEnd)}
+	}
+	return ChainIndex{Blocks: blocks, spans: NewSpanIndex(entries)}
+}
+
+func chainFieldInts(fields []string) ([]int, error) {
+	out := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// Read a UCSC chain file into an index of aligned blocks
+func ReadChains(r io.Reader) (ChainIndex, error) {
+	h := Handle("ReadChains: %w")
+
+	var blocks []ChainBlock
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+
+	var tchr, qchr string
+	var tpos, qpos, qsize int
+	var qrev, inchain bool
+
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			inchain = false
+			continue
+		}
+
+		if fields[0] == "chain" {
+			// chain score tName tSize tStrand tStart tEnd qName qSize qStrand qStart qEnd id
+			if len(fields) < 12 {
+				return ChainIndex{}, h(fmt.Errorf("chain header %v too short", fields))
+			}
+			ints, err := chainFieldInts([]string{fields[5], fields[8], fields[10]})
+			if err != nil {
+				return ChainIndex{}, h(err)
+			}
+			tchr, qchr = fields[2], fields[7]
+			tpos, qsize, qpos = ints[0], ints[1], ints[2]
+			qrev = fields[9] == "-"
+			inchain = true
+			continue
+		}
+
+		if !inchain {
+			return ChainIndex{}, h(fmt.Errorf("alignment line %v outside of a chain", fields))
+		}
+
+		// size [dt dq]
+		ints, err := chainFieldInts(fields)
+		if err != nil {
+			return ChainIndex{}, h(err)
+		}
+		size := ints[0]
+		b := ChainBlock{TChr: tchr, TStart: tpos, TEnd: tpos + size, QChr: qchr, QStart: qpos, QEnd: qpos + size, QReverse: qrev}
+		if qrev {
+			b.QStart, b.QEnd = qsize - (qpos + size), qsize - qpos
+		}
+		blocks = append(blocks, b)
+
+		if len(ints) >= 3 {
+			tpos += size + ints[1]
+			qpos += size + ints[2]
+		} else {
+			inchain = false
+		}
+	}
+	if s.Err() != nil {
+		return ChainIndex{}, h(s.Err())
+	}
+
+	return NewChainIndex(blocks), nil
+}
+
+func ReadChainsPath(path string) (ChainIndex, error) {
+	r, e := OpenMaybeGz(path)
+	if e != nil { return ChainIndex{}, fmt.Errorf("ReadChainsPath: %w", e) }
+	defer r.Close()
+
+	return ReadChains(r)
+}
+
+// Map a span on the old assembly to the new one. The span is split into one
+// piece per chain block it overlaps; parts that fall in gaps are lost. A nil
+// result means nothing could be mapped.
+func (idx ChainIndex) Lift(chr string, start, end int) []Span {
+	if idx.spans == nil {
+		return nil
+	}
+	var out []Span
+	for _, i := range idx.spans.Overlaps(chr, int64(start), int64(end)) {
+		b := idx.Blocks[i]
+		ostart, oend := start, end
+		if b.TStart > ostart {
+			ostart = b.TStart
+		}
+		if b.TEnd < oend {
+			oend = b.TEnd
+		}
+		var qs, qe int
+		if b.QReverse {
+			qs, qe = b.QEnd - (oend - b.TStart), b.QEnd - (ostart - b.TStart)
+		} else {
+			qs, qe = b.QStart + (ostart - b.TStart), b.QStart + (oend - b.TStart)
+		}
+		out = append(out, Span{b.QChr, qs, qe})
+	}
+	return out
+}
+
+type LiftoverArgs struct {
+	// UCSC chain file from the old assembly to the new one
+	Chain string
+	// If set, records that could not be mapped are written to the plot's output prefix plus "_" plus this suffix
+	Unmapped string
+}
+
+// A file shared by several writers; it is closed when every writer has called Close
+type sharedFile struct {
+	mu sync.Mutex
+	f *os.File
+	users int
+}
+
+func (s *sharedFile) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Write(p)
+}
+
+func (s *sharedFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users--
+	if s.users == 0 {
+		return s.f.Close()
+	}
+	return nil
+}
+
+// Lift every line in r to the new assembly. Chromosome names are looked up
+// without their parent, and the parent is added back to the lifted name.
+// Lines that cannot be mapped are dropped, or written to unmapped if it is
+// not nil. Unmapped is closed at the end if it is an io.Closer. A line whose
+// span cannot be parsed ends the output with an error.
+func LiftoverOne(r io.Reader, chains ChainIndex, naming ChrNaming, unmapped io.Writer) io.Reader {
+	h := Handle("LiftoverOne: %w")
+
+	return PipeWriteErr(func(w io.Writer) error {
+		defer CloseAny(unmapped)
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		bw := bufio.NewWriter(w)
+
+		i := 0
+		j := 0
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			i++
+			start, err1 := strconv.Atoi(line[1])
+			end, err2 := strconv.Atoi(line[2])
+			if err1 != nil || err2 != nil {
+				return h(fmt.Errorf("could not parse span of line %v", line))
+			}
+
+			chr, parent := naming.Split(line[0])
+			lifted := chains.Lift(chr, start, end)
+			if len(lifted) == 0 {
+				if unmapped != nil {
+					fmt.Fprintln(unmapped, s.Text())
+				}
+				continue
+			}
+			j++
+
+			for _, span := range lifted {
+				line[0] = naming.Join(span.Chr, parent)
+				line[1] = strconv.Itoa(span.Start)
+				line[2] = strconv.Itoa(span.End)
+				fmt.Fprintln(bw, strings.Join(line, "\t"))
+			}
+		}
+		if s.Err() != nil {
+			return h(s.Err())
+		}
+		fmt.Fprintf(os.Stderr, "Liftover: mapped %v of %v lines\n", j, i)
+		return bw.Flush()
+	})
+}
+
+// Lift all readers to a new assembly with a UCSC chain file. The chain file
+// is read once per config. Anyargs must be of type LiftoverArgs.
+func Liftover(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("Liftover: %w")
+
+	var args LiftoverArgs
+	err := UnmarshalJsonOut(anyargs, &args)
+	if err != nil { return nil, h(err) }
+	if args.Chain == "" {
+		return nil, h(fmt.Errorf("missing chain file"))
+	}
+
+	chains, err := configCached(ctx.Data, "liftover chain " + args.Chain, func() (ChainIndex, error) {
+		return ReadChainsPath(args.Chain)
+	})
+	if err != nil { return nil, h(err) }
+
+	var unmapped *sharedFile
+	if args.Unmapped != "" && !ctx.wholeGenomePass {
+		f, err := os.Create(ctx.Outpre + "_" + args.Unmapped)
+		if err != nil { return nil, h(err) }
+		unmapped = &sharedFile{f: f, users: len(rs)}
+	}
+
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		if unmapped != nil {
+			out[i] = LiftoverOne(r, chains, ctx.Cfg.Naming, unmapped)
+		} else {
+			out[i] = LiftoverOne(r, chains, ctx.Cfg.Naming, nil)
+		}
+	}
+	return out, nil
+}
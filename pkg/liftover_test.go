@@ -0,0 +1,124 @@
+package covplots
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var chaintxt = `chain 1000 chrA 1000 + 0 300 chrA 1100 + 10 310 1
+100	50	50
+150
+
+chain 1000 chrB 500 + 0 100 chrB2 400 - 0 100 2
+100
+`
+
+func TestLift(t *testing.T) {
+	chains, e := ReadChains(strings.NewReader(chaintxt))
+	if e != nil { panic(e) }
+
+	out := chains.Lift("chrA", 50, 200)
+	expect := []Span{Span{"chrA", 60, 110}, Span{"chrA", 160, 210}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	out = chains.Lift("chrB", 10, 20)
+	expect = []Span{Span{"chrB2", 380, 390}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	if out = chains.Lift("chrA", 110, 140); out != nil {
+		t.Errorf("span in a chain gap mapped to %v", out)
+	}
+}
+
+func TestLiftOverlappingChains(t *testing.T) {
+	// A long block that starts before shorter ones must still be found
+	txt := `chain 1000 chrC 2000 + 0 1000 chrX 1000 + 0 1000 1
+1000
+
+chain 500 chrC 2000 + 100 110 chrY 10 + 0 10 2
+10
+
+chain 500 chrC 2000 + 200 210 chrZ 10 + 0 10 3
+10
+`
+	chains, e := ReadChains(strings.NewReader(txt))
+	if e != nil { panic(e) }
+
+	out := chains.Lift("chrC", 500, 510)
+	expect := []Span{Span{"chrX", 500, 510}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+	out = chains.Lift("chrC", 205, 207)
+	expect = []Span{Span{"chrX", 205, 207}, Span{"chrZ", 5, 7}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+	if out = chains.Lift("chrD", 0, 10); out != nil {
+		t.Errorf("span on an unknown chromosome mapped to %v", out)
+	}
+}
+
+func TestLiftoverOne(t *testing.T) {
+	chains, e := ReadChains(strings.NewReader(chaintxt))
+	if e != nil { panic(e) }
+
+	in := "chrA_ISO1\t50\t200\t5\nchrA_ISO1\t110\t140\t6\n"
+	expect := "chrA_ISO1\t60\t110\t5\nchrA_ISO1\t160\t210\t5\n"
+	var unmapped strings.Builder
+
+	r := LiftoverOne(strings.NewReader(in), chains, ChrNaming{}, &unmapped)
+	var b strings.Builder
+	io.Copy(&b, r)
+
+	if b.String() != expect {
+		t.Errorf("b.String() %v != expect %v", b.String(), expect)
+	}
+	if unmapped.String() != "chrA_ISO1\t110\t140\t6\n" {
+		t.Errorf("unexpected unmapped lines %v", unmapped.String())
+	}
+}
+
+func TestLiftoverOneBadLine(t *testing.T) {
+	chains, e := ReadChains(strings.NewReader(chaintxt))
+	if e != nil { panic(e) }
+
+	r := LiftoverOne(strings.NewReader("chrA_ISO1\tstart\t200\t5\n"), chains, ChrNaming{}, nil)
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("no error for a line without a span")
+	}
+}
+
+func TestLiftoverWindow(t *testing.T) {
+	dir := t.TempDir()
+	chain := filepath.Join(dir, "old_to_new.chain")
+	if e := os.WriteFile(chain, []byte(chaintxt), 0644); e != nil { panic(e) }
+	in := filepath.Join(dir, "in.bed")
+	if e := os.WriteFile(in, []byte("chrB_ISO1\t10\t20\t5\n"), 0644); e != nil { panic(e) }
+
+	// The record is on another chromosome in the old assembly, so it is only
+	// in the window after liftover
+	set := InputSet{Paths: []string{in}, Name: "in", Functions: []string{"liftover"}, FunctionArgs: []any{map[string]any{"Chain": chain}}}
+	ctx := MultiplotFuncCtx{Outpre: filepath.Join(dir, "out"), Chr: "chrB2", Start: 350, End: 400, Data: new(ConfigData)}
+	r, closers, err := MultiplotInputSet(set, ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer CloseAny(closers...)
+	out, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	expect := "chrB2_ISO1\t380\t390\t5\n"
+	if string(out) != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
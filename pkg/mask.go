@@ -0,0 +1,174 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type RegionArgs struct {
+	// Bed file of regions
+	Bed string
+	// Fraction (0 to 1) of a record that regions must cover for it to count
+	// as overlapping; if 0, any overlap counts
+	MinFrac float64
+	// Cut overlapping records down to the parts inside (keep_regions) or
+	// outside (mask) the regions instead of keeping or dropping them whole
+	Trim bool
+}
+
+// The parts of start to end covered by the regions at idxs, merged and in order
+func coveredParts(regions *SpanIndex, idxs []int, start, end int64) []Span {
+	var out []Span
+	for _, i := range idxs {
+		reg := regions.Entries[i]
+		s, e := reg.Start, reg.End
+		if s < start { s = start }
+		if e > end { e = end }
+		if e < s { continue }
+		if n := len(out); n > 0 && int64(out[n-1].End) >= s {
+			if int64(out[n-1].End) < e {
+				out[n-1].End = int(e)
+			}
+			continue
+		}
+		out = append(out, Span{Start: int(s), End: int(e)})
+	}
+	return out
+}
+
+// The parts of start to end not in parts, which must be merged and in order
+func uncoveredParts(parts []Span, start, end int64) []Span {
+	var out []Span
+	cursor := int(start)
+	for _, p := range parts {
+		if p.Start > cursor {
+			out = append(out, Span{Start: cursor, End: p.Start})
+		}
+		cursor = p.End
+	}
+	if cursor < int(end) {
+		out = append(out, Span{Start: cursor, End: int(end)})
+	}
+	return out
+}
+
+// Keep (if keep) or drop the records of r that overlap regions. Region
+// chromosomes are matched to each record's full name, or else its chromosome
+// part. A line without a numeric span is an error.
+func RegionFilterOne(r io.Reader, regions *SpanIndex, naming ChrNaming, keep bool, args RegionArgs) io.Reader {
+	h := Handle("RegionFilterOne: %w")
+
+	return PipeWriteErr(func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		s := bufio.NewScanner(r)
+		s.Buffer([]byte{}, 1e12)
+		total, written := 0, 0
+		for s.Scan() {
+			line := strings.Split(s.Text(), "\t")
+			if len(line) < 3 {
+				continue
+			}
+			total++
+			start, e1 := strconv.ParseInt(line[1], 0, 64)
+			end, e2 := strconv.ParseInt(line[2], 0, 64)
+			if e1 != nil || e2 != nil {
+				return h(fmt.Errorf("could not parse span of line %v", line))
+			}
+
+			qend := end
+			if qend <= start {
+				qend = start + 1
+			}
+			parts := coveredParts(regions, regions.Overlaps(regions.ChrFor(line[0], naming), start, qend), start, end)
+
+			hit := len(parts) > 0
+			if hit && args.MinFrac > 0 && end > start {
+				covered := 0
+				for _, p := range parts {
+					covered += p.End - p.Start
+				}
+				hit = float64(covered) / float64(end - start) >= args.MinFrac
+			}
+
+			var out []Span
+			switch {
+			case hit == keep && !(hit && args.Trim && end > start):
+				out = []Span{Span{Start: int(start), End: int(end)}}
+			case hit && args.Trim && keep:
+				out = parts
+			case hit && args.Trim && !keep:
+				out = uncoveredParts(parts, start, end)
+			}
+
+			if len(out) > 0 {
+				written++
+			}
+			for _, span := range out {
+				line[1] = strconv.Itoa(span.Start)
+				line[2] = strconv.Itoa(span.End)
+				fmt.Fprintln(bw, strings.Join(line, "\t"))
+			}
+		}
+		if s.Err() != nil {
+			return h(s.Err())
+		}
+		if keep {
+			fmt.Fprintf(os.Stderr, "KeepRegions: kept %v of %v lines\n", written, total)
+		} else {
+			fmt.Fprintf(os.Stderr, "Mask: kept %v of %v lines\n", written, total)
+		}
+		return bw.Flush()
+	})
+}
+
+// Keep or drop records of every reader by overlap with a bed file of regions
+func RegionFilter(rs []io.Reader, args RegionArgs, naming ChrNaming, keep bool) ([]io.Reader, error) {
+	h := Handle("RegionFilter: %w")
+
+	if args.Bed == "" {
+		return nil, h(fmt.Errorf("missing Bed"))
+	}
+	if args.MinFrac < 0 || args.MinFrac > 1 {
+		return nil, h(fmt.Errorf("MinFrac %v is not between 0 and 1", args.MinFrac))
+	}
+
+	r, err := OpenMaybeGz(args.Bed)
+	if err != nil { return nil, h(err) }
+	defer r.Close()
+	entries, err := ReadBed(r)
+	if err != nil { return nil, h(err) }
+	regions := NewSpanIndex(entries)
+
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		out[i] = RegionFilterOne(r, regions, naming, keep, args)
+	}
+	return out, nil
+}
+
+// Drop records overlapping the regions in a bed file, such as a blacklist. Anyargs must be of type RegionArgs.
+func Mask(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	var args RegionArgs
+	if e := UnmarshalJsonOut(anyargs, &args); e != nil {
+		return nil, fmt.Errorf("Mask: %w", e)
+	}
+	out, e := RegionFilter(rs, args, ctx.Cfg.Naming, false)
+	if e != nil { return nil, fmt.Errorf("Mask: %w", e) }
+	return out, nil
+}
+
+// Keep only records overlapping the regions in a bed file. Anyargs must be of type RegionArgs.
+func KeepRegions(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	var args RegionArgs
+	if e := UnmarshalJsonOut(anyargs, &args); e != nil {
+		return nil, fmt.Errorf("KeepRegions: %w", e)
+	}
+	out, e := RegionFilter(rs, args, ctx.Cfg.Naming, true)
+	if e != nil { return nil, fmt.Errorf("KeepRegions: %w", e) }
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package covplots
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegionFilter(t *testing.T) {
+	bed := filepath.Join(t.TempDir(), "blacklist.bed")
+	if e := os.WriteFile(bed, []byte("chr1\t10\t20\nchr1\t15\t25\nchr1\t100\t200\n"), 0644); e != nil { panic(e) }
+
+	in := "chr1_a\t0\t10\t1\nchr1_a\t5\t30\t2\nchr1_a\t150\t160\t3\nchr2_a\t10\t20\t4\n"
+	tests := []struct {
+		keep bool
+		args RegionArgs
+		expect string
+	}{
+		{false, RegionArgs{}, "chr1_a\t0\t10\t1\nchr2_a\t10\t20\t4\n"},
+		{false, RegionArgs{MinFrac: 0.7}, "chr1_a\t0\t10\t1\nchr1_a\t5\t30\t2\nchr2_a\t10\t20\t4\n"},
+		{false, RegionArgs{Trim: true}, "chr1_a\t0\t10\t1\nchr1_a\t5\t10\t2\nchr1_a\t25\t30\t2\nchr2_a\t10\t20\t4\n"},
+		{true, RegionArgs{}, "chr1_a\t5\t30\t2\nchr1_a\t150\t160\t3\n"},
+		{true, RegionArgs{Trim: true}, "chr1_a\t10\t25\t2\nchr1_a\t150\t160\t3\n"},
+	}
+
+	for _, test := range tests {
+		test.args.Bed = bed
+		rs, err := RegionFilter([]io.Reader{strings.NewReader(in)}, test.args, ChrNaming{}, test.keep)
+		if err != nil {
+			panic(err)
+		}
+		out, err := io.ReadAll(rs[0])
+		if err != nil {
+			panic(err)
+		}
+		if string(out) != test.expect {
+			t.Errorf("keep %v args %v: out %q != expect %q", test.keep, test.args, string(out), test.expect)
+		}
+	}
+}
+
+func TestRegionFilterHeader(t *testing.T) {
+	bed := filepath.Join(t.TempDir(), "blacklist.bed")
+	if e := os.WriteFile(bed, []byte("chr1\t10\t20\n"), 0644); e != nil { panic(e) }
+
+	for _, keep := range []bool{false, true} {
+		rs, err := RegionFilter([]io.Reader{strings.NewReader("chrom\tstart\tend\tval\nchr1\t0\t10\t1\n")}, RegionArgs{Bed: bed}, ChrNaming{}, keep)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := io.ReadAll(rs[0]); err == nil {
+			t.Errorf("keep %v: no error for a header line", keep)
+		}
+	}
+}
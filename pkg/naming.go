@@ -0,0 +1,68 @@
+package covplots
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How a chromosome name encodes its parent genome, i.e. "2L_ISO1". The zero
+// value splits on the last "_" and treats the part after it as the parent.
+type ChrNaming struct {
+	// Separator between chromosome and parent (default "_")
+	Sep string `json:"sep"`
+	// Where the parent name is: "suffix" (default), "prefix", or "none" if names have no parent
+	Parent string `json:"parent"`
+}
+
+func (n ChrNaming) sep() string {
+	if n.Sep == "" {
+		return "_"
+	}
+	return n.Sep
+}
+
+// Check that the naming scheme is one we know how to handle
+func (n ChrNaming) Validate() error {
+	switch n.Parent {
+	case "", "suffix", "prefix", "none":
+		return nil
+	default:
+		return fmt.Errorf("ChrNaming: unknown parent position %q", n.Parent)
+	}
+}
+
+// Split a full chromosome name into the chromosome and its parent. Names
+// without a separator have no parent.
+func (n ChrNaming) Split(name string) (chr, parent string) {
+	sep := n.sep()
+	switch n.Parent {
+	case "none":
+		return name, ""
+	case "prefix":
+		if i := strings.Index(name, sep); i >= 0 {
+			return name[i+len(sep):], name[:i]
+		}
+	default:
+		if i := strings.LastIndex(name, sep); i >= 0 {
+			return name[:i], name[i+len(sep):]
+		}
+	}
+	return name, ""
+}
+
+// The chromosome part of a full chromosome name
+func (n ChrNaming) Chr(name string) string {
+	chr, _ := n.Split(name)
+	return chr
+}
+
+// Build a full chromosome name from a chromosome and parent
+func (n ChrNaming) Join(chr, parent string) string {
+	if parent == "" || n.Parent == "none" {
+		return chr
+	}
+	if n.Parent == "prefix" {
+		return parent + n.sep() + chr
+	}
+	return chr + n.sep() + parent
+}
@@ -0,0 +1,73 @@
+package covplots
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChrNamingSplit(t *testing.T) {
+	type test struct {
+		naming ChrNaming
+		name string
+		chr string
+		parent string
+	}
+	tests := []test{
+		test{ChrNaming{}, "2L_ISO1", "2L", "ISO1"},
+		test{ChrNaming{}, "2L", "2L", ""},
+		test{ChrNaming{}, "scaffold_12_ISO1", "scaffold_12", "ISO1"},
+		test{ChrNaming{Sep: "."}, "scaffold_12.ISO1", "scaffold_12", "ISO1"},
+		test{ChrNaming{Parent: "prefix"}, "ISO1_scaffold_12", "scaffold_12", "ISO1"},
+		test{ChrNaming{Parent: "none"}, "scaffold_12", "scaffold_12", ""},
+	}
+
+	for _, tst := range tests {
+		chr, parent := tst.naming.Split(tst.name)
+		if chr != tst.chr || parent != tst.parent {
+			t.Errorf("%v: chr %v, parent %v != expected %v, %v", tst.name, chr, parent, tst.chr, tst.parent)
+		}
+		if joined := tst.naming.Join(chr, parent); joined != tst.name {
+			t.Errorf("joined %v != name %v", joined, tst.name)
+		}
+	}
+}
+
+var namingin = `2L_ISO1	0	100	1
+2L_A4	50	150	2
+2LHet_ISO1	0	100	3
+2L_random_ISO1	0	100	4
+`
+
+var namingout = `2L_ISO1	0	100	1
+2L_A4	50	150	2
+`
+
+func TestFilterNamed(t *testing.T) {
+	fr, e := FilterNamed(strings.NewReader(namingin), ChrNaming{}, "2L", 20, 200)
+	if e != nil { panic(e) }
+
+	var b strings.Builder
+	io.Copy(&b, fr)
+	if b.String() != namingout {
+		t.Errorf("b.String() %v != namingout %v", b.String(), namingout)
+	}
+}
+
+func TestReChrSingleNamed(t *testing.T) {
+	in := "2L\t0\t100\t1\nX\t5\t10\t2\n"
+	for _, tst := range []struct {
+		naming ChrNaming
+		expect string
+	}{
+		{ChrNaming{}, "2L_ISO1\t0\t100\t1\nX_ISO1\t5\t10\t2\n"},
+		{ChrNaming{Sep: ".", Parent: "prefix"}, "ISO1.2L\t0\t100\t1\nISO1.X\t5\t10\t2\n"},
+		{ChrNaming{Parent: "none"}, in},
+	} {
+		var b strings.Builder
+		io.Copy(&b, ReChrSingleNamed(strings.NewReader(in), []string{"ISO1"}, tst.naming))
+		if b.String() != tst.expect {
+			t.Errorf("b.String() %q != expect %q", b.String(), tst.expect)
+		}
+	}
+}
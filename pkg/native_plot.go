@@ -0,0 +1,466 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The column layout of a _plfmt.bed file
+type PlfmtLayout int
+
+const (
+	// chrom, BP1, BP, VAL, NAME, CHR, startoff, endoff
+	PlfmtPlain PlfmtLayout = iota
+	// chrom, BP1, BP, VAL, FACET, NAME, CHR, startoff, endoff
+	PlfmtFacet
+	// chrom, BP1, BP, VAL, LOWER, UPPER, NAME, CHR, startoff, endoff
+	PlfmtRibbon
+)
+
+// One record of a _plfmt.bed file
+type PlotPoint struct {
+	Chr string
+	Val float64
+	Lower float64
+	Upper float64
+	Facet string
+	Name string
+	// Any columns between VAL (or FACET, LOWER, and UPPER) and NAME
+	Cols []string
+	// Position on the chromosome
+	BpStart int64
+	BpEnd int64
+	// Position on the genome-wide axis
+	Start float64
+	End float64
+}
+
+func (p PlotPoint) X() float64 {
+	return (p.Start + p.End) / 2
+}
+
+// Parse one _plfmt.bed line. Names and offsets are counted from the end of
+// the line, so extra columns between them and VAL are allowed.
+func ParsePlotPoint(line []string, layout PlfmtLayout) (PlotPoint, error) {
+	var p PlotPoint
+	n := len(line)
+	need := map[PlfmtLayout]int{PlfmtPlain: 8, PlfmtFacet: 9, PlfmtRibbon: 10}[layout]
+	if n < need {
+		return p, fmt.Errorf("ParsePlotPoint: line %v has less than %v fields", line, need)
+	}
+
+	p.Chr = line[0]
+	p.Val = AlwaysParseFloat(line[3])
+	p.Lower, p.Upper = math.NaN(), math.NaN()
+	p.Name = line[n-4]
+	var e1, e2 error
+	p.Start, e1 = strconv.ParseFloat(line[n-2], 64)
+	p.End, e2 = strconv.ParseFloat(line[n-1], 64)
+	if e1 != nil || e2 != nil {
+		return p, fmt.Errorf("ParsePlotPoint: could not parse offsets of line %v", line)
+	}
+	p.BpStart, e1 = strconv.ParseInt(line[1], 10, 64)
+	p.BpEnd, e2 = strconv.ParseInt(line[2], 10, 64)
+	if e1 != nil || e2 != nil {
+		return p, fmt.Errorf("ParsePlotPoint: could not parse positions of line %v", line)
+	}
+
+	first := 4
+	switch layout {
+	case PlfmtFacet:
+		p.Facet = line[4]
+		first = 5
+	case PlfmtRibbon:
+		p.Lower = AlwaysParseFloat(line[4])
+		p.Upper = AlwaysParseFloat(line[5])
+		first = 6
+	}
+	if n - 4 > first {
+		p.Cols = line[first:n-4]
+	}
+	return p, nil
+}
+
+func ReadPlotPoints(r io.Reader, layout PlfmtLayout) ([]PlotPoint, error) {
+	var out []PlotPoint
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		if s.Text() == "" {
+			continue
+		}
+		p, err := ParsePlotPoint(strings.Split(s.Text(), "\t"), layout)
+		if err != nil {
+			return nil, fmt.Errorf("ReadPlotPoints: %w", err)
+		}
+		out = append(out, p)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("ReadPlotPoints: %w", err)
+	}
+	return out, nil
+}
+
+func ReadPlotPointsPath(path string, layout PlfmtLayout) ([]PlotPoint, error) {
+	r, err := OpenMaybeGz(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadPlotPointsPath: %w", err)
+	}
+	defer r.Close()
+	return ReadPlotPoints(r, layout)
+}
+
+// Options for native plots
+type NativePlotArgs struct {
+	// Size in pixels; default 1500 by 600
+	Width float64
+	Height float64
+	Title string
+	// Axis labels; default "Chromosome" and "Raw coverage"
+	XLabel string
+	YLabel string
+	// Legend title; default "Dataset"
+	LegendTitle string
+	// "point" (default) or "line"
+	Geom string
+	// Point radius or line width in pixels; default 1.5
+	Size float64
+	// Colors for input set names; others get the default palette
+	Colors map[string]string
+	// Order of names and facets: "sorted" (default, as in the R plots) or "input"
+	Order string
+	// Font size in pixels; default 16
+	FontSize float64
+	// Styles for input set names, added to those of the input sets
+	Styles map[string]SetStyle
+	// Y limits of single facets
+	FacetYlims map[string][]float64
+	// Order, titles, and heights of facet panels; default the config's facets
+	Facets []FacetCfg
+	// Regions to mark, before those of the config
+	Highlights []Highlight
+
+	// Reads highlight beds; nil to read them straight from the files
+	readBed highlightReader
+}
+
+func (a *NativePlotArgs) setDefaults() {
+	if a.Width == 0 { a.Width = 1500 }
+	if a.Height == 0 { a.Height = 600 }
+	if a.XLabel == "" { a.XLabel = "Chromosome" }
+	if a.YLabel == "" { a.YLabel = "Raw coverage" }
+	if a.LegendTitle == "" { a.LegendTitle = "Dataset" }
+	if a.Geom == "" { a.Geom = "point" }
+	if a.Size == 0 { a.Size = 1.5 }
+	if a.FontSize == 0 { a.FontSize = 16 }
+}
+
+// Unique values in order of first appearance, or sorted
+func uniqueStrings(vals []string, sorted bool) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, v := range vals {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	if sorted {
+		sort.Strings(out)
+	}
+	return out
+}
+
+// About n round-numbered ticks covering lo to hi
+func NiceTicks(lo, hi float64, n int) []float64 {
+	if !(hi > lo) || math.IsInf(hi - lo, 0) {
+		return []float64{lo}
+	}
+	raw := (hi - lo) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		step = m * mag
+		if step >= raw {
+			break
+		}
+	}
+	var out []float64
+	for t := math.Ceil(lo / step) * step; t <= hi + step * 1e-9; t += step {
+		out = append(out, math.Round(t / step) * step)
+	}
+	return out
+}
+
+// Format a tick label with as many decimals as the tick spacing has
+func FormatTick(v float64, ticks []float64) string {
+	decimals := 0
+	if len(ticks) > 1 {
+		step := math.Abs(ticks[1] - ticks[0])
+		for decimals < 10 {
+			scaled := step * math.Pow(10, float64(decimals))
+			if math.Abs(scaled - math.Round(scaled)) < 1e-6 * scaled {
+				break
+			}
+			decimals++
+		}
+	}
+	if v == 0 {
+		// Avoid "-0"
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// A linear map from data to pixels
+type plotScale struct {
+	dlo, dhi, plo, phi float64
+}
+
+func (s plotScale) Map(v float64) float64 {
+	if s.dhi == s.dlo {
+		return (s.plo + s.phi) / 2
+	}
+	return s.plo + (v - s.dlo) / (s.dhi - s.dlo) * (s.phi - s.plo)
+}
+
+// The y range of the data, padded by 5% as in ggplot2
+func dataYRange(pts []PlotPoint) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		for _, v := range []float64{p.Val, p.Lower, p.Upper} {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				lo = math.Min(lo, v)
+				hi = math.Max(hi, v)
+			}
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return 0, 1
+	}
+	if lo == hi {
+		return lo - 1, hi + 1
+	}
+	pad := (hi - lo) * 0.05
+	return lo - pad, hi + pad
+}
+
+// Chromosome label positions: the median x of each chromosome's points
+func chrLabelPositions(pts []PlotPoint) ([]string, []float64) {
+	xs := map[string][]float64{}
+	var chrs []string
+	for _, p := range pts {
+		if _, ok := xs[p.Chr]; !ok {
+			chrs = append(chrs, p.Chr)
+		}
+		xs[p.Chr] = append(xs[p.Chr], p.X())
+	}
+	pos := make([]float64, len(chrs))
+	for i, chr := range chrs {
+		pos[i] = aggMedian(xs[chr])
+	}
+	return chrs, pos
+}
+
+// Colors for every name: those in args.Colors, then the default palette
+func nameColors(names []string, colors map[string]string) (map[string]Color, error) {
+	out := map[string]Color{}
+	pal := HuePalette(len(names))
+	for i, name := range names {
+		out[name] = pal[i]
+		if s, ok := colors[name]; ok {
+			c, err := ParseColor(s)
+			if err != nil {
+				return nil, fmt.Errorf("nameColors: %w", err)
+			}
+			out[name] = c
+		}
+	}
+	return out, nil
+}
+
+// The plot spec that draws like the R multiline plots, as set up by args
+func (a NativePlotArgs) plotSpec(pts []PlotPoint, facets bool) (*PlotSpec, error) {
+	a.setDefaults()
+	sorted := a.Order != "input"
+	var allNames, allFacets []string
+	for _, p := range pts {
+		allNames = append(allNames, p.Name)
+		allFacets = append(allFacets, p.Facet)
+	}
+	names := uniqueStrings(allNames, sorted)
+	colors, err := nameColors(names, a.Colors)
+	if err != nil {
+		return nil, fmt.Errorf("plotSpec: %w", err)
+	}
+	var values []string
+	for _, name := range names {
+		values = append(values, colors[name].Hex())
+	}
+
+	s := &PlotSpec{
+		Geom: a.Geom,
+		Size: a.Size,
+		Color: "NAME",
+		Title: a.Title,
+		XLabel: a.XLabel,
+		YLabel: a.YLabel,
+		Width: a.Width,
+		Height: a.Height,
+		Theme: SpecTheme{FontSize: a.FontSize},
+		Styles: a.Styles,
+		Highlights: a.Highlights,
+		readBed: a.readBed,
+	}
+	s.Scales.Y.FacetLimits = a.FacetYlims
+	s.Scales.Color = SpecScale{Name: a.LegendTitle, Breaks: names, Values: values}
+	if facets {
+		s.Facet = "FACET"
+		s.Scales.Facet.Breaks = uniqueStrings(allFacets, sorted)
+		if len(a.Facets) > 0 {
+			s.Scales.Facet = facetsScale(a.Facets)
+			s.Scales.Y.FacetLimits = facetYlims(a.Facets, a.FacetYlims)
+		}
+	}
+	for _, p := range pts {
+		if !math.IsNaN(p.Lower) || !math.IsNaN(p.Upper) {
+			s.Lower, s.Upper = "LOWER", "UPPER"
+			break
+		}
+	}
+	return s, nil
+}
+
+// Draw a multiline plot, with one panel per facet if facets is true. Ylim
+// sets the y range if it has two different values; values outside it are not
+// drawn, and ribbons are clipped to it.
+func DrawMultiplot(c Canvas, pts []PlotPoint, ylim []float64, facets bool, args NativePlotArgs) error {
+	spec, err := args.plotSpec(pts, facets)
+	if err != nil {
+		return fmt.Errorf("DrawMultiplot: %w", err)
+	}
+	return DrawPlotSpec(c, pts, ylim, spec)
+}
+
+// Split points into runs sorted by x, breaking between chromosomes and at
+// points where ok is false
+func plotRuns(pts []PlotPoint, ok func(PlotPoint) bool) [][]PlotPoint {
+	sorted := append([]PlotPoint{}, pts...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].X() < sorted[j].X() })
+
+	var out [][]PlotPoint
+	var run []PlotPoint
+	for _, p := range sorted {
+		if !ok(p) || (len(run) > 0 && run[len(run)-1].Chr != p.Chr) {
+			if len(run) > 0 {
+				out = append(out, run)
+			}
+			run = nil
+		}
+		if ok(p) {
+			run = append(run, p)
+		}
+	}
+	if len(run) > 0 {
+		out = append(out, run)
+	}
+	return out
+}
+
+// Output files for native plots
+type PlotOutputCfg struct {
+	// Any of "svg", "png", and "pdf", or "none"; default svg
+	Formats []string `json:"formats"`
+	// Size in inches; overrides the plot's own size in pixels, at 96 pixels per inch
+	Width float64 `json:"width"`
+	Height float64 `json:"height"`
+	// PNG resolution; default 96
+	DPI float64 `json:"dpi"`
+	// Also draw all windows into one outpre_booklet.pdf
+	Booklet bool `json:"booklet"`
+}
+
+func (o *PlotOutputCfg) setDefaults() {
+	if len(o.Formats) == 0 { o.Formats = []string{"svg"} }
+	if o.DPI == 0 { o.DPI = 96 }
+}
+
+// Draw the same plot to one file per format, as outpre_plotted.<format>
+func WritePlotFormats(outpre string, width, height float64, out PlotOutputCfg, draw func(c Canvas) error) error {
+	h := Handle("WritePlotFormats: %w")
+	out.setDefaults()
+	if out.Width > 0 { width = out.Width * 96 }
+	if out.Height > 0 { height = out.Height * 96 }
+
+	for _, format := range out.Formats {
+		var wt io.WriterTo
+		switch strings.ToLower(format) {
+		case "svg":
+			c := NewSVGCanvas(width, height)
+			if err := draw(c); err != nil { return h(err) }
+			wt = c
+		case "png":
+			c := NewRasterCanvas(width, height, out.DPI / 96)
+			if err := draw(c); err != nil { return h(err) }
+			wt = c
+		case "pdf":
+			d := NewPDFDoc()
+			if err := draw(d.AddPage(width, height)); err != nil { return h(err) }
+			wt = d
+		case "none":
+			continue
+		default:
+			return h(fmt.Errorf("unknown format %q", format))
+		}
+
+		f, err := os.Create(outpre + "_plotted." + strings.ToLower(format))
+		if err != nil { return h(err) }
+		_, err = wt.WriteTo(f)
+		if e := f.Close(); err == nil { err = e }
+		if err != nil { return h(err) }
+	}
+	return nil
+}
+
+// Read outpre_plfmt.bed and draw it to outpre_plotted.svg, .png, and/or .pdf
+func PlotNative(outpre string, ylim []float64, layout PlfmtLayout, args NativePlotArgs, out PlotOutputCfg) error {
+	h := Handle("PlotNative: %w")
+
+	pts, err := ReadPlotPointsPath(outpre + "_plfmt.bed", layout)
+	if err != nil { return h(err) }
+	styles, err := ReadPlfmtSetStyles(outpre)
+	if err != nil { return h(err) }
+	args.Styles = mergeSetStyles(args.Styles, styles)
+
+	args.setDefaults()
+	err = WritePlotFormats(outpre, args.Width, args.Height, out, func(c Canvas) error {
+		return DrawMultiplot(c, pts, ylim, layout == PlfmtFacet, args)
+	})
+	if err != nil { return h(err) }
+	return nil
+}
+
+// Get a native plot function for a plfmt layout. Args must be of type NativePlotArgs.
+func PlotNativeAny(layout PlfmtLayout) func(outpre string, ylim []float64, args any, margs MultiplotPlotFuncArgs) error {
+	return func(outpre string, ylim []float64, anyargs any, margs MultiplotPlotFuncArgs) error {
+		var args NativePlotArgs
+		if err := UnmarshalJsonOut(anyargs, &args); err != nil {
+			return fmt.Errorf("PlotNativeAny: %w", err)
+		}
+		if margs.FacetYlims != nil {
+			args.FacetYlims = margs.FacetYlims
+		}
+		if len(args.Facets) == 0 {
+			args.Facets = margs.Cfg.Facets
+		}
+		args.Highlights = appendHighlights(args.Highlights, margs.Cfg.Highlights)
+		args.readBed = configHighlightReader(margs.Cfg, margs.Data)
+		return PlotNative(outpre, ylim, layout, args, margs.Cfg.PlotOutput)
+	}
+}
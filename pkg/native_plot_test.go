@@ -0,0 +1,156 @@
+package covplots
+
+import (
+	"encoding/xml"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNiceTicks(t *testing.T) {
+	out := NiceTicks(-3, 95, 5)
+	expect := []float64{0, 20, 40, 60, 80}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	ticks := NiceTicks(0, 1, 4)
+	labels := []string{}
+	for _, tick := range ticks {
+		labels = append(labels, FormatTick(tick, ticks))
+	}
+	expectLabels := []string{"0.00", "0.25", "0.50", "0.75", "1.00"}
+	if !reflect.DeepEqual(labels, expectLabels) {
+		t.Errorf("out %v != expect %v", labels, expectLabels)
+	}
+}
+
+func TestParsePlotPoint(t *testing.T) {
+	line := strings.Split("2L\t0\t10\t3.5\t1\t5\tixw\t0\t100\t110", "\t")
+	out, err := ParsePlotPoint(line, PlfmtRibbon)
+	if err != nil {
+		panic(err)
+	}
+	expect := PlotPoint{Chr: "2L", Val: 3.5, Lower: 1, Upper: 5, Name: "ixw", BpStart: 0, BpEnd: 10, Start: 100, End: 110}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	line = strings.Split("2L\t0\t10\t3.5\thead\tsawamura\tixw\t0\t100\t110", "\t")
+	out, err = ParsePlotPoint(line, PlfmtPlain)
+	if err != nil {
+		panic(err)
+	}
+	expectCols := []string{"head", "sawamura"}
+	if !reflect.DeepEqual(out.Cols, expectCols) {
+		t.Errorf("out %v != expect %v", out.Cols, expectCols)
+	}
+}
+
+func TestPlotNativeSVG(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t0\t10\t1\tf1\tixw\t0\t0\t10\n" +
+		"2L\t10\t20\t2\tf1\tixw\t0\t10\t20\n" +
+		"2L\t0\t10\t3\tf2\tixa & co\t0\t0\t10\n" +
+		"3R\t0\t10\t100\tf2\tixa & co\t1\t20\t30\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+
+	err := PlotNativeAny(PlfmtFacet)(outpre, []float64{0, 5}, map[string]any{"Colors": map[string]any{"ixw": "#000000"}}, MultiplotPlotFuncArgs{})
+	if err != nil {
+		panic(err)
+	}
+	svg, err := os.ReadFile(outpre + "_plotted.svg")
+	if err != nil {
+		panic(err)
+	}
+
+	d := xml.NewDecoder(strings.NewReader(string(svg)))
+	circles := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid svg: %v", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "circle" {
+			circles++
+		}
+	}
+	// Three points are inside ylim, plus two legend keys
+	if circles != 5 {
+		t.Errorf("circles %v != expect %v", circles, 5)
+	}
+	for _, want := range []string{"ixa &amp; co", "f1", "f2", "Raw coverage", `fill="#000000"`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("svg does not contain %q", want)
+		}
+	}
+}
+
+func TestPlotNativeFormats(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t0\t10\t1\tixw\t0\t0\t10\n" +
+		"2L\t10\t20\t2\tixa\t0\t10\t20\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+
+	var margs MultiplotPlotFuncArgs
+	margs.Cfg.PlotOutput = PlotOutputCfg{Formats: []string{"png", "pdf"}, Width: 4, Height: 2, DPI: 150}
+	if e := PlotNativeAny(PlfmtPlain)(outpre, nil, nil, margs); e != nil { panic(e) }
+
+	f, err := os.Open(outpre + "_plotted.png")
+	if err != nil { panic(err) }
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("invalid png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 600 || b.Dy() != 300 {
+		t.Errorf("png size %v != expect %v", b.Size(), "(600,300)")
+	}
+
+	pdf, err := os.ReadFile(outpre + "_plotted.pdf")
+	if err != nil { panic(err) }
+	for _, want := range []string{"%PDF-1.4", "/Count 1", "/MediaBox [0 0 288.00 144.00]", "%%EOF"} {
+		if !strings.Contains(string(pdf), want) {
+			t.Errorf("pdf does not contain %q", want)
+		}
+	}
+	if !strings.Contains(pdfContents(pdf), "(ixw) Tj") {
+		t.Errorf("pdf does not contain %q", "(ixw) Tj")
+	}
+	if _, err := os.Stat(outpre + "_plotted.svg"); err == nil {
+		t.Errorf("svg written when not requested")
+	}
+}
+
+func TestRasterTextWidth(t *testing.T) {
+	// PNG text must cover the same span as the Helvetica width used by SVG
+	// and PDF layout
+	for _, s := range []string{"WWW", "iii", "µΔé"} {
+		c := NewRasterCanvas(200, 50, 2)
+		c.Text(100, 40, s, TextStyle{Size: 20, Color: Black, Anchor: "end"})
+		left, right := -1, -1
+		b := c.Img.Bounds()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				if c.Img.RGBAAt(x, y).A > 0 {
+					if left < 0 {
+						left = x
+					}
+					right = x + 1
+					break
+				}
+			}
+		}
+		w := TextWidth(s, 20) * 2
+		if left < 0 || float64(right) > 200 + 1 || float64(left) < 200 - w - 1 || float64(right - left) < w * 0.7 {
+			t.Errorf("%v: ink from %v to %v, expected within %v to 200", s, left, right, 200 - w)
+		}
+	}
+}
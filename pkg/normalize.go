@@ -0,0 +1,688 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/montanaflynn/stats"
+)
+
+// Arguments for the normalize function. All fields are optional; the default is a z-score over all records.
+type NormalizeArgs struct {
+	// zscore (default), robust_z, minmax, cpm, rpkm, percentile, winsorize, or quantile
+	Method string
+	// Total count for cpm and rpkm; if 0, the sum of all values is used
+	Total float64
+	// Lower and upper percentiles (0-100) for winsorize; default [1, 99]
+	Quantiles []float64
+	// Normalize each group separately: "chr" for each chromosome, or a 0-based column index
+	Group any
+	// Weight each record by its span length in zscore, robust_z, percentile,
+	// and winsorize statistics, and count value * length toward cpm and rpkm totals
+	BpWeighted bool
+}
+
+// Configuration for quantile normalization across input sets
+type QuantileNormalizeCfg struct {
+	// Names of the input sets to normalize together
+	Sets []string
+	// Same as NormalizeArgs.Group
+	Group any
+}
+
+// The lines of a 4-column bed file, their values, and span lengths
+type normData struct {
+	lines [][]string
+	vals []float64
+	lens []float64
+}
+
+func readNormData(r io.Reader) (normData, error) {
+	var d normData
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		line := strings.Split(s.Text(), "\t")
+		if len(line) < 4 {
+			return d, fmt.Errorf("readNormData: line %v has length %v < 4", line, len(line))
+		}
+		d.lines = append(d.lines, line)
+		d.vals = append(d.vals, AlwaysParseFloat(line[3]))
+		d.lens = append(d.lens, AlwaysParseFloat(line[2]) - AlwaysParseFloat(line[1]))
+	}
+	if s.Err() != nil {
+		return d, fmt.Errorf("readNormData: %w", s.Err())
+	}
+	return d, nil
+}
+
+func (d normData) reader() io.Reader {
+	var out strings.Builder
+	for i, line := range d.lines {
+		line[3] = fmt.Sprintf("%f", d.vals[i])
+		fmt.Fprintln(&out, strings.Join(line, "\t"))
+	}
+	return strings.NewReader(out.String())
+}
+
+// Get a function that gives the normalization group of a line
+func NormGroupFunc(group any) (func(line []string) string, error) {
+	switch g := group.(type) {
+	case nil:
+		return func([]string) string { return "" }, nil
+	case string:
+		if g != "chr" {
+			return nil, fmt.Errorf("NormGroupFunc: unknown group %q", g)
+		}
+		return func(line []string) string { return line[0] }, nil
+	case float64:
+		col := int(g)
+		return func(line []string) string {
+			if len(line) <= col {
+				return ""
+			}
+			return line[col]
+		}, nil
+	}
+	return nil, fmt.Errorf("NormGroupFunc: group %v is not \"chr\" or a column index", group)
+}
+
+func pick[T any](ts []T, idxs []int) []T {
+	out := make([]T, len(idxs))
+	for i, idx := range idxs {
+		out[i] = ts[idx]
+	}
+	return out
+}
+
+func nanFree(vals []float64) []float64 {
+	var out []float64
+	for _, v := range vals {
+		if !math.IsNaN(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func mapFloats(vals []float64, f func(float64) float64) []float64 {
+	out := make([]float64, len(vals))
+	for i, v := range vals {
+		out[i] = f(v)
+	}
+	return out
+}
+
+// A normalization fitted to reference values, applied to one value and the
+// length of its span
+type normApply func(v, l float64) float64
+
+// Apply f to every value. Lens may be nil if f does not use them.
+func applyNorm(f normApply, vals, lens []float64) []float64 {
+	out := make([]float64, len(vals))
+	for i, v := range vals {
+		l := 0.0
+		if lens != nil {
+			l = lens[i]
+		}
+		out[i] = f(v, l)
+	}
+	return out
+}
+
+func fitZscore(ref []float64) normApply {
+	nf := nanFree(ref)
+	m, err := stats.Mean(nf)
+	if err != nil {
+		m = 0
+	}
+	s, err := stats.StdDevP(nf)
+	if err != nil {
+		s = 1
+	}
+	return func(v, l float64) float64 { return (v-m) / s }
+}
+
+func robustZApply(med, mad float64) normApply {
+	return func(v, l float64) float64 { return (v - med) / (1.4826 * mad) }
+}
+
+func fitRobustZ(ref []float64) normApply {
+	nf := nanFree(ref)
+	med, err := stats.Median(nf)
+	if err != nil {
+		med = 0
+	}
+	mad, err := stats.MedianAbsoluteDeviationPopulation(nf)
+	if err != nil || mad == 0 {
+		mad = 1 / 1.4826
+	}
+	return robustZApply(med, mad)
+}
+
+// Subtract the median and divide by the scaled median absolute deviation
+func RobustZFloats(vals []float64) []float64 {
+	return applyNorm(fitRobustZ(vals), vals, nil)
+}
+
+func fitMinMax(ref []float64) normApply {
+	nf := nanFree(ref)
+	lo, err1 := stats.Min(nf)
+	hi, err2 := stats.Max(nf)
+	if err1 != nil || err2 != nil || hi == lo {
+		return func(v, l float64) float64 {
+			if math.IsNaN(v) {
+				return v
+			}
+			return 0
+		}
+	}
+	return func(v, l float64) float64 { return (v - lo) / (hi - lo) }
+}
+
+// Scale values to the range 0 to 1
+func MinMaxFloats(vals []float64) []float64 {
+	return applyNorm(fitMinMax(vals), vals, nil)
+}
+
+func normTotal(vals []float64, total float64) float64 {
+	if total != 0 {
+		return total
+	}
+	sum, err := stats.Sum(nanFree(vals))
+	if err != nil || sum == 0 {
+		return 1
+	}
+	return sum
+}
+
+func weightedNormTotal(vals, lens []float64, total float64) float64 {
+	if total != 0 {
+		return total
+	}
+	vals, lens = weightedNanFree(vals, lens)
+	sum := 0.0
+	for i, v := range vals {
+		sum += v * lens[i]
+	}
+	if sum == 0 {
+		return 1
+	}
+	return sum
+}
+
+func cpmApply(total float64) normApply {
+	return func(v, l float64) float64 { return v / total * 1e6 }
+}
+
+func rpkmApply(total float64) normApply {
+	return func(v, l float64) float64 { return v / (l / 1e3) / (total / 1e6) }
+}
+
+// Counts per million of total
+func CpmFloats(vals []float64, total float64) []float64 {
+	return applyNorm(cpmApply(normTotal(vals, total)), vals, nil)
+}
+
+// Counts per million, with the default total counting each value once per bp of its span
+func WeightedCpmFloats(vals, lens []float64, total float64) []float64 {
+	return applyNorm(cpmApply(weightedNormTotal(vals, lens, total)), vals, lens)
+}
+
+// Counts per kilobase of span per million of total
+func RpkmFloats(vals, lens []float64, total float64) []float64 {
+	return applyNorm(rpkmApply(normTotal(vals, total)), vals, lens)
+}
+
+// RPKM, with the default total counting each value once per bp of its span
+func WeightedRpkmFloats(vals, lens []float64, total float64) []float64 {
+	return applyNorm(rpkmApply(weightedNormTotal(vals, lens, total)), vals, lens)
+}
+
+// The number of sorted values below v, and the number equal to it
+func sortedRank(sorted []float64, v float64) (below, equal int) {
+	lo := sort.SearchFloat64s(sorted, v)
+	hi := sort.Search(len(sorted), func(i int) bool { return sorted[i] > v })
+	return lo, hi - lo
+}
+
+func sortedNanFree(vals []float64) []float64 {
+	sorted := nanFree(vals)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// The fraction of ref below a value, counting values equal to it as half
+// below
+func fitRankFrac(ref []float64) func(v float64) float64 {
+	sorted := sortedNanFree(ref)
+	n := float64(len(sorted))
+	return func(v float64) float64 {
+		if math.IsNaN(v) {
+			return v
+		}
+		below, equal := sortedRank(sorted, v)
+		return (float64(below) + float64(equal) / 2) / n
+	}
+}
+
+func fitPercentile(ref []float64) normApply {
+	frac := fitRankFrac(ref)
+	return func(v, l float64) float64 { return 100 * frac(v) }
+}
+
+// Convert values to percentile ranks from 0 to 100
+func PercentileRankFloats(vals []float64) []float64 {
+	return applyNorm(fitPercentile(vals), vals, nil)
+}
+
+func clampApply(lo, hi float64) normApply {
+	return func(v, l float64) float64 {
+		if math.IsNaN(v) {
+			return v
+		}
+		return math.Max(lo, math.Min(hi, v))
+	}
+}
+
+func fitWinsorize(ref []float64, lowPerc, highPerc float64) normApply {
+	nf := nanFree(ref)
+	lo, err1 := stats.Percentile(nf, lowPerc)
+	hi, err2 := stats.Percentile(nf, highPerc)
+	if err1 != nil || err2 != nil {
+		return func(v, l float64) float64 { return v }
+	}
+	return clampApply(lo, hi)
+}
+
+// Clamp values to the given lower and upper percentiles
+func WinsorizeFloats(vals []float64, lowPerc, highPerc float64) []float64 {
+	return applyNorm(fitWinsorize(vals, lowPerc, highPerc), vals, nil)
+}
+
+// Linearly interpolate a sorted slice at quantile p (0 to 1)
+func interpSorted(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := p * float64(len(sorted)) - 0.5
+	if pos <= 0 {
+		return sorted[0]
+	}
+	if pos >= float64(len(sorted) - 1) {
+		return sorted[len(sorted) - 1]
+	}
+	i := int(pos)
+	frac := pos - float64(i)
+	return sorted[i] + frac * (sorted[i+1] - sorted[i])
+}
+
+// Fit quantile normalization to reference sets: each set's values are mapped
+// to the mean of the sets' quantile functions at their rank in that set
+func fitQuantile(sets [][]float64) []normApply {
+	var sorteds [][]float64
+	m := 0
+	for _, set := range sets {
+		sorted := sortedNanFree(set)
+		if len(sorted) == 0 {
+			continue
+		}
+		sorteds = append(sorteds, sorted)
+		if len(sorted) > m {
+			m = len(sorted)
+		}
+	}
+
+	ref := make([]float64, m)
+	for k := range ref {
+		p := (float64(k) + 0.5) / float64(m)
+		for _, sorted := range sorteds {
+			ref[k] += interpSorted(sorted, p)
+		}
+		ref[k] /= float64(len(sorteds))
+	}
+
+	out := make([]normApply, len(sets))
+	for i, set := range sets {
+		frac := fitRankFrac(set)
+		out[i] = func(v, l float64) float64 {
+			p := frac(v)
+			if math.IsNaN(p) {
+				return p
+			}
+			return interpSorted(ref, p)
+		}
+	}
+	return out
+}
+
+// Give every set of values the same distribution: the mean of the sets'
+// quantile functions. Sets may differ in size; ties share their average rank.
+func QuantileNormalizeFloats(sets [][]float64) [][]float64 {
+	fits := fitQuantile(sets)
+	out := make([][]float64, len(sets))
+	for i, set := range sets {
+		out[i] = applyNorm(fits[i], set, nil)
+	}
+	return out
+}
+
+// Get the function that fits one normalization method to a group of
+// reference values and their span lengths
+func NormFitFunc(args NormalizeArgs) (func(vals, lens []float64) normApply, error) {
+	q := args.Quantiles
+	if q == nil {
+		q = []float64{1, 99}
+	}
+	if args.Method == "winsorize" && len(q) != 2 {
+		return nil, fmt.Errorf("NormFitFunc: winsorize needs 2 quantiles, not %v", q)
+	}
+
+	if args.BpWeighted {
+		switch args.Method {
+		case "", "zscore":
+			return fitWeightedZscore, nil
+		case "robust_z":
+			return fitWeightedRobustZ, nil
+		case "cpm":
+			return func(vals, lens []float64) normApply { return cpmApply(weightedNormTotal(vals, lens, args.Total)) }, nil
+		case "rpkm":
+			return func(vals, lens []float64) normApply { return rpkmApply(weightedNormTotal(vals, lens, args.Total)) }, nil
+		case "percentile":
+			return fitWeightedPercentile, nil
+		case "winsorize":
+			return func(vals, lens []float64) normApply { return fitWeightedWinsorize(vals, lens, q[0], q[1]) }, nil
+		}
+	}
+
+	switch args.Method {
+	case "", "zscore":
+		return func(vals, lens []float64) normApply { return fitZscore(vals) }, nil
+	case "robust_z":
+		return func(vals, lens []float64) normApply { return fitRobustZ(vals) }, nil
+	case "minmax":
+		return func(vals, lens []float64) normApply { return fitMinMax(vals) }, nil
+	case "cpm":
+		return func(vals, lens []float64) normApply { return cpmApply(normTotal(vals, args.Total)) }, nil
+	case "rpkm":
+		return func(vals, lens []float64) normApply { return rpkmApply(normTotal(vals, args.Total)) }, nil
+	case "percentile":
+		return func(vals, lens []float64) normApply { return fitPercentile(vals) }, nil
+	case "winsorize":
+		return func(vals, lens []float64) normApply { return fitWinsorize(vals, q[0], q[1]) }, nil
+	}
+	return nil, fmt.Errorf("NormFitFunc: unknown method %q", args.Method)
+}
+
+// Get the function that applies one normalization method to a group of values
+func NormMethodFunc(args NormalizeArgs) (func(vals, lens []float64) []float64, error) {
+	fit, err := NormFitFunc(args)
+	if err != nil {
+		return nil, fmt.Errorf("NormMethodFunc: %w", err)
+	}
+	return func(vals, lens []float64) []float64 { return applyNorm(fit(vals, lens), vals, lens) }, nil
+}
+
+// The normalization of each group of each reader, by group key
+type normFits []map[string]normApply
+
+// Split the line indices of every data set into groups. Groups are listed in
+// order of first appearance, and each has the indices of every data set.
+func normGroupsAcross(ds []normData, key func([]string) string) ([]string, map[string][][]int) {
+	var groups []string
+	idxs := map[string][][]int{}
+	for i, d := range ds {
+		for j, line := range d.lines {
+			k := key(line)
+			if _, ok := idxs[k]; !ok {
+				idxs[k] = make([][]int, len(ds))
+				groups = append(groups, k)
+			}
+			idxs[k][i] = append(idxs[k][i], j)
+		}
+	}
+	return groups, idxs
+}
+
+// Fit the normalization method of args to each group of each data set.
+// Quantile normalization is fitted across all data sets together, and every
+// other method to each data set alone.
+func fitNormData(ds []normData, args NormalizeArgs) (normFits, error) {
+	h := Handle("fitNormData: %w")
+
+	key, err := NormGroupFunc(args.Group)
+	if err != nil { return nil, h(err) }
+	var fit func(vals, lens []float64) normApply
+	if args.Method != "quantile" {
+		if fit, err = NormFitFunc(args); err != nil { return nil, h(err) }
+	}
+
+	out := make(normFits, len(ds))
+	for i := range out {
+		out[i] = map[string]normApply{}
+	}
+	groups, groupidxs := normGroupsAcross(ds, key)
+	for _, k := range groups {
+		idxs := groupidxs[k]
+		if args.Method == "quantile" {
+			sets := make([][]float64, len(ds))
+			for i, d := range ds {
+				sets[i] = pick(d.vals, idxs[i])
+			}
+			for i, f := range fitQuantile(sets) {
+				out[i][k] = f
+			}
+			continue
+		}
+		for i, d := range ds {
+			if len(idxs[i]) > 0 {
+				out[i][k] = fit(pick(d.vals, idxs[i]), pick(d.lens, idxs[i]))
+			}
+		}
+	}
+	return out, nil
+}
+
+// Normalize every data set in place with fits. Groups that were not in the
+// data the fits came from are fitted to their own values.
+func applyNormData(ds []normData, fits normFits, args NormalizeArgs) error {
+	h := Handle("applyNormData: %w")
+	if len(fits) != len(ds) {
+		return h(fmt.Errorf("%v fitted data sets != %v data sets", len(fits), len(ds)))
+	}
+	key, err := NormGroupFunc(args.Group)
+	if err != nil { return h(err) }
+
+	var own normFits
+	for i, d := range ds {
+		for j, line := range d.lines {
+			k := key(line)
+			f, ok := fits[i][k]
+			if !ok {
+				if own == nil {
+					if own, err = fitNormData(ds, args); err != nil { return h(err) }
+				}
+				f = own[i][k]
+			}
+			d.vals[j] = f(d.vals[j], d.lens[j])
+		}
+	}
+	return nil
+}
+
+func readNormDatas(rs []io.Reader) ([]normData, error) {
+	ds := make([]normData, len(rs))
+	for i, r := range rs {
+		var err error
+		if ds[i], err = readNormData(r); err != nil {
+			return nil, err
+		}
+	}
+	return ds, nil
+}
+
+func normDataReaders(ds []normData) []io.Reader {
+	out := make([]io.Reader, len(ds))
+	for i, d := range ds {
+		out[i] = d.reader()
+	}
+	return out
+}
+
+// Normalize one reader, separately for each group. Quantile normalization of
+// one reader only averages tied values; use NormalizeWith to quantile
+// normalize several readers together.
+func NormalizeOne(r io.Reader, args NormalizeArgs) (io.Reader, error) {
+	h := Handle("NormalizeOne: %w")
+
+	if args.Method != "quantile" {
+		if _, err := NormFitFunc(args); err != nil { return nil, h(err) }
+	}
+	outs, err := NormalizeWith([]io.Reader{r}, args)
+	if err != nil { return nil, h(err) }
+	return outs[0], nil
+}
+
+// Quantile normalize a set of readers together, separately for each group
+func QuantileNormalizeReaders(rs []io.Reader, group any) ([]io.Reader, error) {
+	outs, err := NormalizeWith(rs, NormalizeArgs{Method: "quantile", Group: group})
+	if err != nil {
+		return nil, fmt.Errorf("QuantileNormalizeReaders: %w", err)
+	}
+	return outs, nil
+}
+
+// Normalize every reader with the chosen method. Quantile normalization
+// is done across all readers together; everything else is done per reader.
+func NormalizeWith(rs []io.Reader, args NormalizeArgs) ([]io.Reader, error) {
+	return NormalizeWithReference(rs, nil, args)
+}
+
+// Like NormalizeWith, but with the statistics of every method taken from
+// refs, one for each reader, rather than from rs. Nil refs means rs.
+func NormalizeWithReference(rs, refs []io.Reader, args NormalizeArgs) ([]io.Reader, error) {
+	h := Handle("NormalizeWithReference: %w")
+
+	ds, err := readNormDatas(rs)
+	if err != nil { return nil, h(err) }
+	refds := ds
+	if refs != nil {
+		if refds, err = readNormDatas(refs); err != nil { return nil, h(err) }
+	}
+	fits, err := fitNormData(refds, args)
+	if err != nil { return nil, h(err) }
+	if err = applyNormData(ds, fits, args); err != nil { return nil, h(err) }
+	return normDataReaders(ds), nil
+}
+
+// Normalize the readers of one window. The statistics are fitted once per
+// config to the same pipeline over the whole genome, so that every window is
+// normalized the same way.
+func NormalizeCtx(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("NormalizeCtx: %w")
+
+	args, err := parseNormalizeArgs(anyargs)
+	if err != nil { return nil, h(err) }
+	if ctx.Fullchr || ctx.wholeInput == nil {
+		outs, err := NormalizeWith(rs, args)
+		if err != nil { return nil, h(err) }
+		return outs, nil
+	}
+
+	fits, err := configCached(ctx.Data, ctx.wholeInputKey, func() (normFits, error) {
+		refs, closers, err := ctx.wholeInput()
+		if err != nil { return nil, err }
+		defer CloseAny(closers...)
+		refds, err := readNormDatas(refs)
+		if err != nil { return nil, err }
+		return fitNormData(refds, args)
+	})
+	if err != nil { return nil, h(err) }
+
+	ds, err := readNormDatas(rs)
+	if err != nil { return nil, h(err) }
+	if err = applyNormData(ds, fits, args); err != nil { return nil, h(err) }
+	return normDataReaders(ds), nil
+}
+
+// The indices of the input sets named in qcfg
+func quantileSetIdxs(sets []InputSet, qcfg QuantileNormalizeCfg) ([]int, error) {
+	var idxs []int
+	for _, name := range qcfg.Sets {
+		found := false
+		for i, set := range sets {
+			if set.Name == name {
+				idxs = append(idxs, i)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no input set named %v", name)
+		}
+	}
+	return idxs, nil
+}
+
+// Quantile normalize the final streams of the input sets named in qcfg, replacing them in rs
+func QuantileNormalizeSets(rs []io.Reader, sets []InputSet, qcfg QuantileNormalizeCfg) ([]io.Reader, error) {
+	h := Handle("QuantileNormalizeSets: %w")
+
+	idxs, err := quantileSetIdxs(sets, qcfg)
+	if err != nil { return nil, h(err) }
+
+	normed, err := QuantileNormalizeReaders(pick(rs, idxs), qcfg.Group)
+	if err != nil { return nil, h(err) }
+
+	out := make([]io.Reader, len(rs))
+	copy(out, rs)
+	for i, idx := range idxs {
+		out[idx] = normed[i]
+	}
+	return out, nil
+}
+
+// QuantileNormalizeSets for one window of ctx's config. The quantiles are
+// fitted once per config to the sets' streams over the whole genome.
+func QuantileNormalizeSetsCtx(rs []io.Reader, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("QuantileNormalizeSetsCtx: %w")
+
+	sets, qcfg := ctx.Cfg.InputSets, ctx.Cfg.QuantileNormalize
+	if ctx.Fullchr {
+		out, err := QuantileNormalizeSets(rs, sets, qcfg)
+		if err != nil { return nil, h(err) }
+		return out, nil
+	}
+	idxs, err := quantileSetIdxs(sets, qcfg)
+	if err != nil { return nil, h(err) }
+
+	args := NormalizeArgs{Method: "quantile", Group: qcfg.Group}
+	fits, err := configCached(ctx.Data, "quantilenormalize", func() (normFits, error) {
+		whole := ctx.wholeGenome()
+		refs := make([]io.Reader, len(idxs))
+		for i, idx := range idxs {
+			r, closers, err := MultiplotInputSet(sets[idx], whole)
+			if err != nil { return nil, err }
+			defer CloseAny(closers...)
+			refs[i] = r
+		}
+		refds, err := readNormDatas(refs)
+		if err != nil { return nil, err }
+		return fitNormData(refds, args)
+	})
+	if err != nil { return nil, h(err) }
+
+	ds, err := readNormDatas(pick(rs, idxs))
+	if err != nil { return nil, h(err) }
+	if err = applyNormData(ds, fits, args); err != nil { return nil, h(err) }
+
+	out := make([]io.Reader, len(rs))
+	copy(out, rs)
+	for i, idx := range idxs {
+		out[idx] = ds[i].reader()
+	}
+	return out, nil
+}
@@ -0,0 +1,224 @@
+package covplots
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuantileNormalizeFloats(t *testing.T) {
+	sets := [][]float64{
+		[]float64{5, 2, 3, 4},
+		[]float64{4, 1, 4, 2},
+		[]float64{3, 4, 6, 8},
+	}
+	expect := [][]float64{
+		[]float64{5.666666666666667, 2, 3, 4.666666666666667},
+		[]float64{5.166666666666667, 2, 5.166666666666667, 3},
+		[]float64{2, 3, 4.666666666666667, 5.666666666666667},
+	}
+
+	out := QuantileNormalizeFloats(sets)
+	for i := range out {
+		for j := range out[i] {
+			if math.Abs(out[i][j] - expect[i][j]) > 1e-9 {
+				t.Errorf("out %v != expect %v", out, expect)
+				return
+			}
+		}
+	}
+}
+
+func TestPercentileRankFloats(t *testing.T) {
+	out := PercentileRankFloats([]float64{10, 30, 20, 20, math.NaN()})
+	expect := []float64{12.5, 87.5, 50, 50}
+	if !reflect.DeepEqual(out[:4], expect) || !math.IsNaN(out[4]) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestNormalizeGrouped(t *testing.T) {
+	in := "2L\t0\t10\t1\n2L\t10\t20\t3\nX\t0\t10\t10\nX\t10\t20\t20\n"
+	expect := "2L\t0\t10\t0.000000\n2L\t10\t20\t1.000000\nX\t0\t10\t0.000000\nX\t10\t20\t1.000000\n"
+
+	rs, e := Normalize([]io.Reader{strings.NewReader(in)}, map[string]any{"Method": "minmax", "Group": "chr"})
+	if e != nil { panic(e) }
+
+	var b strings.Builder
+	io.Copy(&b, rs[0])
+	if b.String() != expect {
+		t.Errorf("b.String() %v != expect %v", b.String(), expect)
+	}
+}
+
+func TestBpWeightedNormalize(t *testing.T) {
+	// A merged run of 3 bp at value 1 should count the same as 3 one-bp lines
+	merged := "chr1\t0\t3\t1\nchr1\t3\t4\t5\n"
+	split := "chr1\t0\t1\t1\nchr1\t1\t2\t1\nchr1\t2\t3\t1\nchr1\t3\t4\t5\n"
+
+	r, err := NormalizeOne(strings.NewReader(merged), NormalizeArgs{BpWeighted: true})
+	if err != nil {
+		panic(err)
+	}
+	mout, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+
+	r, err = NormalizeOne(strings.NewReader(split), NormalizeArgs{})
+	if err != nil {
+		panic(err)
+	}
+	sout, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+
+	mlines := strings.Split(strings.TrimSpace(string(mout)), "\n")
+	slines := strings.Split(strings.TrimSpace(string(sout)), "\n")
+	mval := strings.Split(mlines[0], "\t")[3]
+	sval := strings.Split(slines[0], "\t")[3]
+	if mval != sval {
+		t.Errorf("out %v != expect %v", mval, sval)
+	}
+}
+
+func TestFilterByStdevsWeighted(t *testing.T) {
+	entries := []BedEntry{
+		BedEntry{"chr1", 0, 1000, []string{"10"}},
+		BedEntry{"chr1", 1000, 1001, []string{"0"}},
+		BedEntry{"chr1", 1001, 1002, []string{"0"}},
+	}
+	out, err := FilterByStdevsWeighted(0, 1, true, entries...)
+	if err != nil {
+		panic(err)
+	}
+	if len(out) != 1 || out[0].Start != 0 {
+		t.Errorf("out %v != expect %v", out, entries[:1])
+	}
+}
+
+// The lines of a window's output of one input set, or of the whole genome if
+// start is -1
+func normWindowLines(set InputSet, data *ConfigData, start, end int) []string {
+	ctx := MultiplotFuncCtx{Chr: "chr1", Start: start, End: end, Data: data}
+	if start == -1 {
+		ctx = MultiplotFuncCtx{Chr: "full_genome", Fullchr: true, Data: data}
+	}
+	r, closers, err := MultiplotInputSet(set, ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer CloseAny(closers...)
+	out, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestNormalizeWindows(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.bed")
+	if e := os.WriteFile(in, []byte("chr1\t0\t50\t1\nchr1\t50\t100\t2\nchr1\t100\t150\t30\nchr1\t150\t200\t40\n"), 0644); e != nil { panic(e) }
+
+	for _, method := range []string{"zscore", "robust_z", "minmax", "cpm", "rpkm", "percentile", "winsorize"} {
+		set := InputSet{Paths: []string{in}, Name: "in", Functions: []string{"normalize"}, FunctionArgs: []any{map[string]any{"Method": method}}}
+		data := new(ConfigData)
+		// Each window is normalized like the whole genome, not on its own
+		whole := normWindowLines(set, data, -1, -1)
+		out := append(normWindowLines(set, data, 0, 100), normWindowLines(set, data, 100, 200)...)
+		if !reflect.DeepEqual(out, whole) {
+			t.Errorf("%v: out %v != expect %v", method, out, whole)
+		}
+	}
+}
+
+func TestQuantileNormalizeSetsWindows(t *testing.T) {
+	dir := t.TempDir()
+	in1 := filepath.Join(dir, "in1.bed")
+	if e := os.WriteFile(in1, []byte("chr1\t0\t100\t1\nchr1\t100\t200\t2\n"), 0644); e != nil { panic(e) }
+	in2 := filepath.Join(dir, "in2.bed")
+	if e := os.WriteFile(in2, []byte("chr1\t0\t100\t10\nchr1\t100\t200\t20\n"), 0644); e != nil { panic(e) }
+
+	var cfg UltimateConfig
+	cfg.InputSets = []InputSet{{Paths: []string{in1}, Name: "a"}, {Paths: []string{in2}, Name: "b"}}
+	cfg.QuantileNormalize = QuantileNormalizeCfg{Sets: []string{"a", "b"}}
+	data := new(ConfigData)
+	var vals []string
+	for _, start := range []int{0, 100} {
+		ctx := MultiplotFuncCtx{Cfg: cfg, Chr: "chr1", Start: start, End: start + 100, Data: data}
+		var rs []io.Reader
+		for _, set := range cfg.InputSets {
+			r, closers, err := MultiplotInputSet(set, ctx)
+			if err != nil {
+				panic(err)
+			}
+			defer CloseAny(closers...)
+			rs = append(rs, r)
+		}
+		rs, err := QuantileNormalizeSetsCtx(rs, ctx)
+		if err != nil {
+			panic(err)
+		}
+		for _, r := range rs {
+			out, err := io.ReadAll(r)
+			if err != nil {
+				panic(err)
+			}
+			vals = append(vals, strings.Split(strings.TrimSpace(string(out)), "\t")[3])
+		}
+	}
+	// Each window has one value per set; over the genome, the lower values of
+	// both sets map to 5.5 and the higher ones to 11
+	expect := []string{"5.500000", "5.500000", "11.000000", "11.000000"}
+	if !reflect.DeepEqual(vals, expect) {
+		t.Errorf("out %v != expect %v", vals, expect)
+	}
+}
+
+func TestBpWeightedNormalizeWindows(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.bed")
+	if e := os.WriteFile(in, []byte("chr1\t0\t90\t1\nchr1\t90\t100\t2\nchr1\t100\t110\t30\nchr1\t110\t200\t40\n"), 0644); e != nil { panic(e) }
+
+	for _, method := range []string{"zscore", "robust_z", "cpm", "rpkm", "percentile", "winsorize"} {
+		args := map[string]any{"Method": method, "BpWeighted": true}
+		set := InputSet{Paths: []string{in}, Name: "in", Functions: []string{"normalize"}, FunctionArgs: []any{args}}
+		data := new(ConfigData)
+		whole := normWindowLines(set, data, -1, -1)
+		out := append(normWindowLines(set, data, 0, 100), normWindowLines(set, data, 100, 200)...)
+		if !reflect.DeepEqual(out, whole) {
+			t.Errorf("%v: out %v != expect %v", method, out, whole)
+		}
+	}
+}
+
+func TestNormalizeOneQuantile(t *testing.T) {
+	in := "chr1\t0\t1\t3\nchr1\t1\t2\t1\nchr1\t2\t3\t2\nchr1\t3\t4\t1\n"
+	r, err := NormalizeOne(strings.NewReader(in), NormalizeArgs{Method: "quantile"})
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		panic(err)
+	}
+	rs, err := NormalizeWith([]io.Reader{strings.NewReader(in)}, NormalizeArgs{Method: "quantile"})
+	if err != nil {
+		panic(err)
+	}
+	expect, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	if string(out) != string(expect) {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+
+	if _, err := NormalizeOne(strings.NewReader(in), NormalizeArgs{Method: "bogus"}); err == nil {
+		t.Errorf("no error for an unknown method")
+	}
+}
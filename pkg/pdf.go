@@ -0,0 +1,299 @@
+package covplots
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// PDF points per drawing pixel (pixels are 1/96 inch)
+const pdfPtPerPx = 72.0 / 96.0
+
+// A multi-page PDF document. Each page is a Canvas.
+type PDFDoc struct {
+	Pages []*PDFCanvas
+	// Alpha values in use, by graphics state name
+	alphas map[uint8]string
+}
+
+func NewPDFDoc() *PDFDoc {
+	return &PDFDoc{alphas: map[uint8]string{}}
+}
+
+// Start a new page of w by h drawing pixels
+func (d *PDFDoc) AddPage(w, h float64) *PDFCanvas {
+	p := &PDFCanvas{W: w, H: h, doc: d}
+	fmt.Fprintf(&p.b, "%.4f 0 0 %.4f 0 %.2f cm\n1 j 1 J\n", pdfPtPerPx, -pdfPtPerPx, h * pdfPtPerPx)
+	d.Pages = append(d.Pages, p)
+	return p
+}
+
+// A Canvas that draws one page of a PDFDoc
+type PDFCanvas struct {
+	W, H float64
+	b bytes.Buffer
+	doc *PDFDoc
+}
+
+func (c *PDFCanvas) Size() (float64, float64) { return c.W, c.H }
+
+func (c *PDFCanvas) alphaState(a uint8) string {
+	name, ok := c.doc.alphas[a]
+	if !ok {
+		name = fmt.Sprintf("GS%d", len(c.doc.alphas))
+		c.doc.alphas[a] = name
+	}
+	return name
+}
+
+// Set colors for st and return the painting operator, or "" if nothing is
+// painted
+func (c *PDFCanvas) setStyle(st Style, closed bool) string {
+	fill := closed && st.Fill.A != 0
+	stroke := st.Stroke.A != 0 && st.StrokeWidth > 0
+	if fill {
+		fmt.Fprintf(&c.b, "%s rg\n", pdfRGB(st.Fill))
+	}
+	if stroke {
+		fmt.Fprintf(&c.b, "%s RG %.3g w\n", pdfRGB(st.Stroke), st.StrokeWidth)
+		if len(st.Dash) > 0 {
+			c.b.WriteString("[")
+			for _, d := range st.Dash {
+				fmt.Fprintf(&c.b, " %.3g", d)
+			}
+			c.b.WriteString(" ] 0 d\n")
+		}
+	}
+	// One alpha is shared by fill and stroke; the fill's wins
+	switch {
+	case fill && st.Fill.A != 255:
+		fmt.Fprintf(&c.b, "/%s gs\n", c.alphaState(st.Fill.A))
+	case !fill && stroke && st.Stroke.A != 255:
+		fmt.Fprintf(&c.b, "/%s gs\n", c.alphaState(st.Stroke.A))
+	}
+	switch {
+	case fill && stroke:
+		return "B"
+	case fill:
+		return "f"
+	case stroke:
+		return "S"
+	}
+	return ""
+}
+
+func pdfRGB(col Color) string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(col.R) / 255, float64(col.G) / 255, float64(col.B) / 255)
+}
+
+func (c *PDFCanvas) path(xs, ys []float64, closed bool, st Style) {
+	if len(xs) == 0 {
+		return
+	}
+	c.b.WriteString("q\n")
+	op := c.setStyle(st, closed)
+	if op != "" {
+		for i := range xs {
+			verb := "l"
+			if i == 0 {
+				verb = "m"
+			}
+			fmt.Fprintf(&c.b, "%.2f %.2f %s\n", xs[i], ys[i], verb)
+		}
+		if closed {
+			c.b.WriteString("h ")
+		}
+		c.b.WriteString(op + "\n")
+	}
+	c.b.WriteString("Q\n")
+}
+
+func (c *PDFCanvas) Rect(x, y, w, h float64, st Style) {
+	c.path([]float64{x, x + w, x + w, x}, []float64{y, y, y + h, y + h}, true, st)
+}
+
+func (c *PDFCanvas) Polyline(xs, ys []float64, st Style) {
+	c.path(xs, ys, false, st)
+}
+
+func (c *PDFCanvas) Polygon(xs, ys []float64, st Style) {
+	c.path(xs, ys, true, st)
+}
+
+func (c *PDFCanvas) Circle(x, y, r float64, st Style) {
+	c.b.WriteString("q\n")
+	if op := c.setStyle(st, true); op != "" {
+		// Four Bezier quarter arcs
+		k := 0.5523 * r
+		fmt.Fprintf(&c.b, "%.2f %.2f m\n", x + r, y)
+		fmt.Fprintf(&c.b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x + r, y + k, x + k, y + r, x, y + r)
+		fmt.Fprintf(&c.b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x - k, y + r, x - r, y + k, x - r, y)
+		fmt.Fprintf(&c.b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x - r, y - k, x - k, y - r, x, y - r)
+		fmt.Fprintf(&c.b, "%.2f %.2f %.2f %.2f %.2f %.2f c\n", x + k, y - r, x + r, y - k, x + r, y)
+		c.b.WriteString("h " + op + "\n")
+	}
+	c.b.WriteString("Q\n")
+}
+
+// Encode s as a PDF string in the font encoding. Codes outside ASCII are
+// written as octal escapes, and runes the font cannot show as '?'.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := pdfFontCode(r)
+		switch {
+		case !ok:
+			b.WriteByte('?')
+		case c == '\\' || c == '(' || c == ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c > '~':
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (c *PDFCanvas) Text(x, y float64, s string, st TextStyle) {
+	if st.Color.A == 0 || s == "" {
+		return
+	}
+	w := TextWidth(s, st.Size)
+	dx := 0.0
+	switch st.Anchor {
+	case "middle":
+		dx = -w / 2
+	case "end":
+		dx = -w
+	}
+	th := st.Rotate * math.Pi / 180
+	cos, sin := math.Cos(th), math.Sin(th)
+	x0, y0 := x + dx * cos, y + dx * sin
+	c.b.WriteString("q\n")
+	if st.Color.A != 255 {
+		fmt.Fprintf(&c.b, "/%s gs\n", c.alphaState(st.Color.A))
+	}
+	// The text matrix flips glyphs back upright in the page's y-down space
+	fmt.Fprintf(&c.b, "%s rg BT /F1 %.3g Tf %.4f %.4f %.4f %.4f %.2f %.2f Tm (%s) Tj ET\nQ\n",
+		pdfRGB(st.Color), st.Size, cos, sin, sin, -cos, x0, y0, pdfEscape(s))
+}
+
+// Write the finished document
+func (d *PDFDoc) WriteTo(w io.Writer) (int64, error) {
+	pw := newPDFWriter(w)
+	for _, p := range d.Pages {
+		pw.page(p)
+	}
+	pw.finish(d)
+	return pw.w.n, pw.err
+}
+
+// Writes PDF objects in any order and keeps their offsets for the xref
+// table. Objects 1 to 3 are the catalog, page tree, and shared resources,
+// which are written last; pages and their contents follow from 4.
+type pdfWriter struct {
+	w *countWriter
+	offsets map[int]int64
+	pages []int
+	next int
+	err error
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	pw := &pdfWriter{w: &countWriter{w: w}, offsets: map[int]int64{}, next: 4}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return pw
+}
+
+func (pw *pdfWriter) printf(format string, args ...any) {
+	if pw.err == nil {
+		_, pw.err = fmt.Fprintf(pw.w, format, args...)
+	}
+}
+
+func (pw *pdfWriter) obj(id int, body string) {
+	pw.offsets[id] = pw.w.n
+	pw.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// Write a page and its compressed contents
+func (pw *pdfWriter) page(p *PDFCanvas) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(p.b.Bytes())
+	zw.Close()
+
+	id := pw.next
+	pw.next += 2
+	pw.pages = append(pw.pages, id)
+	pw.obj(id, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources 3 0 R /Contents %d 0 R >>",
+		p.W * pdfPtPerPx, p.H * pdfPtPerPx, id + 1))
+	pw.obj(id + 1, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.String()))
+}
+
+func (pw *pdfWriter) finish(d *PDFDoc) {
+	var kids []string
+	for _, id := range pw.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	pw.obj(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	pw.obj(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var alphas []uint8
+	for a := range d.alphas {
+		alphas = append(alphas, a)
+	}
+	sort.Slice(alphas, func(i, j int) bool { return alphas[i] < alphas[j] })
+	var gs strings.Builder
+	for _, a := range alphas {
+		fmt.Fprintf(&gs, " /%s << /ca %.3f /CA %.3f >>", d.alphas[a], float64(a) / 255, float64(a) / 255)
+	}
+	pw.obj(3, fmt.Sprintf("<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [127 /Delta] >> >> >> /ExtGState <<%s >> >>", gs.String()))
+
+	xref := pw.w.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", pw.next)
+	for id := 1; id < pw.next; id++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[id])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", pw.next, xref)
+}
+
+// A PDF that is written one page at a time, so only the current page is held
+// in memory
+type PDFStream struct {
+	doc *PDFDoc
+	pw *pdfWriter
+}
+
+func NewPDFStream(w io.Writer) *PDFStream {
+	return &PDFStream{doc: NewPDFDoc(), pw: newPDFWriter(w)}
+}
+
+func (s *PDFStream) flush() {
+	for _, p := range s.doc.Pages {
+		s.pw.page(p)
+	}
+	s.doc.Pages = nil
+}
+
+// Write the previous page and start a new one
+func (s *PDFStream) AddPage(w, h float64) *PDFCanvas {
+	s.flush()
+	return s.doc.AddPage(w, h)
+}
+
+// Write the last page and the end of the document
+func (s *PDFStream) Close() error {
+	s.flush()
+	s.pw.finish(s.doc)
+	if s.pw.err != nil {
+		return fmt.Errorf("PDFStream.Close: %w", s.pw.err)
+	}
+	return nil
+}
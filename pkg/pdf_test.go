@@ -0,0 +1,93 @@
+package covplots
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var pdfStreamRe = regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+
+// The decompressed content streams of a PDF, in file order
+func pdfContents(pdf []byte) string {
+	var out strings.Builder
+	for _, m := range pdfStreamRe.FindAllSubmatchIndex(pdf, -1) {
+		n, _ := strconv.Atoi(string(pdf[m[2]:m[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[m[1]:m[1]+n]))
+		if err != nil {
+			panic(err)
+		}
+		io.Copy(&out, zr)
+	}
+	return out.String()
+}
+
+// Check that every xref entry points at its object
+func checkPDFXref(pdf []byte) error {
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		return fmt.Errorf("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		return fmt.Errorf("startxref %v does not point at xref", xref)
+	}
+	offs := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, off := range offs {
+		o, _ := strconv.Atoi(string(off[1]))
+		if !bytes.HasPrefix(pdf[o:], []byte(fmt.Sprintf("%d 0 obj\n", i + 1))) {
+			return fmt.Errorf("object %v is not at %v", i + 1, o)
+		}
+	}
+	return nil
+}
+
+func TestPDFStream(t *testing.T) {
+	var b bytes.Buffer
+	s := NewPDFStream(&b)
+	for i := 0; i < 3; i++ {
+		c := s.AddPage(200, 100)
+		c.Rect(0, 0, 10, 10, Style{Fill: Black.Fade(0.5)})
+		c.Text(5, 50, fmt.Sprintf("page (%v)", i), TextStyle{Size: 12, Color: Black})
+	}
+	if e := s.Close(); e != nil {
+		panic(e)
+	}
+
+	pdf := b.Bytes()
+	if e := checkPDFXref(pdf); e != nil {
+		t.Errorf("bad xref: %v", e)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 3")) || !bytes.Contains(pdf, []byte("/ca 0.502")) {
+		t.Errorf("missing page count or alpha state")
+	}
+	text := pdfContents(pdf)
+	for i := 0; i < 3; i++ {
+		if want := fmt.Sprintf(`(page \(%v\)) Tj`, i); !strings.Contains(text, want) {
+			t.Errorf("pdf does not contain %q", want)
+		}
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	out := pdfEscape("µ (Δ) é\\日")
+	expect := `\265 \(\177\) \351\\?`
+	if out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	// Helvetica widths: W 944, i 222, µ 556, Δ 612; unknown runes as '?' 556
+	out := TextWidth("Wiµ Δ日", 10)
+	expect := (944 + 222 + 556 + 278 + 612 + 556) / 100.0
+	if math.Abs(out - expect) > 1e-9 {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
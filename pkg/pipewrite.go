@@ -12,3 +12,12 @@ func PipeWrite(f func(io.Writer)) io.ReadCloser {
 	}()
 	return r
 }
+
+// Like PipeWrite, but an error from f is returned by the reader's Read
+func PipeWriteErr(f func(io.Writer) error) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(f(w))
+	}()
+	return r
+}
@@ -0,0 +1,879 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A discrete scale, mapping the values of a column to colors, line types, or
+// facet panels
+type SpecScale struct {
+	// Legend title; default the column name, or "Dataset" for NAME
+	Name string `json:"name"`
+	// Values to show, in order; default all values, sorted. Other values are
+	// drawn gray and left out of the legend, or for facets not drawn.
+	Breaks []string `json:"breaks"`
+	// Legend or strip labels for Breaks; default the values themselves
+	Labels []string `json:"labels"`
+	// Colors or line types for Breaks; default a palette
+	Values []string `json:"values"`
+	// For facets, panel heights for Breaks relative to each other; default 1
+	Heights []float64 `json:"heights"`
+}
+
+type SpecYScale struct {
+	// Limits of all panels; default the config's ylim
+	Limits []float64 `json:"limits"`
+	// Limits of single facet panels
+	FacetLimits map[string][]float64 `json:"facetlimits"`
+	// A file of FACET, MIN, and MAX columns with a header, as used by plot_multi_facet_scales
+	File string `json:"file"`
+	// Without limits, fit each panel to its own data instead of all data
+	Free bool `json:"free"`
+}
+
+type SpecScales struct {
+	Color SpecScale `json:"color"`
+	Linetype SpecScale `json:"linetype"`
+	Facet SpecScale `json:"facet"`
+	Y SpecYScale `json:"y"`
+}
+
+// Regions to highlight behind the data in every panel
+type SpecBoxes struct {
+	Bed string `json:"bed"`
+	// Default "#ddddff"
+	Fill string `json:"fill"`
+	// Default 0.5
+	Alpha float64 `json:"alpha"`
+}
+
+type SpecLegend struct {
+	// "right" (default), "bottom", or "none"
+	Position string `json:"position"`
+}
+
+// Sizes and colors of the parts of a plot; the defaults follow ggplot2's theme_bw
+type SpecTheme struct {
+	// In pixels; default 16
+	FontSize float64 `json:"fontsize"`
+	Background string `json:"background"`
+	PanelBackground string `json:"panelbackground"`
+	// Grid line color, or "none"
+	Grid string `json:"grid"`
+	StripFill string `json:"stripfill"`
+}
+
+// A declarative description of a plot of plfmt data
+type PlotSpec struct {
+	// Names of the plfmt columns between VAL and NAME, e.g. ["TISSUE", "GENO"]
+	Columns []string `json:"columns"`
+	// "point" (default), "line", "bar", or "area"
+	Geom string `json:"geom"`
+	// Point radius or line width in pixels; default 1.5
+	Size float64 `json:"size"`
+	// Styles of single input sets, by NAME. Their colors and line types
+	// apply where those are not mapped to other columns.
+	Styles map[string]SetStyle `json:"styles"`
+	// Columns mapped to each aesthetic. Color defaults to NAME ("none" for
+	// black). Lines connect points with the same group, which defaults to
+	// the same color, line type, and NAME.
+	Color string `json:"color"`
+	Linetype string `json:"linetype"`
+	Group string `json:"group"`
+	// Columns with the bounds of a ribbon around each line
+	Lower string `json:"lower"`
+	Upper string `json:"upper"`
+	// Column that splits the plot into rows of panels
+	Facet string `json:"facet"`
+	Scales SpecScales `json:"scales"`
+	Boxes []SpecBoxes `json:"boxes"`
+	// Regions and point features to mark, with legend labels
+	Highlights []Highlight `json:"highlights"`
+	Title string `json:"title"`
+	// Default "Chromosome" and "Raw coverage"
+	XLabel string `json:"xlabel"`
+	YLabel string `json:"ylabel"`
+	Legend SpecLegend `json:"legend"`
+	Theme SpecTheme `json:"theme"`
+	// Size in pixels; default 1500 by 600
+	Width float64 `json:"width"`
+	Height float64 `json:"height"`
+
+	loaded bool
+	// Reads highlight beds; nil to read them straight from the files
+	readBed highlightReader
+	marks []specMark
+	markKeys []legendKey
+}
+
+func (s *PlotSpec) setDefaults() {
+	if s.Geom == "" { s.Geom = "point" }
+	if s.Size == 0 { s.Size = 1.5 }
+	if s.Color == "" { s.Color = "NAME" }
+	if s.XLabel == "" { s.XLabel = "Chromosome" }
+	if s.YLabel == "" { s.YLabel = "Raw coverage" }
+	if s.Legend.Position == "" { s.Legend.Position = "right" }
+	if s.Theme.FontSize == 0 { s.Theme.FontSize = 16 }
+	if s.Theme.Background == "" { s.Theme.Background = "white" }
+	if s.Theme.PanelBackground == "" { s.Theme.PanelBackground = "white" }
+	if s.Theme.Grid == "" { s.Theme.Grid = "#ebebeb" }
+	if s.Theme.StripFill == "" { s.Theme.StripFill = "#d9d9d9" }
+	if s.Width == 0 { s.Width = 1500 }
+	if s.Height == 0 { s.Height = 600 }
+}
+
+// The config's plotspec with the config's highlights added to the spec's,
+// loaded once per config, with highlight chromosomes renamed as the data are
+func configPlotSpec(cfg UltimateConfig, d *ConfigData) (*PlotSpec, error) {
+	return configCached(d, "plotspec", func() (*PlotSpec, error) {
+		if cfg.PlotSpec == nil {
+			return nil, fmt.Errorf("configPlotSpec: config has no plotspec")
+		}
+		spec := *cfg.PlotSpec
+		spec.Highlights = appendHighlights(spec.Highlights, cfg.Highlights)
+		spec.readBed = configHighlightReader(cfg, d)
+		spec.loaded = false
+		if err := spec.Load(); err != nil {
+			return nil, fmt.Errorf("configPlotSpec: %w", err)
+		}
+		return &spec, nil
+	})
+}
+
+// A copy of the spec that also uses a config's facets for the order, titles,
+// heights, and y limits of its facet panels, and automatic limits for the
+// facets that have none, where the spec does not set them. Use it on the
+// spec from configPlotSpec, which already has the config's highlights.
+func (s *PlotSpec) withConfig(cfg UltimateConfig, lims map[string][]float64) (*PlotSpec, error) {
+	h := Handle("PlotSpec.withConfig: %w")
+	if err := s.Load(); err != nil { return nil, h(err) }
+	out := *s
+	facets := cfg.Facets
+	if len(facets) > 0 && len(s.Scales.Facet.Breaks) == 0 {
+		sc := facetsScale(facets)
+		out.Scales.Facet.Breaks = sc.Breaks
+		if len(s.Scales.Facet.Labels) == 0 {
+			out.Scales.Facet.Labels = sc.Labels
+		}
+		if len(s.Scales.Facet.Heights) == 0 {
+			out.Scales.Facet.Heights = sc.Heights
+		}
+	}
+	lims = facetYlims(facets, lims)
+	out.Scales.Y.FacetLimits = map[string][]float64{}
+	for facet, lim := range lims {
+		out.Scales.Y.FacetLimits[facet] = lim
+	}
+	for facet, lim := range s.Scales.Y.FacetLimits {
+		out.Scales.Y.FacetLimits[facet] = lim
+	}
+	return &out, nil
+}
+
+// Read the files that the spec refers to. DrawPlotSpec does this the first
+// time it is called.
+func (s *PlotSpec) Load() error {
+	h := Handle("PlotSpec.Load: %w")
+	if s.loaded {
+		return nil
+	}
+	s.marks, s.markKeys = nil, nil
+	for _, b := range s.Boxes {
+		bed, err := ReadBedPath(b.Bed)
+		if err != nil { return h(err) }
+		fill := b.Fill
+		if fill == "" { fill = "#ddddff" }
+		col, err := ParseColor(fill)
+		if err != nil { return h(err) }
+		alpha := b.Alpha
+		if alpha == 0 { alpha = 0.5 }
+		for _, entry := range bed {
+			s.marks = append(s.marks, specMark{BedEntry: entry, style: Style{Fill: col.Fade(alpha)}})
+		}
+	}
+	marks, keys, err := readHighlightMarks(s.Highlights, s.readBed)
+	if err != nil { return h(err) }
+	s.marks = append(s.marks, marks...)
+	s.markKeys = keys
+
+	if s.Scales.Y.File != "" {
+		lims, err := ReadScalesFile(s.Scales.Y.File)
+		if err != nil { return h(err) }
+		for facet, lim := range s.Scales.Y.FacetLimits {
+			lims[facet] = lim
+		}
+		s.Scales.Y.FacetLimits = lims
+	}
+	s.loaded = true
+	return nil
+}
+
+// Read per-facet y limits from a file with a header and FACET, MIN, and MAX columns
+func ReadScalesFile(path string) (map[string][]float64, error) {
+	h := Handle("ReadScalesFile: %w")
+	r, err := os.Open(path)
+	if err != nil { return nil, h(err) }
+	defer r.Close()
+
+	out := map[string][]float64{}
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for i := 0; s.Scan(); i++ {
+		line := strings.Fields(s.Text())
+		if i == 0 || len(line) == 0 {
+			continue
+		}
+		if len(line) < 3 {
+			return nil, h(fmt.Errorf("line %v has less than 3 fields", line))
+		}
+		lo, e1 := strconv.ParseFloat(line[1], 64)
+		hi, e2 := strconv.ParseFloat(line[2], 64)
+		if e1 != nil || e2 != nil {
+			return nil, h(fmt.Errorf("could not parse limits of line %v", line))
+		}
+		out[line[0]] = []float64{lo, hi}
+	}
+	if err := s.Err(); err != nil { return nil, h(err) }
+	return out, nil
+}
+
+// The value of a named column of p. Columns listed in the spec come first,
+// then NAME, CHR, FACET, VAL, LOWER, and UPPER.
+func (s *PlotSpec) colString(p PlotPoint, col string) string {
+	for i, name := range s.Columns {
+		if name == col && i < len(p.Cols) {
+			return p.Cols[i]
+		}
+	}
+	switch col {
+	case "NAME": return p.Name
+	case "CHR", "chrom": return p.Chr
+	case "FACET": return p.Facet
+	case "VAL": return strconv.FormatFloat(p.Val, 'g', -1, 64)
+	case "LOWER": return strconv.FormatFloat(p.Lower, 'g', -1, 64)
+	case "UPPER": return strconv.FormatFloat(p.Upper, 'g', -1, 64)
+	}
+	return ""
+}
+
+func (s *PlotSpec) colFloat(p PlotPoint, col string) float64 {
+	if col == "" {
+		return math.NaN()
+	}
+	return AlwaysParseFloat(s.colString(p, col))
+}
+
+// Dash patterns of ggplot2's named line types, in units of the line width
+var linetypeDashes = map[string][]float64{
+	"solid": nil,
+	"dashed": {4, 4},
+	"dotted": {1, 3},
+	"dotdash": {1, 3, 4, 3},
+	"longdash": {7, 3},
+	"twodash": {2, 2, 6, 2},
+}
+
+var linetypeOrder = []string{"solid", "dashed", "dotted", "dotdash", "longdash", "twodash"}
+
+// A line type name, or a string of hex digits giving dash and gap lengths as in R
+func ParseLinetype(s string) ([]float64, error) {
+	if d, ok := linetypeDashes[s]; ok {
+		return append([]float64(nil), d...), nil
+	}
+	if len(s) == 0 || len(s) % 2 != 0 {
+		return nil, fmt.Errorf("ParseLinetype: unknown line type %q", s)
+	}
+	var out []float64
+	for _, r := range s {
+		v, err := strconv.ParseUint(string(r), 16, 8)
+		if err != nil || v == 0 {
+			return nil, fmt.Errorf("ParseLinetype: unknown line type %q", s)
+		}
+		out = append(out, float64(v))
+	}
+	return out, nil
+}
+
+// A discrete scale with its breaks, labels, and values worked out
+type specScale struct {
+	col string
+	title string
+	breaks []string
+	labels []string
+	index map[string]int
+	colors []Color
+	dashes [][]float64
+}
+
+func newSpecScale(sc SpecScale, col string, vals []string) specScale {
+	out := specScale{col: col, title: sc.Name, breaks: sc.Breaks, index: map[string]int{}}
+	if out.title == "" {
+		out.title = col
+		if col == "NAME" {
+			out.title = "Dataset"
+		}
+	}
+	if len(out.breaks) == 0 {
+		out.breaks = uniqueStrings(vals, true)
+	}
+	for i, b := range out.breaks {
+		out.index[b] = i
+		label := b
+		if i < len(sc.Labels) {
+			label = sc.Labels[i]
+		}
+		out.labels = append(out.labels, label)
+	}
+	return out
+}
+
+func (sc *specScale) setColors(values []string) error {
+	pal := HuePalette(len(sc.breaks))
+	for i := range sc.breaks {
+		col := pal[i]
+		if i < len(values) {
+			c, err := ParseColor(values[i])
+			if err != nil {
+				return fmt.Errorf("setColors: %w", err)
+			}
+			col = c
+		}
+		sc.colors = append(sc.colors, col)
+	}
+	return nil
+}
+
+func (sc *specScale) setDashes(values []string) error {
+	for i := range sc.breaks {
+		name := linetypeOrder[i % len(linetypeOrder)]
+		if i < len(values) {
+			name = values[i]
+		}
+		d, err := ParseLinetype(name)
+		if err != nil {
+			return fmt.Errorf("setDashes: %w", err)
+		}
+		sc.dashes = append(sc.dashes, d)
+	}
+	return nil
+}
+
+// A point with its spec columns looked up
+type specPoint struct {
+	PlotPoint
+	lower, upper float64
+	color, linetype, group, facet string
+}
+
+func specYRange(pts []specPoint) (float64, float64) {
+	var vals []PlotPoint
+	for _, p := range pts {
+		vals = append(vals, PlotPoint{Val: p.Val, Lower: p.lower, Upper: p.upper})
+	}
+	return dataYRange(vals)
+}
+
+// One row of the plot
+type specPanel struct {
+	facet string
+	label string
+	height float64
+	pts []specPoint
+	ylo, yhi float64
+	ticks []float64
+}
+
+// How one group of points is drawn
+type seriesStyle struct {
+	color Color
+	dash []float64
+	geom string
+	size float64
+}
+
+// Dash lengths scale with the line width, as in ggplot2
+func scaleDash(dash []float64, width float64) []float64 {
+	var out []float64
+	for _, d := range dash {
+		out = append(out, d * math.Max(width, 1))
+	}
+	return out
+}
+
+// A legend key: a point, line, or box in a series style
+type legendKey struct {
+	label string
+	style seriesStyle
+}
+
+type legendSection struct {
+	title string
+	keys []legendKey
+}
+
+func (l legendSection) width(fs float64) float64 {
+	w := TextWidth(l.title, fs)
+	for _, k := range l.keys {
+		w = math.Max(w, TextWidth(k.label, fs * 0.85) + fs * 1.8)
+	}
+	return w
+}
+
+// Draw plfmt points as described by spec. Values outside a panel's y limits
+// are not drawn, and ribbons are clipped to them. Ylim is used when the spec
+// sets no limits.
+func DrawPlotSpec(c Canvas, pts []PlotPoint, ylim []float64, spec *PlotSpec) error {
+	h := Handle("DrawPlotSpec: %w")
+	if err := spec.Load(); err != nil { return h(err) }
+	s := *spec
+	s.setDefaults()
+	w, ht := c.Size()
+	fs := s.Theme.FontSize
+
+	var theme [4]Color
+	for i, name := range []string{s.Theme.Background, s.Theme.PanelBackground, s.Theme.Grid, s.Theme.StripFill} {
+		col, err := ParseColor(name)
+		if err != nil { return h(err) }
+		theme[i] = col
+	}
+	bg, panelBg, gridCol, stripFill := theme[0], theme[1], theme[2], theme[3]
+
+	// Scales
+	sps := make([]specPoint, len(pts))
+	var colorVals, ltVals, facetVals []string
+	for i, p := range pts {
+		sp := specPoint{PlotPoint: p, lower: s.colFloat(p, s.Lower), upper: s.colFloat(p, s.Upper)}
+		if s.Color != "none" {
+			sp.color = s.colString(p, s.Color)
+			colorVals = append(colorVals, sp.color)
+		}
+		if s.Linetype != "" {
+			sp.linetype = s.colString(p, s.Linetype)
+			ltVals = append(ltVals, sp.linetype)
+		}
+		if s.Facet != "" {
+			sp.facet = s.colString(p, s.Facet)
+			facetVals = append(facetVals, sp.facet)
+		}
+		sp.group = sp.color + "\x00" + sp.linetype + "\x00" + p.Name
+		if s.Group != "" {
+			sp.group = s.colString(p, s.Group)
+		}
+		sps[i] = sp
+	}
+
+	var colorSc, ltSc specScale
+	if s.Color != "none" {
+		colorSc = newSpecScale(s.Scales.Color, s.Color, colorVals)
+		if err := colorSc.setColors(s.Scales.Color.Values); err != nil { return h(err) }
+	}
+	if s.Linetype != "" {
+		ltSc = newSpecScale(s.Scales.Linetype, s.Linetype, ltVals)
+		if err := ltSc.setDashes(s.Scales.Linetype.Values); err != nil { return h(err) }
+	}
+	for name, st := range s.Styles {
+		if err := st.Validate(); err != nil { return h(fmt.Errorf("style of %q: %w", name, err)) }
+	}
+	styleColor := s.Color == "NAME" || s.Color == "none"
+	styleLinetype := s.Linetype == "" || s.Linetype == "NAME"
+	styleOf := func(p specPoint) seriesStyle {
+		out := seriesStyle{color: Black, geom: s.Geom, size: s.Size}
+		if s.Color != "none" {
+			out.color = Color{128, 128, 128, 255}
+			if i, ok := colorSc.index[p.color]; ok {
+				out.color = colorSc.colors[i]
+			}
+		}
+		if i, ok := ltSc.index[p.linetype]; ok {
+			out.dash = ltSc.dashes[i]
+		}
+		if st, ok := s.Styles[p.Name]; ok {
+			if st.Color != "" && styleColor {
+				out.color, _ = ParseColor(st.Color)
+			}
+			if st.Linetype != "" && styleLinetype {
+				out.dash, _ = ParseLinetype(st.Linetype)
+			}
+			if st.Geom != "" { out.geom = st.Geom }
+			if st.Width != 0 { out.size = st.Width }
+			if st.Alpha != 0 { out.color = out.color.Fade(st.Alpha) }
+		}
+		out.dash = scaleDash(out.dash, out.size)
+		return out
+	}
+
+	// Panels and their y ranges
+	var panels []*specPanel
+	if s.Facet == "" {
+		panels = []*specPanel{{pts: sps, height: 1}}
+	} else {
+		facetSc := newSpecScale(s.Scales.Facet, s.Facet, facetVals)
+		byFacet := map[string]*specPanel{}
+		for i, f := range facetSc.breaks {
+			p := &specPanel{facet: f, label: facetSc.labels[i], height: 1}
+			if i < len(s.Scales.Facet.Heights) && s.Scales.Facet.Heights[i] > 0 {
+				p.height = s.Scales.Facet.Heights[i]
+			}
+			panels = append(panels, p)
+			byFacet[f] = p
+		}
+		for _, p := range sps {
+			if panel, ok := byFacet[p.facet]; ok {
+				panel.pts = append(panel.pts, p)
+			}
+		}
+		if len(panels) == 0 {
+			panels = []*specPanel{{height: 1}}
+		}
+	}
+	lims := s.Scales.Y.Limits
+	if len(lims) != 2 || lims[0] == lims[1] {
+		lims = ylim
+	}
+	allLo, allHi := specYRange(sps)
+	tickW := 0.0
+	for _, p := range panels {
+		switch lim, ok := s.Scales.Y.FacetLimits[p.facet]; {
+		case ok && len(lim) == 2 && lim[0] != lim[1] && s.Facet != "":
+			p.ylo, p.yhi = lim[0], lim[1]
+		case len(lims) == 2 && lims[0] != lims[1]:
+			p.ylo, p.yhi = lims[0], lims[1]
+		case s.Scales.Y.Free:
+			p.ylo, p.yhi = specYRange(p.pts)
+		default:
+			p.ylo, p.yhi = allLo, allHi
+		}
+		p.ticks = NiceTicks(p.ylo, p.yhi, 5)
+		for _, t := range p.ticks {
+			tickW = math.Max(tickW, TextWidth(FormatTick(t, p.ticks), fs * 0.85))
+		}
+	}
+
+	xlo, xhi := math.Inf(1), math.Inf(-1)
+	shifts := map[string]float64{}
+	for _, p := range pts {
+		xlo = math.Min(xlo, p.Start)
+		xhi = math.Max(xhi, p.End)
+		shifts[p.Chr] = p.Start - float64(p.BpStart)
+	}
+	if math.IsInf(xlo, 0) {
+		xlo, xhi = 0, 1
+	}
+	xpad := (xhi - xlo) * 0.02
+
+	// Legend
+	var legend []legendSection
+	if s.Color != "none" && len(colorSc.breaks) > 0 {
+		sec := legendSection{title: colorSc.title}
+		for i, label := range colorSc.labels {
+			p := specPoint{color: colorSc.breaks[i]}
+			if s.Linetype == s.Color {
+				p.linetype = p.color
+			}
+			if s.Color == "NAME" {
+				p.Name = p.color
+				if st := s.Styles[p.Name]; st.Label != "" && i >= len(s.Scales.Color.Labels) {
+					label = st.Label
+				}
+			}
+			sec.keys = append(sec.keys, legendKey{label: label, style: styleOf(p)})
+		}
+		legend = append(legend, sec)
+	}
+	if s.Linetype != "" && s.Linetype != s.Color && len(ltSc.breaks) > 0 {
+		sec := legendSection{title: ltSc.title}
+		for i, label := range ltSc.labels {
+			st := seriesStyle{color: Color{51, 51, 51, 255}, geom: "line", size: s.Size}
+			st.dash = scaleDash(ltSc.dashes[i], st.size)
+			sec.keys = append(sec.keys, legendKey{label: label, style: st})
+		}
+		legend = append(legend, sec)
+	}
+	if len(spec.markKeys) > 0 {
+		legend = append(legend, legendSection{title: "Highlights", keys: spec.markKeys})
+	}
+
+	// Layout
+	top := fs
+	if s.Title != "" {
+		top += fs * 1.8
+	}
+	left := fs * 2.2 + tickW + 8
+	right := w - fs
+	bottom := ht - fs * 3.4
+	legendW := 0.0
+	for _, sec := range legend {
+		legendW = math.Max(legendW, sec.width(fs))
+	}
+	switch {
+	case len(legend) == 0 || s.Legend.Position == "none":
+	case s.Legend.Position == "bottom":
+		bottom -= fs * 2.6
+	default:
+		right -= legendW + fs
+	}
+	if s.Facet != "" {
+		right -= fs * 1.6
+	}
+
+	c.Rect(0, 0, w, ht, Style{Fill: bg})
+	if s.Title != "" {
+		c.Text((left + right) / 2, fs * 1.6, s.Title, TextStyle{Size: fs * 1.2, Color: Black, Anchor: "middle"})
+	}
+
+	chrs, chrpos := chrLabelPositions(pts)
+	xs := plotScale{xlo - xpad, xhi + xpad, left, right}
+	gap := fs * 0.5
+	heights := 0.0
+	for _, panel := range panels {
+		heights += panel.height
+	}
+	panelsH := bottom - top - gap * float64(len(panels) - 1)
+	axisText := TextStyle{Size: fs * 0.85, Color: Color{77, 77, 77, 255}}
+
+	ptop := top
+	for _, panel := range panels {
+		panelH := panelsH * panel.height / heights
+		pbot := ptop + panelH
+		ys := plotScale{panel.ylo, panel.yhi, pbot, ptop}
+
+		c.Rect(left, ptop, right - left, panelH, Style{Fill: panelBg})
+		grid := Style{Stroke: gridCol, StrokeWidth: 1}
+		yt := axisText
+		yt.Anchor = "end"
+		for _, t := range panel.ticks {
+			y := ys.Map(t)
+			c.Polyline([]float64{left, right}, []float64{y, y}, grid)
+			c.Text(left - 6, y + fs * 0.3, FormatTick(t, panel.ticks), yt)
+		}
+		for _, x := range chrpos {
+			px := xs.Map(x)
+			c.Polyline([]float64{px, px}, []float64{ptop, pbot}, grid)
+		}
+
+		for _, m := range spec.marks {
+			shift, ok := shifts[m.Chr]
+			if !ok {
+				continue
+			}
+			if m.line {
+				x := xs.Map(float64(m.Start + m.End) / 2 + shift)
+				if x >= left && x <= right {
+					c.Polyline([]float64{x, x}, []float64{ptop, pbot}, m.style)
+				}
+				continue
+			}
+			x0 := math.Max(xs.Map(float64(m.Start) + shift), left)
+			x1 := math.Min(xs.Map(float64(m.End) + shift), right)
+			if x1 > x0 {
+				c.Rect(x0, ptop, x1 - x0, panelH, m.style)
+			}
+		}
+
+		drawSpecSeries(c, panel, &s, styleOf, colorSc, ltSc, xs, ys)
+
+		c.Rect(left, ptop, right - left, panelH, Style{Stroke: Color{51, 51, 51, 255}, StrokeWidth: 1})
+		if s.Facet != "" {
+			sx := right + 2
+			c.Rect(sx, ptop, fs * 1.4, panelH, Style{Fill: stripFill, Stroke: Color{51, 51, 51, 255}, StrokeWidth: 1})
+			c.Text(sx + fs * 0.7 + fs * 0.3, (ptop + pbot) / 2, panel.label, TextStyle{Size: fs * 0.85, Color: Color{26, 26, 26, 255}, Anchor: "middle", Rotate: 90})
+		}
+		ptop = pbot + gap
+	}
+
+	// Axes
+	xt := axisText
+	xt.Anchor = "middle"
+	for i, chr := range chrs {
+		c.Text(xs.Map(chrpos[i]), bottom + fs * 1.2, chr, xt)
+	}
+	c.Text((left + right) / 2, bottom + fs * 2.8, s.XLabel, TextStyle{Size: fs, Color: Black, Anchor: "middle"})
+	c.Text(fs * 1.2, (top + bottom) / 2, s.YLabel, TextStyle{Size: fs, Color: Black, Anchor: "middle", Rotate: -90})
+
+	if len(legend) == 0 || s.Legend.Position == "none" {
+		return nil
+	}
+	drawKey := func(x, y float64, k legendKey) {
+		st := k.style
+		switch st.geom {
+		case "line":
+			c.Polyline([]float64{x - fs * 0.6, x + fs * 0.6}, []float64{y, y}, Style{Stroke: st.color, StrokeWidth: math.Max(st.size, 1.5), Dash: st.dash})
+		case "bar", "area":
+			c.Rect(x - fs * 0.4, y - fs * 0.4, fs * 0.8, fs * 0.8, Style{Fill: st.color})
+		default:
+			c.Circle(x, y, fs * 0.3, Style{Fill: st.color})
+		}
+	}
+	if s.Legend.Position == "bottom" {
+		x := left
+		y := ht - fs * 1.2
+		for _, sec := range legend {
+			c.Text(x, y + fs * 0.35, sec.title, TextStyle{Size: fs, Color: Black})
+			x += TextWidth(sec.title, fs) + fs
+			for _, k := range sec.keys {
+				drawKey(x + fs * 0.6, y, k)
+				c.Text(x + fs * 1.5, y + fs * 0.3, k.label, TextStyle{Size: fs * 0.85, Color: Black})
+				x += TextWidth(k.label, fs * 0.85) + fs * 2.5
+			}
+			x += fs
+		}
+		return nil
+	}
+	lx := w - legendW - fs
+	n := 0
+	for _, sec := range legend {
+		n += len(sec.keys) + 1
+	}
+	ly := (top + bottom) / 2 - (float64(n) * fs * 1.4 + float64(len(legend) - 1) * fs) / 2
+	for _, sec := range legend {
+		c.Text(lx, ly + fs, sec.title, TextStyle{Size: fs, Color: Black})
+		for i, k := range sec.keys {
+			y := ly + float64(i + 1) * fs * 1.4 + fs * 0.5
+			drawKey(lx + fs * 0.6, y, k)
+			c.Text(lx + fs * 1.5, y + fs * 0.3, k.label, TextStyle{Size: fs * 0.85, Color: Black})
+		}
+		ly += float64(len(sec.keys) + 1) * fs * 1.4 + fs
+	}
+	return nil
+}
+
+// Draw the ribbons, then the points, lines, bars, or areas of every group in
+// one panel
+func drawSpecSeries(c Canvas, panel *specPanel, s *PlotSpec, styleOf func(specPoint) seriesStyle, colorSc, ltSc specScale, xs, ys plotScale) {
+	ylo, yhi := panel.ylo, panel.yhi
+	byGroup := map[string][]PlotPoint{}
+	first := map[string]specPoint{}
+	var groups []string
+	for _, p := range panel.pts {
+		if _, ok := first[p.group]; !ok {
+			first[p.group] = p
+			groups = append(groups, p.group)
+		}
+		// Carry the ribbon bounds in the PlotPoint for plotRuns
+		pp := p.PlotPoint
+		pp.Lower, pp.Upper = p.lower, p.upper
+		byGroup[p.group] = append(byGroup[p.group], pp)
+	}
+	// Draw values not in the scales first, then in legend order, so the
+	// last break is on top
+	rank := func(p specPoint) (int, int) {
+		ci, ok := colorSc.index[p.color]
+		if !ok { ci = -1 }
+		li, ok := ltSc.index[p.linetype]
+		if !ok { li = -1 }
+		return ci, li
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		ci, li := rank(first[groups[i]])
+		cj, lj := rank(first[groups[j]])
+		if ci != cj {
+			return ci < cj
+		}
+		if li != lj {
+			return li < lj
+		}
+		return first[groups[i]].Name < first[groups[j]].Name
+	})
+
+	inRange := func(v float64) bool { return !math.IsNaN(v) && v >= ylo && v <= yhi }
+	clip := func(v float64) float64 { return math.Max(ylo, math.Min(yhi, v)) }
+
+	for _, g := range groups {
+		col := styleOf(first[g]).color
+		for _, run := range plotRuns(byGroup[g], func(p PlotPoint) bool { return !math.IsNaN(p.Lower) && !math.IsNaN(p.Upper) }) {
+			var px, py []float64
+			for _, p := range run {
+				px = append(px, xs.Map(p.X()))
+				py = append(py, ys.Map(clip(p.Upper)))
+			}
+			for i := len(run) - 1; i >= 0; i-- {
+				px = append(px, xs.Map(run[i].X()))
+				py = append(py, ys.Map(clip(run[i].Lower)))
+			}
+			c.Polygon(px, py, Style{Fill: col.Fade(0.3)})
+		}
+	}
+
+	// Bars and areas start from zero, or the nearest edge of the panel
+	base := ys.Map(clip(0))
+	for _, g := range groups {
+		st := styleOf(first[g])
+		switch st.geom {
+		case "line":
+			for _, run := range plotRuns(byGroup[g], func(p PlotPoint) bool { return inRange(p.Val) }) {
+				var px, py []float64
+				for _, p := range run {
+					px = append(px, xs.Map(p.X()))
+					py = append(py, ys.Map(p.Val))
+				}
+				c.Polyline(px, py, Style{Stroke: st.color, StrokeWidth: st.size, Dash: st.dash})
+			}
+		case "bar":
+			for _, p := range byGroup[g] {
+				if math.IsNaN(p.Val) {
+					continue
+				}
+				x0, x1, y := xs.Map(p.Start), xs.Map(p.End), ys.Map(clip(p.Val))
+				c.Rect(x0, math.Min(y, base), math.Max(x1 - x0, 1), math.Abs(y - base), Style{Fill: st.color})
+			}
+		case "area":
+			for _, run := range plotRuns(byGroup[g], func(p PlotPoint) bool { return !math.IsNaN(p.Val) }) {
+				var px, py []float64
+				for _, p := range run {
+					px = append(px, xs.Map(p.X()))
+					py = append(py, ys.Map(clip(p.Val)))
+				}
+				px = append(px, px[len(px)-1], px[0])
+				py = append(py, base, base)
+				c.Polygon(px, py, Style{Fill: st.color})
+			}
+		default:
+			for _, p := range byGroup[g] {
+				if inRange(p.Val) {
+					c.Circle(xs.Map(p.X()), ys.Map(p.Val), st.size, Style{Fill: st.color})
+				}
+			}
+		}
+	}
+}
+
+// Read outpre_plfmt.bed and draw it as described by spec, to the formats in out
+func PlotSpecFiles(outpre string, ylim []float64, spec *PlotSpec, out PlotOutputCfg) error {
+	h := Handle("PlotSpecFiles: %w")
+
+	pts, err := ReadPlotPointsPath(outpre + "_plfmt.bed", PlfmtPlain)
+	if err != nil { return h(err) }
+	styles, err := ReadPlfmtSetStyles(outpre)
+	if err != nil { return h(err) }
+	// Load before copying, so that files are read once for all windows
+	if err := spec.Load(); err != nil { return h(err) }
+
+	s := *spec
+	s.Styles = mergeSetStyles(s.Styles, styles)
+	s.setDefaults()
+	err = WritePlotFormats(outpre, s.Width, s.Height, out, func(c Canvas) error {
+		return DrawPlotSpec(c, pts, ylim, &s)
+	})
+	if err != nil { return h(err) }
+	return nil
+}
+
+// Plot with the config's "plotspec"
+func PlotSpecAny(outpre string, ylim []float64, args any, margs MultiplotPlotFuncArgs) error {
+	spec, err := configPlotSpec(margs.Cfg, margs.Data)
+	if err != nil {
+		return fmt.Errorf("PlotSpecAny: %w", err)
+	}
+	if margs.FacetYlims != nil || len(margs.Cfg.Facets) > 0 {
+		if spec, err = spec.withConfig(margs.Cfg, margs.FacetYlims); err != nil {
+			return fmt.Errorf("PlotSpecAny: %w", err)
+		}
+	}
+	return PlotSpecFiles(outpre, ylim, spec, margs.Cfg.PlotOutput)
+}
@@ -0,0 +1,142 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLinetype(t *testing.T) {
+	for _, c := range []struct {
+		in string
+		expect []float64
+	}{
+		{"solid", nil},
+		{"dashed", []float64{4, 4}},
+		{"44", []float64{4, 4}},
+		{"13f3", []float64{1, 3, 15, 3}},
+	} {
+		out, err := ParseLinetype(c.in)
+		if err != nil {
+			panic(err)
+		}
+		if !reflect.DeepEqual(out, c.expect) {
+			t.Errorf("out %v != expect %v", out, c.expect)
+		}
+	}
+	for _, in := range []string{"wavy", "4", "40"} {
+		if _, err := ParseLinetype(in); err == nil {
+			t.Errorf("no error for line type %q", in)
+		}
+	}
+}
+
+func TestReadScalesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scales.txt")
+	if e := os.WriteFile(path, []byte("FACET\tMIN\tMAX\nixw\t0\t0.5\nixa\t-1\t2\n"), 0644); e != nil { panic(e) }
+	out, err := ReadScalesFile(path)
+	if err != nil {
+		panic(err)
+	}
+	expect := map[string][]float64{"ixw": {0, 0.5}, "ixa": {-1, 2}}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestPlotSpec(t *testing.T) {
+	dir := t.TempDir()
+	outpre := filepath.Join(dir, "out")
+	plfmt := "2L\t0\t10\t0.1\thead\tixw\tx\t0\t0\t10\n" +
+		"2L\t10\t20\t0.2\thead\tixw\tx\t0\t10\t20\n" +
+		"2L\t0\t10\t0.3\tgonad\tixw\tx\t0\t0\t10\n" +
+		"2L\t10\t20\t0.4\tgonad\tixw\tx\t0\t10\t20\n" +
+		"2L\t0\t10\t0.3\thead\tixa\tx\t0\t0\t10\n" +
+		"2L\t0\t10\t0.3\thead\tother\tx\t0\t0\t10\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+	bed := filepath.Join(dir, "boxes.bed")
+	if e := os.WriteFile(bed, []byte("2L\t5\t15\n3R\t0\t10\n"), 0644); e != nil { panic(e) }
+
+	var margs MultiplotPlotFuncArgs
+	margs.Cfg.PlotSpec = &PlotSpec{
+		Columns: []string{"TISSUE", "GENO"},
+		Geom: "line",
+		Color: "TISSUE",
+		Linetype: "TISSUE",
+		Facet: "GENO",
+		Scales: SpecScales{
+			Color: SpecScale{Name: "Tissue", Breaks: []string{"head", "gonad"}, Values: []string{"#000000", "#ff0000"}},
+			Linetype: SpecScale{Values: []string{"solid", "dashed"}},
+			Facet: SpecScale{Breaks: []string{"ixw", "ixa"}, Labels: []string{"Female", "Male"}},
+			Y: SpecYScale{Limits: []float64{0, 0.5}},
+		},
+		Boxes: []SpecBoxes{{Bed: bed}},
+		Title: "Tissues",
+		Legend: SpecLegend{Position: "bottom"},
+	}
+	if e := PlotSpecAny(outpre, nil, nil, margs); e != nil { panic(e) }
+
+	svg, err := os.ReadFile(outpre + "_plotted.svg")
+	if err != nil {
+		panic(err)
+	}
+	s := string(svg)
+	for _, want := range []string{"Tissues", "Tissue", "Female", "Male", `stroke-dasharray="6 6"`, `fill="#ddddff"`, `stroke="#ff0000"`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("svg does not contain %q", want)
+		}
+	}
+	if strings.Contains(s, ">other<") {
+		t.Errorf("svg contains facet not in breaks")
+	}
+	if strings.Index(s, "Female") > strings.Index(s, "Male") {
+		t.Errorf("facets not in order of breaks")
+	}
+	// One box for each of the two panels; 3R is not plotted
+	if n := strings.Count(s, `fill="#ddddff"`); n != 2 {
+		t.Errorf("boxes %v != expect %v", n, 2)
+	}
+
+	if e := PlotSpecAny(outpre, nil, nil, MultiplotPlotFuncArgs{}); e == nil {
+		t.Errorf("no error without a plotspec")
+	}
+}
+
+func TestConfigPlotSpec(t *testing.T) {
+	dir := t.TempDir()
+	regions := filepath.Join(dir, "regions.bed")
+	if e := os.WriteFile(regions, []byte("2L\t120\t140\n"), 0644); e != nil { panic(e) }
+	snps := filepath.Join(dir, "snps.bed")
+	if e := os.WriteFile(snps, []byte("2L\t130\t131\n"), 0644); e != nil { panic(e) }
+
+	var cfg UltimateConfig
+	cfg.PlotSpec = &PlotSpec{Highlights: []Highlight{{Bed: regions, Label: "Inversion"}}}
+	cfg.Highlights = []Highlight{{Bed: snps, Label: "SNP"}}
+	data := &ConfigData{}
+	spec, err := configPlotSpec(cfg, data)
+	if err != nil { panic(err) }
+	if len(spec.marks) != 2 || len(spec.markKeys) != 2 {
+		t.Errorf("marks %v and keys %v != expect 2 each", spec.marks, spec.markKeys)
+	}
+	if len(cfg.PlotSpec.Highlights) != 1 {
+		t.Errorf("config plotspec highlights changed to %v", cfg.PlotSpec.Highlights)
+	}
+
+	// Later windows use the spec that was read for the first one
+	if e := os.Remove(snps); e != nil { panic(e) }
+	again, err := configPlotSpec(cfg, data)
+	if err != nil {
+		t.Errorf("highlights read again: %v", err)
+	}
+	if again != spec {
+		t.Errorf("out %p != expect %p", again, spec)
+	}
+	win, err := spec.withConfig(cfg, nil)
+	if err != nil {
+		t.Errorf("withConfig read highlights again: %v", err)
+	} else if len(win.marks) != 2 {
+		t.Errorf("out %v != expect %v", len(win.marks), 2)
+	}
+}
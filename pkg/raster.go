@@ -0,0 +1,403 @@
+package covplots
+
+import (
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// A Canvas that draws into an RGBA image, with antialiasing. Drawing
+// coordinates are multiplied by Scale to get image pixels.
+type RasterCanvas struct {
+	W, H float64
+	Scale float64
+	Img *image.RGBA
+	// Coverage of each pixel in the row being filled
+	acc []float64
+}
+
+func NewRasterCanvas(w, h, scale float64) *RasterCanvas {
+	pw, ph := int(math.Ceil(w * scale)), int(math.Ceil(h * scale))
+	return &RasterCanvas{
+		W: w,
+		H: h,
+		Scale: scale,
+		Img: image.NewRGBA(image.Rect(0, 0, pw, ph)),
+		acc: make([]float64, pw + 2),
+	}
+}
+
+func (c *RasterCanvas) Size() (float64, float64) { return c.W, c.H }
+
+type rpoint struct {
+	x, y float64
+}
+
+type redge struct {
+	x0, y0, x1, y1 float64
+	dir int
+}
+
+// Blend col over pixel x, y with the given coverage (0 to 1)
+func (c *RasterCanvas) blend(x, y int, col Color, cov float64) {
+	a := cov * float64(col.A) / 255
+	if a <= 0 {
+		return
+	}
+	i := c.Img.PixOffset(x, y)
+	p := c.Img.Pix[i:i+4]
+	p[0] = uint8(float64(col.R) * a + float64(p[0]) * (1 - a) + 0.5)
+	p[1] = uint8(float64(col.G) * a + float64(p[1]) * (1 - a) + 0.5)
+	p[2] = uint8(float64(col.B) * a + float64(p[2]) * (1 - a) + 0.5)
+	p[3] = uint8(255 * a + float64(p[3]) * (1 - a) + 0.5)
+}
+
+// Add coverage w to the part of the accumulator row from xa to xb
+func (c *RasterCanvas) addSpan(xa, xb, w float64, lo, hi *int) {
+	maxx := float64(c.Img.Bounds().Dx())
+	xa = math.Max(0, math.Min(maxx, xa))
+	xb = math.Max(0, math.Min(maxx, xb))
+	if xb <= xa {
+		return
+	}
+	ia, ib := int(xa), int(xb)
+	if ia == ib {
+		c.acc[ia] += (xb - xa) * w
+	} else {
+		c.acc[ia] += (float64(ia + 1) - xa) * w
+		for i := ia + 1; i < ib; i++ {
+			c.acc[i] += w
+		}
+		c.acc[ib] += (xb - float64(ib)) * w
+	}
+	if ia < *lo { *lo = ia }
+	if ib > *hi { *hi = ib }
+}
+
+// Fill polygons given in image pixels with the nonzero winding rule. Each
+// pixel row is sampled at 4 heights, with exact coverage across the row.
+func (c *RasterCanvas) fillPolys(polys [][]rpoint, col Color) {
+	if col.A == 0 {
+		return
+	}
+	var edges []redge
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, poly := range polys {
+		for i := range poly {
+			a, b := poly[i], poly[(i + 1) % len(poly)]
+			if a.y == b.y {
+				continue
+			}
+			dir := 1
+			if a.y > b.y {
+				a, b = b, a
+				dir = -1
+			}
+			edges = append(edges, redge{a.x, a.y, b.x, b.y, dir})
+			ymin = math.Min(ymin, a.y)
+			ymax = math.Max(ymax, b.y)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].y0 < edges[j].y0 })
+
+	const samples = 4
+	type crossing struct {
+		x float64
+		dir int
+	}
+	py0 := int(math.Max(0, math.Floor(ymin)))
+	py1 := int(math.Min(float64(c.Img.Bounds().Dy() - 1), math.Ceil(ymax)))
+	var active []int
+	var xs []crossing
+	next := 0
+	for py := py0; py <= py1; py++ {
+		lo, hi := len(c.acc), -1
+		for s := 0; s < samples; s++ {
+			y := float64(py) + (float64(s) + 0.5) / samples
+			for next < len(edges) && edges[next].y0 <= y {
+				active = append(active, next)
+				next++
+			}
+			k := 0
+			for _, e := range active {
+				if edges[e].y1 > y {
+					active[k] = e
+					k++
+				}
+			}
+			active = active[:k]
+
+			xs = xs[:0]
+			for _, ei := range active {
+				e := edges[ei]
+				t := (y - e.y0) / (e.y1 - e.y0)
+				xs = append(xs, crossing{e.x0 + t * (e.x1 - e.x0), e.dir})
+			}
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+			wind := 0
+			for i := 0; i + 1 < len(xs); i++ {
+				wind += xs[i].dir
+				if wind != 0 {
+					c.addSpan(xs[i].x, xs[i+1].x, 1.0 / samples, &lo, &hi)
+				}
+			}
+		}
+		for x := lo; x <= hi && x < c.Img.Bounds().Dx(); x++ {
+			if cov := c.acc[x]; cov > 0 {
+				c.blend(x, py, col, math.Min(cov, 1))
+			}
+			c.acc[x] = 0
+		}
+	}
+}
+
+func (c *RasterCanvas) scaled(xs, ys []float64) []rpoint {
+	out := make([]rpoint, len(xs))
+	for i := range xs {
+		out[i] = rpoint{xs[i] * c.Scale, ys[i] * c.Scale}
+	}
+	return out
+}
+
+func signedArea(poly []rpoint) float64 {
+	a := 0.0
+	for i := range poly {
+		p, q := poly[i], poly[(i + 1) % len(poly)]
+		a += p.x * q.y - q.x * p.y
+	}
+	return a / 2
+}
+
+// Reverse poly if needed so that every polygon winds the same way, and
+// overlapping polygons fill as a union
+func orient(poly []rpoint) []rpoint {
+	if signedArea(poly) < 0 {
+		for i, j := 0, len(poly) - 1; i < j; i, j = i + 1, j - 1 {
+			poly[i], poly[j] = poly[j], poly[i]
+		}
+	}
+	return poly
+}
+
+func circlePoly(x, y, r float64) []rpoint {
+	n := int(math.Max(8, math.Min(64, r * 2)))
+	out := make([]rpoint, n)
+	for i := range out {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		out[i] = rpoint{x + r * math.Cos(a), y + r * math.Sin(a)}
+	}
+	return out
+}
+
+// The outline of a stroked polyline as polygons: one quad per segment, and a
+// round join at each vertex
+func strokePolys(pts []rpoint, width float64, closed bool) [][]rpoint {
+	var out [][]rpoint
+	hw := width / 2
+	n := len(pts)
+	segs := n - 1
+	if closed {
+		segs = n
+	}
+	for i := 0; i < segs; i++ {
+		a, b := pts[i], pts[(i + 1) % n]
+		dx, dy := b.x - a.x, b.y - a.y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy / l * hw, dx / l * hw
+		out = append(out, orient([]rpoint{
+			{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny},
+		}))
+	}
+	if hw > 0.75 {
+		for i, p := range pts {
+			if closed || (i > 0 && i < n - 1) {
+				out = append(out, orient(circlePoly(p.x, p.y, hw)))
+			}
+		}
+	}
+	return out
+}
+
+func (c *RasterCanvas) Rect(x, y, w, h float64, st Style) {
+	xs := []float64{x, x + w, x + w, x}
+	ys := []float64{y, y, y + h, y + h}
+	c.Polygon(xs, ys, st)
+}
+
+// Split a polyline into its dashes, given as alternating dash and gap lengths
+func dashRuns(pts []rpoint, dash []float64) [][]rpoint {
+	total := 0.0
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return [][]rpoint{pts}
+	}
+	var out [][]rpoint
+	run := []rpoint{pts[0]}
+	di, left := 0, dash[0]
+	on := true
+	for i := 1; i < len(pts); i++ {
+		a, b := pts[i-1], pts[i]
+		l := math.Hypot(b.x - a.x, b.y - a.y)
+		pos := 0.0
+		for l - pos > left {
+			pos += left
+			p := rpoint{a.x + (b.x - a.x) * pos / l, a.y + (b.y - a.y) * pos / l}
+			if on {
+				out = append(out, append(run, p))
+				run = nil
+			} else {
+				run = []rpoint{p}
+			}
+			on = !on
+			di = (di + 1) % len(dash)
+			left = dash[di]
+		}
+		left -= l - pos
+		if on {
+			run = append(run, b)
+		}
+	}
+	if on && len(run) > 1 {
+		out = append(out, run)
+	}
+	return out
+}
+
+func (c *RasterCanvas) Polyline(xs, ys []float64, st Style) {
+	if st.Stroke.A == 0 || len(xs) < 2 {
+		return
+	}
+	pts := c.scaled(xs, ys)
+	w := math.Max(st.StrokeWidth, 0.5) * c.Scale
+	if len(st.Dash) == 0 {
+		c.fillPolys(strokePolys(pts, w, false), st.Stroke)
+		return
+	}
+	dash := make([]float64, len(st.Dash))
+	for i, d := range st.Dash {
+		dash[i] = d * c.Scale
+	}
+	var polys [][]rpoint
+	for _, run := range dashRuns(pts, dash) {
+		polys = append(polys, strokePolys(run, w, false)...)
+	}
+	c.fillPolys(polys, st.Stroke)
+}
+
+func (c *RasterCanvas) Polygon(xs, ys []float64, st Style) {
+	pts := c.scaled(xs, ys)
+	c.fillPolys([][]rpoint{pts}, st.Fill)
+	if st.Stroke.A != 0 {
+		c.fillPolys(strokePolys(pts, math.Max(st.StrokeWidth, 0.5) * c.Scale, true), st.Stroke)
+	}
+}
+
+func (c *RasterCanvas) Circle(x, y, r float64, st Style) {
+	x, y, r = x * c.Scale, y * c.Scale, r * c.Scale
+	hw := st.StrokeWidth * c.Scale / 2
+	if st.Stroke.A == 0 {
+		hw = 0
+	}
+	b := c.Img.Bounds()
+	x0 := int(math.Max(0, math.Floor(x - r - hw - 1)))
+	x1 := int(math.Min(float64(b.Dx() - 1), math.Ceil(x + r + hw + 1)))
+	y0 := int(math.Max(0, math.Floor(y - r - hw - 1)))
+	y1 := int(math.Min(float64(b.Dy() - 1), math.Ceil(y + r + hw + 1)))
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(1, v)) }
+
+	for py := y0; py <= y1; py++ {
+		for px := x0; px <= x1; px++ {
+			d := math.Hypot(float64(px) + 0.5 - x, float64(py) + 0.5 - y)
+			if st.Fill.A != 0 {
+				cov := clamp(r + 0.5 - d)
+				if r < 0.5 {
+					cov *= 2 * r
+				}
+				c.blend(px, py, st.Fill, cov)
+			}
+			if hw > 0 {
+				c.blend(px, py, st.Stroke, clamp(hw + 0.5 - math.Abs(d - r)))
+			}
+		}
+	}
+}
+
+// Text is drawn with the built-in 5 by 7 pixel font, with each glyph
+// stretched over the Helvetica advance width and cap height of its character,
+// so that text takes the same space as in SVG and PDF output
+func (c *RasterCanvas) Text(x, y float64, s string, st TextStyle) {
+	size := st.Size * c.Scale
+	width := TextWidth(s, st.Size) * c.Scale
+	off := 0.0
+	switch st.Anchor {
+	case "middle": off = -width / 2
+	case "end": off = -width
+	}
+	sin, cos := math.Sincos(st.Rotate * math.Pi / 180)
+	x, y = x * c.Scale, y * c.Scale
+	tr := func(tx, ty float64) rpoint {
+		tx += off
+		return rpoint{x + tx * cos - ty * sin, y + tx * sin + ty * cos}
+	}
+
+	var polys [][]rpoint
+	uy := size * helveticaCapHeight / 1000 / 7
+	pen := 0.0
+	for _, r := range s {
+		glyph := fontGlyph(r)
+		adv := runeWidth(r) * size
+		// Leave a tenth of the advance on each side of the glyph
+		ux := adv * 0.8 / 5
+		left := pen + adv * 0.1
+		for row := 0; row < 7; row++ {
+			// Merge runs of set pixels in each row into one rectangle
+			for col := 0; col < 5; {
+				if glyph[col] & (1 << row) == 0 {
+					col++
+					continue
+				}
+				start := col
+				for col < 5 && glyph[col] & (1 << row) != 0 {
+					col++
+				}
+				x0, x1 := left + float64(start) * ux, left + float64(col) * ux
+				y0, y1 := float64(row - 7) * uy, float64(row - 6) * uy
+				polys = append(polys, orient([]rpoint{tr(x0, y0), tr(x1, y0), tr(x1, y1), tr(x0, y1)}))
+			}
+		}
+		pen += adv
+	}
+	c.fillPolys(polys, st.Color)
+}
+
+func (c *RasterCanvas) WritePNG(w io.Writer) error {
+	return png.Encode(w, c.Img)
+}
+
+// Write the image as a PNG
+func (c *RasterCanvas) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := c.WritePNG(cw)
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -12,6 +12,10 @@ type InputSet struct {
 	Functions []string `json:"functions"`
 	FunctionArgs []any `json: "functionargs"`
 	Extra any `json: "extra"`
+	// The first line of each file is a header that survives the window filter
+	Header bool `json:"header"`
+	// How the set is drawn and labelled in plots that read styles
+	Style SetStyle `json:"style"`
 }
 
 type UltimateConfig struct {
@@ -25,6 +29,24 @@ type UltimateConfig struct {
 	NoParent bool `json: "noparent"`
 	ManualChrs []string `json: "manualchrs"`
 	ManualChrsBedPath string `json: "manualchrsbedpath"]`
+	// Named column lists for the "preset_cols" functions
+	ColumnPresets map[string][]any `json:"columnpresets"`
+	// How chromosome names are split into a chromosome and a parent genome
+	Naming ChrNaming `json:"naming"`
+	// Path of a table of other names for each chromosome
+	ChrAliases string `json:"chraliases"`
+	// Input sets to quantile normalize against each other
+	QuantileNormalize QuantileNormalizeCfg `json:"quantilenormalize"`
+	// Formats, size, and resolution of native plots, and the booklet
+	PlotOutput PlotOutputCfg `json:"plotoutput"`
+	// Plot description for the "plotspec" plot function
+	PlotSpec *PlotSpec `json:"plotspec"`
+	// Compute y limits from the data instead of using Ylim
+	AutoYlim *AutoYlimCfg `json:"autoylim"`
+	// Panels of faceted plots, in order, and the input sets in each
+	Facets []FacetCfg `json:"facets"`
+	// Regions to mark in the plots of every plot function
+	Highlights []Highlight `json:"highlights"`
 }
 
 func ReadUltimateConfig(r io.Reader) ([]UltimateConfig, error) {
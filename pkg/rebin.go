@@ -0,0 +1,280 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type RebinArgs struct {
+	// Width of fixed bins, starting at 0 on each chromosome
+	Size int64
+	// Bed file of bins to use instead of fixed bins
+	Bed string
+	// "sum", "mean", "wmean", "max", "min", or "count"; default "wmean"
+	Method string
+	// 0-based column holding the value; default 3
+	Col int
+	// Also write bins that no input overlaps, with a value of NaN (0 for sum and count)
+	Empty bool
+}
+
+// Running totals for one bin. Frac is the fraction of each input interval
+// that lies in the bin, and bp the number of overlapping bases. ValFracSum is
+// both the "sum" value and the numerator of the "mean" value.
+type binAcc struct {
+	n int
+	fracSum float64
+	valFracSum float64
+	bpSum float64
+	valBpSum float64
+	min float64
+	max float64
+}
+
+func (a *binAcc) Add(val, frac, bp float64) {
+	if a.n == 0 || val < a.min {
+		a.min = val
+	}
+	if a.n == 0 || val > a.max {
+		a.max = val
+	}
+	a.n++
+	a.fracSum += frac
+	a.valFracSum += val * frac
+	a.bpSum += bp
+	a.valBpSum += val * bp
+}
+
+// Get the function that turns a bin's totals into its value
+func RebinMethodFunc(method string) (func(a *binAcc) float64, error) {
+	switch method {
+	case "sum":
+		// Each input value is split among bins in proportion to its overlap
+		return func(a *binAcc) float64 { return a.valFracSum }, nil
+	case "mean":
+		// Mean of overlapping values, each weighted by the fraction of its interval in the bin
+		return func(a *binAcc) float64 {
+			if a.fracSum == 0 { return math.NaN() }
+			return a.valFracSum / a.fracSum
+		}, nil
+	case "", "wmean":
+		// Mean of overlapping values, each weighted by the bp it overlaps
+		return func(a *binAcc) float64 {
+			if a.bpSum == 0 { return math.NaN() }
+			return a.valBpSum / a.bpSum
+		}, nil
+	case "max":
+		return func(a *binAcc) float64 {
+			if a.n == 0 { return math.NaN() }
+			return a.max
+		}, nil
+	case "min":
+		return func(a *binAcc) float64 {
+			if a.n == 0 { return math.NaN() }
+			return a.min
+		}, nil
+	case "count":
+		// Number of input intervals, counting partial overlaps as fractions
+		return func(a *binAcc) float64 { return a.fracSum }, nil
+	}
+	return nil, fmt.Errorf("RebinMethodFunc: unknown method %q", method)
+}
+
+type rebinInterval struct {
+	start int64
+	end int64
+	val float64
+}
+
+// Read every interval in r, grouped by chromosome in order of first appearance
+func readRebinIntervals(r io.Reader, col int) (chrs []string, ivals map[string][]rebinInterval, err error) {
+	h := Handle("readRebinIntervals: %w")
+	ivals = map[string][]rebinInterval{}
+
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	skipped := 0
+	for s.Scan() {
+		line := strings.Split(s.Text(), "\t")
+		if len(line) <= col || len(line) < 3 {
+			skipped++
+			continue
+		}
+		start, e1 := strconv.ParseInt(line[1], 0, 64)
+		end, e2 := strconv.ParseInt(line[2], 0, 64)
+		val, e3 := strconv.ParseFloat(line[col], 64)
+		if e1 != nil || e2 != nil || e3 != nil || math.IsNaN(val) {
+			skipped++
+			continue
+		}
+		if _, ok := ivals[line[0]]; !ok {
+			chrs = append(chrs, line[0])
+		}
+		ivals[line[0]] = append(ivals[line[0]], rebinInterval{start, end, val})
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, h(err)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Rebin: skipped %v lines without a numeric value\n", skipped)
+	}
+	return chrs, ivals, nil
+}
+
+func overlapLen(start1, end1, start2, end2 int64) int64 {
+	start := start1
+	if start2 > start { start = start2 }
+	end := end1
+	if end2 < end { end = end2 }
+	if end < start { return 0 }
+	return end - start
+}
+
+func addToBin(acc *binAcc, iv rebinInterval, binStart, binEnd int64) {
+	bp := overlapLen(iv.start, iv.end, binStart, binEnd)
+	width := iv.end - iv.start
+	var frac float64
+	if width <= 0 {
+		// A zero-width point counts fully toward the bin that contains it
+		frac = 1
+	} else if bp > 0 {
+		frac = float64(bp) / float64(width)
+	} else {
+		return
+	}
+	acc.Add(iv.val, frac, float64(bp))
+}
+
+// The bin holding position pos, rounding down for negative positions
+func floorDiv(pos, size int64) int64 {
+	b := pos / size
+	if pos % size != 0 && pos < 0 {
+		b--
+	}
+	return b
+}
+
+// Rebin one reader into fixed bins of width size
+func RebinFixed(r io.Reader, size int64, col int, empty bool, value func(*binAcc) float64) io.Reader {
+	return PipeWriteErr(func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		chrs, ivals, err := readRebinIntervals(r, col)
+		if err != nil { return fmt.Errorf("RebinFixed: %w", err) }
+
+		for _, chr := range chrs {
+			accs := map[int64]*binAcc{}
+			var first, last int64
+			for i, iv := range ivals[chr] {
+				lo := floorDiv(iv.start, size)
+				hi := floorDiv(iv.end - 1, size)
+				if iv.end <= iv.start {
+					hi = lo
+				}
+				for b := lo; b <= hi; b++ {
+					if accs[b] == nil {
+						accs[b] = &binAcc{}
+					}
+					addToBin(accs[b], iv, b * size, (b + 1) * size)
+				}
+				if i == 0 || lo < first { first = lo }
+				if i == 0 || hi > last { last = hi }
+			}
+
+			for b := first; b <= last && len(ivals[chr]) > 0; b++ {
+				acc, ok := accs[b]
+				if !ok {
+					if !empty { continue }
+					acc = &binAcc{}
+				}
+				fmt.Fprintf(bw, "%v\t%v\t%v\t%v\n", chr, b * size, (b + 1) * size, value(acc))
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+// Rebin one reader into the bins in a bed file. Bin chromosomes are matched
+// to each input name, or else its chromosome part, and bins keep the input name.
+// Bins may overlap, and each overlapping bin gets its share of an interval.
+func RebinBed(r io.Reader, bins *SpanIndex, naming ChrNaming, col int, empty bool, value func(*binAcc) float64) io.Reader {
+	return PipeWriteErr(func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		chrs, ivals, err := readRebinIntervals(r, col)
+		if err != nil { return fmt.Errorf("RebinBed: %w", err) }
+
+		for _, chr := range chrs {
+			binchr := bins.ChrFor(chr, naming)
+			accs := map[int]*binAcc{}
+			for _, iv := range ivals[chr] {
+				end := iv.end
+				if end <= iv.start {
+					end = iv.start + 1
+				}
+				for _, i := range bins.Overlaps(binchr, iv.start, end) {
+					if accs[i] == nil {
+						accs[i] = &binAcc{}
+					}
+					addToBin(accs[i], iv, bins.Entries[i].Start, bins.Entries[i].End)
+				}
+			}
+
+			for i, bin := range bins.Entries {
+				if bin.Chr != binchr {
+					continue
+				}
+				acc, ok := accs[i]
+				if !ok {
+					if !empty { continue }
+					acc = &binAcc{}
+				}
+				fmt.Fprintf(bw, "%v\t%v\t%v\t%v\n", chr, bin.Start, bin.End, value(acc))
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+// Aggregate every reader into fixed-size bins or bins from a bed file. Anyargs must be of type RebinArgs.
+func Rebin(rs []io.Reader, anyargs any, ctx MultiplotFuncCtx) ([]io.Reader, error) {
+	h := Handle("Rebin: %w")
+
+	var args RebinArgs
+	err := UnmarshalJsonOut(anyargs, &args)
+	if err != nil { return nil, h(err) }
+	if (args.Size > 0) == (args.Bed != "") {
+		return nil, h(fmt.Errorf("exactly one of Size and Bed must be set"))
+	}
+	if args.Col == 0 {
+		args.Col = 3
+	}
+	if args.Col < 3 {
+		return nil, h(fmt.Errorf("value column %v is part of the span", args.Col))
+	}
+
+	value, err := RebinMethodFunc(args.Method)
+	if err != nil { return nil, h(err) }
+
+	var bins *SpanIndex
+	if args.Bed != "" {
+		entries, err := ReadBedPath(args.Bed)
+		if err != nil { return nil, h(err) }
+		bins = NewSpanIndex(entries)
+	}
+
+	out := make([]io.Reader, len(rs))
+	for i, r := range rs {
+		if bins != nil {
+			out[i] = RebinBed(r, bins, ctx.Cfg.Naming, args.Col, args.Empty, value)
+		} else {
+			out[i] = RebinFixed(r, args.Size, args.Col, args.Empty, value)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package covplots
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runRebin(in string, args RebinArgs) string {
+	rs, err := Rebin([]io.Reader{strings.NewReader(in)}, args, MultiplotFuncCtx{})
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	return string(out)
+}
+
+func TestRebinFixed(t *testing.T) {
+	in := "chr1\t0\t10\t2\nchr1\t10\t30\t4\nchr1\t45\t50\t1\n"
+	tests := []struct {
+		method string
+		expect string
+	}{
+		{"sum", "chr1\t0\t20\t4\nchr1\t20\t40\t2\nchr1\t40\t60\t1\n"},
+		{"wmean", "chr1\t0\t20\t3\nchr1\t20\t40\t4\nchr1\t40\t60\t1\n"},
+		{"count", "chr1\t0\t20\t1.5\nchr1\t20\t40\t0.5\nchr1\t40\t60\t1\n"},
+		{"max", "chr1\t0\t20\t4\nchr1\t20\t40\t4\nchr1\t40\t60\t1\n"},
+	}
+	for _, test := range tests {
+		out := runRebin(in, RebinArgs{Size: 20, Method: test.method})
+		if out != test.expect {
+			t.Errorf("%v: out %q != expect %q", test.method, out, test.expect)
+		}
+	}
+}
+
+func TestRebinFixedNegative(t *testing.T) {
+	in := "chr1\t-30\t-10\t2\nchr1\t5\t10\t4\n"
+	expect := "chr1\t-40\t-20\t1\nchr1\t-20\t0\t1\nchr1\t0\t20\t4\n"
+	out := runRebin(in, RebinArgs{Size: 20, Method: "sum"})
+	if out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
+
+func TestRebinBed(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "bins.bed")
+	if e := os.WriteFile(f, []byte("chr1\t0\t15\nchr1\t15\t100\nchr2\t0\t10\n"), 0644); e != nil { panic(e) }
+	in := "chr1_a\t0\t10\t2\nchr1_a\t10\t30\t4\n"
+	expect := "chr1_a\t0\t15\t2.6666666666666665\nchr1_a\t15\t100\t4\n"
+
+	out := runRebin(in, RebinArgs{Bed: f, Method: "wmean"})
+	if out != expect {
+		t.Errorf("out %q != expect %q", out, expect)
+	}
+}
@@ -81,45 +81,53 @@ func MustGetColSums(cols ...int) func(BedEntry) (float64, error) {
 }
 
 func FilterByStdevs(col int, stdevs float64, entries ...BedEntry) ([]BedEntry, error) {
-	h := Handle("GetBedVals: %w")
-
-	getval := GetColFloat(col)
-
-	vals, err := GetBedVals(getval, entries...)
-	if err != nil { return nil, h(err) }
-
-	stdev, err := stats.StandardDeviation(vals)
-	if err != nil { return nil, h(err) }
-
-	mean, err := stats.Mean(vals)
-	if err != nil { return nil, h(err) }
+	return FilterByStdevsWeighted(col, stdevs, false, entries...)
+}
 
-	lowthresh := mean - (stdev * stdevs)
-	hithresh := mean + (stdev * stdevs)
+func FilterByColsumStdevs(cols []int, stdevs float64, entries ...BedEntry) ([]BedEntry, error) {
+	return FilterByColsumStdevsWeighted(cols, stdevs, false, entries...)
+}
 
-	filt := func(b BedEntry) bool {
-		val, err := getval(b)
-		if err != nil { return false }
-		return (val >= lowthresh) && (val <= hithresh)
-	}
+// Like FilterByStdevs, but if bpWeighted, weight each entry's value by its
+// span length. The mean and standard deviation are taken over all entries,
+// so pass the whole data set rather than one window of it.
+func FilterByStdevsWeighted(col int, stdevs float64, bpWeighted bool, entries ...BedEntry) ([]BedEntry, error) {
+	out, err := filterByValStdevs(GetColFloat(col), stdevs, bpWeighted, entries...)
+	if err != nil { return nil, fmt.Errorf("FilterByStdevsWeighted: %w", err) }
+	return out, nil
+}
 
-	return FilterBedEntries(filt, entries...), nil
-	// func Percentile(input Float64Data, percent float64) (percentile float64, err error) {
+// Like FilterByColsumStdevs, but if bpWeighted, weight each entry's value by
+// its span length. As in FilterByStdevsWeighted, the statistics are over all
+// entries.
+func FilterByColsumStdevsWeighted(cols []int, stdevs float64, bpWeighted bool, entries ...BedEntry) ([]BedEntry, error) {
+	out, err := filterByValStdevs(GetColSums(cols...), stdevs, bpWeighted, entries...)
+	if err != nil { return nil, fmt.Errorf("FilterByColsumStdevsWeighted: %w", err) }
+	return out, nil
 }
 
-func FilterByColsumStdevs(cols []int, stdevs float64, entries ...BedEntry) ([]BedEntry, error) {
+func filterByValStdevs(getval func(BedEntry) (float64, error), stdevs float64, bpWeighted bool, entries ...BedEntry) ([]BedEntry, error) {
 	h := Handle("GetBedVals: %w")
 
-	getval := GetColSums(cols...)
-
 	vals, err := GetBedVals(getval, entries...)
 	if err != nil { return nil, h(err) }
 
-	stdev, err := stats.StandardDeviation(vals)
-	if err != nil { return nil, h(err) }
+	var stdev, mean float64
+	if bpWeighted {
+		lens := BedSpanLens(entries...)
 
-	mean, err := stats.Mean(vals)
-	if err != nil { return nil, h(err) }
+		stdev, err = WeightedStdDevP(vals, lens)
+		if err != nil { return nil, h(err) }
+
+		mean, err = WeightedMean(vals, lens)
+		if err != nil { return nil, h(err) }
+	} else {
+		stdev, err = stats.StandardDeviation(vals)
+		if err != nil { return nil, h(err) }
+
+		mean, err = stats.Mean(vals)
+		if err != nil { return nil, h(err) }
+	}
 
 	lowthresh := mean - (stdev * stdevs)
 	hithresh := mean + (stdev * stdevs)
@@ -131,7 +139,6 @@ func FilterByColsumStdevs(cols []int, stdevs float64, entries ...BedEntry) ([]Be
 	}
 
 	return FilterBedEntries(filt, entries...), nil
-	// func Percentile(input Float64Data, percent float64) (percentile float64, err error) {
 }
 
 func WriteBedEntries(w io.Writer, entries ...BedEntry) error {
@@ -145,9 +152,10 @@ func WriteBedEntries(w io.Writer, entries ...BedEntry) error {
 	return nil
 }
 
-func ReadCovFiltFlags() ([]int, float64) {
+func ReadCovFiltFlags() ([]int, float64, bool) {
 	colsp := flag.String("c", "", "comma-separated columns to filter by")
 	stdevp := flag.String("s", "", "Standard deviations to keep")
+	bpp := flag.Bool("bp", false, "Weight each line by its length in bp")
 	flag.Parse()
 	if *colsp == "" {
 		panic(fmt.Errorf("missing column specifier -c"))
@@ -166,16 +174,16 @@ func ReadCovFiltFlags() ([]int, float64) {
 		}
 		cols = append(cols, int(col))
 	}
-	return cols, stdevs
+	return cols, stdevs, *bpp
 }
 
 func FullFilterCov() error {
-	cols, stdevs := ReadCovFiltFlags()
+	cols, stdevs, bp := ReadCovFiltFlags()
 
 	entries, err := ReadBed(os.Stdin)
 	if err != nil { return err }
 
-	fentries, err := FilterByColsumStdevs(cols, stdevs, entries...)
+	fentries, err := FilterByColsumStdevsWeighted(cols, stdevs, bp, entries...)
 	if err != nil { return err }
 
 	err = WriteBedEntries(os.Stdout, fentries...)
@@ -18,6 +18,7 @@ type AllSingleFlags struct {
 	WholeGenome bool
 	SelectWins string
 	NoParent bool
+	Naming ChrNaming
 }
 
 func GetAllSingleFlags() AllSingleFlags {
@@ -25,13 +26,20 @@ func GetAllSingleFlags() AllSingleFlags {
 	flag.StringVar(&f.Config, "i", "", "Input config file. Tab-separated columns containing input bed path, chromosome length bed path, and output prefix. Default stdin.")
 	flag.IntVar(&f.WinSize, "w", 1000000, "Sliding window plot size (default = 1000000).")
 	flag.IntVar(&f.WinStep, "s", 1000000, "Sliding window step distance (default = 1000000).")
+	flag.StringVar(&f.Naming.Sep, "sep", "", "Separator between chromosome and parent names (default \"_\").")
+	flag.StringVar(&f.Naming.Parent, "parent", "", "Position of the parent name: suffix (default), prefix, or none.")
 	flag.Parse()
 
 	return f
 }
 
+// SinglePlotNamed with the default chromosome naming scheme
 func SinglePlot(r io.Reader, outpre, chr string, start, end int) error {
-	fr, err := Filter(r, chr, start, end)
+	return SinglePlotNamed(r, ChrNaming{}, outpre, chr, start, end)
+}
+
+func SinglePlotNamed(r io.Reader, naming ChrNaming, outpre, chr string, start, end int) error {
+	fr, err := FilterNamed(r, naming, chr, start, end)
 	if err != nil {
 		return err
 	}
@@ -46,14 +54,19 @@ func SinglePlot(r io.Reader, outpre, chr string, start, end int) error {
 	return nil
 }
 
+// SinglePlotPathNamed with the default chromosome naming scheme
 func SinglePlotPath(path string, outpre, chr string, start, end int) error {
+	return SinglePlotPathNamed(path, ChrNaming{}, outpre, chr, start, end)
+}
+
+func SinglePlotPathNamed(path string, naming ChrNaming, outpre, chr string, start, end int) error {
 	r, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("SinglePlotPath: %w", err)
 	}
 	defer r.Close()
 
-	err = SinglePlot(r, outpre, chr, start, end)
+	err = SinglePlotNamed(r, naming, outpre, chr, start, end)
 	if err != nil {
 		return fmt.Errorf("SinglePlotPath: %w", err)
 	}
@@ -65,6 +78,7 @@ type Config struct {
 	Chrlenpath string
 	Outpre string
 	Inpath2 string
+	Naming ChrNaming
 }
 
 type ChrLenSet struct {
@@ -72,19 +86,77 @@ type ChrLenSet struct {
 	Len int
 }
 
-func ParseChrLenSet(line string) (ChrLenSet, error) {
+// The layout of a chromosome length file
+type ChrLenFormat int
+
+const (
+	// chrom, start, end; the length is the end column
+	ChrLenBed ChrLenFormat = iota
+	// chrom, length, plus any other columns (.fai, chrom.sizes)
+	ChrLenSizes
+)
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// Detect the chromosome length format from the first line of a file: two
+// columns, or a length followed by text or by the numeric columns of a .fai,
+// are sizes, and a start no greater than its end is bed. The file name only
+// decides between formats that the line fits equally well.
+func DetectChrLenFormat(path, line string) (ChrLenFormat, error) {
+	sl := strings.Split(line, "\t")
+	if len(sl) < 2 || !isInt(sl[1]) {
+		return 0, fmt.Errorf("DetectChrLenFormat: %v: first line %q has no length in its second column", path, line)
+	}
+	fai := len(sl) == 5 || len(sl) == 6
+	for _, field := range sl[2:] {
+		fai = fai && isInt(field)
+	}
+	sizes := len(sl) == 2 || !isInt(sl[2]) || fai
+	bed := false
+	if len(sl) >= 3 && isInt(sl[2]) {
+		start, _ := strconv.ParseInt(sl[1], 10, 64)
+		end, _ := strconv.ParseInt(sl[2], 10, 64)
+		bed = start <= end
+	}
+
+	switch {
+	case sizes && !bed:
+		return ChrLenSizes, nil
+	case bed && !sizes:
+		return ChrLenBed, nil
+	case bed && sizes:
+		name := strings.TrimSuffix(path, ".gz")
+		for _, ext := range []string{".fai", ".sizes"} {
+			if strings.HasSuffix(name, ext) {
+				return ChrLenSizes, nil
+			}
+		}
+		if strings.HasSuffix(name, ".bed") {
+			return ChrLenBed, nil
+		}
+		return 0, fmt.Errorf("DetectChrLenFormat: %v: first line %q could be a .fai or a bed file; name it .fai or .bed", path, line)
+	}
+	return 0, fmt.Errorf("DetectChrLenFormat: %v: first line %q is neither a length file nor a bed file", path, line)
+}
+
+// Parse one line of a chromosome length file. The parent is removed from the chromosome name if present.
+func ParseChrLenSet(line string, format ChrLenFormat, naming ChrNaming) (ChrLenSet, error) {
 	var out ChrLenSet
 	sl := strings.Split(line, "\t")
-	if len(sl) < 3 {
-		return out, fmt.Errorf("line %v has less than 3 fields", sl)
+	if len(sl) < 2 {
+		return out, fmt.Errorf("ParseChrLenSet: line %v has less than 2 fields", sl)
 	}
 
-	chrsplit := strings.Split(sl[0], "_")
-	if len(chrsplit) < 2 {
-		return out, fmt.Errorf("chr field %v less than 2 fields", chrsplit)
+	lencol := 2
+	if format == ChrLenSizes || len(sl) == 2 {
+		lencol = 1
 	}
-	out.Chr = chrsplit[0]
-	chrlen, err := strconv.ParseInt(sl[2], 0, 64)
+
+	out.Chr = naming.Chr(sl[0])
+	chrlen, err := strconv.ParseInt(sl[lencol], 0, 64)
 	if err != nil {
 		return out, fmt.Errorf("ParseChrLenSet: %w", err)
 	}
@@ -92,40 +164,85 @@ func ParseChrLenSet(line string) (ChrLenSet, error) {
 	return out, nil
 }
 
+// GetChrLensNamed with the default chromosome naming scheme
 func GetChrLens(chrlenpath string) ([]ChrLenSet, error) {
-	r, err := os.Open(chrlenpath)
+	return GetChrLensNamed(chrlenpath, ChrNaming{})
+}
+
+// Read chromosome lengths from a bed, .fai, or chrom.sizes file. Chromosomes
+// are returned in the order they first appear in the file, with the longest
+// length seen for each.
+func GetChrLensNamed(chrlenpath string, naming ChrNaming) ([]ChrLenSet, error) {
+	return GetChrLensAliased(chrlenpath, naming, nil)
+}
+
+// Like GetChrLensNamed, but rename chromosomes to their canonical names
+// before removing parents. Aliases may be nil.
+func GetChrLensAliased(chrlenpath string, naming ChrNaming, aliases ChrAliases) ([]ChrLenSet, error) {
+	if err := naming.Validate(); err != nil {
+		return nil, fmt.Errorf("GetChrLens: %w", err)
+	}
+	r, err := OpenMaybeGz(chrlenpath)
 	if err != nil {
 		return nil, fmt.Errorf("GetChrLens: %w", err)
 	}
 	defer r.Close()
+
+	var format ChrLenFormat
+	formatKnown := false
 	s := bufio.NewScanner(r)
 	s.Buffer([]byte{}, 1e12)
-	chrlens := make(map[string]int)
+
+	var out []ChrLenSet
+	idxs := make(map[string]int)
 	for s.Scan() {
-		set, err := ParseChrLenSet(s.Text())
-		chrlen, ok := chrlens[set.Chr]
-		if !ok {
-			chrlen = 0
-			chrlens[set.Chr] = 0
+		if s.Text() == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
 		}
-		if set.Len > chrlen {
-			chrlens[set.Chr] = set.Len
+		line := s.Text()
+		if !formatKnown {
+			if format, err = DetectChrLenFormat(chrlenpath, line); err != nil {
+				return nil, fmt.Errorf("GetChrLens: %w", err)
+			}
+			formatKnown = true
 		}
-
+		if aliases != nil {
+			name, rest, _ := strings.Cut(line, "\t")
+			canon, ok := aliases.Rename(name, naming)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "GetChrLens: chromosome %v not in alias table\n", name)
+			}
+			line = canon + "\t" + rest
+		}
+		set, err := ParseChrLenSet(line, format, naming)
 		if err != nil {
 			return nil, fmt.Errorf("GetChrLens: %w", err)
 		}
-	}
 
-	var out []ChrLenSet
-	for chr, chrlen := range chrlens {
-		out = append(out, ChrLenSet{chr, chrlen})
+		idx, ok := idxs[set.Chr]
+		if !ok {
+			idxs[set.Chr] = len(out)
+			out = append(out, set)
+			continue
+		}
+		if set.Len > out[idx].Len {
+			out[idx].Len = set.Len
+		}
 	}
+	if s.Err() != nil {
+		return nil, fmt.Errorf("GetChrLens: %w", s.Err())
+	}
+
 	return out, nil
 }
 
+// SinglePlotWinsNamed with the default chromosome naming scheme
 func SinglePlotWins(inpath, chrlenpath, outpre string, winsize, winstep int) error {
-	chrlens, err := GetChrLens(chrlenpath)
+	return SinglePlotWinsNamed(inpath, chrlenpath, outpre, winsize, winstep, ChrNaming{})
+}
+
+func SinglePlotWinsNamed(inpath, chrlenpath, outpre string, winsize, winstep int, naming ChrNaming) error {
+	chrlens, err := GetChrLensNamed(chrlenpath, naming)
 	if err != nil {
 		return fmt.Errorf("SinglePlotWins: %w", err)
 	}
@@ -135,7 +252,7 @@ func SinglePlotWins(inpath, chrlenpath, outpre string, winsize, winstep int) err
 		for start := 0; start < chrlen; start += winstep {
 			end := start + winsize
 			outpre2 := fmt.Sprintf("%s_%v_%v_%v", outpre, chr, start, end)
-			err = SinglePlotPath(inpath, outpre2, chr, start, end)
+			err = SinglePlotPathNamed(inpath, naming, outpre2, chr, start, end)
 			if err != nil {
 				return fmt.Errorf("SinglePlotWins: %w", err)
 			}
@@ -154,12 +271,12 @@ func ReadConfig(r io.Reader, subtract bool) ([]Config, error) {
 			if len(line) < 3 {
 				return nil, fmt.Errorf("ReadConfig: line %v less than 3 fields", line)
 			}
-			out = append(out, Config{line[0], line[1], line[2], ""})
+			out = append(out, Config{Inpath: line[0], Chrlenpath: line[1], Outpre: line[2]})
 		} else {
 			if len(line) < 4 {
 				return nil, fmt.Errorf("ReadConfig: line %v less than 4 fields", line)
 			}
-			out = append(out, Config{line[0], line[1], line[2], line[3]})
+			out = append(out, Config{Inpath: line[0], Chrlenpath: line[1], Outpre: line[2], Inpath2: line[3]})
 		}
 	}
 	return out, nil
@@ -223,7 +340,7 @@ func SinglePlotWinsParallel(cfgs []Config, winsize, winstep, threads int) error
 	for i:=0; i<threads; i++ {
 		go func() {
 			for cfg := range jobs {
-				errs <- SinglePlotWins(cfg.Inpath, cfg.Chrlenpath, cfg.Outpre, winsize, winstep)
+				errs <- SinglePlotWinsNamed(cfg.Inpath, cfg.Chrlenpath, cfg.Outpre, winsize, winstep, cfg.Naming)
 			}
 		}()
 	}
@@ -247,6 +364,9 @@ func RunAllSingle() {
 	if err != nil {
 		panic(err)
 	}
+	for i, _ := range cfgs {
+		cfgs[i].Naming = f.Naming
+	}
 
 	err = SinglePlotWinsParallel(cfgs, f.WinSize, f.WinStep, 8)
 	if err != nil {
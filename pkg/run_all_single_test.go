@@ -0,0 +1,68 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetChrLens(t *testing.T) {
+	dir := t.TempDir()
+	inputs := map[string]string{
+		"genome.fa.fai": "X\t23542271\t4\t60\t61\n2L\t23513712\t23934584\t60\t61\n3R\t32079331\t47839920\t60\t61\n",
+		"chrom.sizes": "X\t23542271\n2L\t23513712\n3R\t32079331\n",
+		"chrlens.bed": "X_ISO1\t0\t23542271\nX_A4\t0\t23000000\n2L_ISO1\t0\t23513712\n3R_ISO1\t0\t32079331\n",
+		// Detected from the columns, not the name
+		"genome_index.txt": "X\t23542271\t4\t60\t61\n2L\t23513712\t23934584\t60\t61\n3R\t32079331\t47839920\t60\t61\n",
+		"chrlens.fai": "X\t23542271\n2L\t23513712\n3R\t32079331\n",
+		"named_chrlens.txt": "X\t0\t23542271\tchrX\n2L\t0\t23513712\tchr2L\n3R\t0\t32079331\tchr3R\n",
+	}
+	expect := []ChrLenSet{
+		ChrLenSet{"X", 23542271},
+		ChrLenSet{"2L", 23513712},
+		ChrLenSet{"3R", 32079331},
+	}
+
+	for name, text := range inputs {
+		path := filepath.Join(dir, name)
+		if e := os.WriteFile(path, []byte(text), 0644); e != nil { panic(e) }
+
+		out, e := GetChrLens(path)
+		if e != nil { panic(e) }
+
+		if !reflect.DeepEqual(out, expect) {
+			t.Errorf("%v: out %v != expect %v", name, out, expect)
+		}
+	}
+}
+
+func TestDetectChrLenFormat(t *testing.T) {
+	for _, c := range []struct {
+		path string
+		line string
+		expect ChrLenFormat
+	}{
+		{"a.txt", "X\t100", ChrLenSizes},
+		{"a.txt", "X\t100\thttp://example.org/X.fa", ChrLenSizes},
+		{"a.txt", "X\t100\t4\t60\t61", ChrLenSizes},
+		{"a.txt", "X\t0\t100", ChrLenBed},
+		{"a.txt", "X\t0\t100\tname\t0\t+", ChrLenBed},
+		// Fits both; the name decides
+		{"a.fai", "X\t0\t100\t60\t61", ChrLenSizes},
+		{"a.bed.gz", "X\t0\t100\t60\t61", ChrLenBed},
+	} {
+		out, err := DetectChrLenFormat(c.path, c.line)
+		if err != nil {
+			panic(err)
+		}
+		if out != c.expect {
+			t.Errorf("%v %q: out %v != expect %v", c.path, c.line, out, c.expect)
+		}
+	}
+	for _, line := range []string{"X\t0\t100\t60\t61", "X\tlong", "X"} {
+		if _, err := DetectChrLenFormat("a.txt", line); err == nil {
+			t.Errorf("no error for line %q", line)
+		}
+	}
+}
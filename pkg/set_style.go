@@ -0,0 +1,185 @@
+package covplots
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// How one input set is drawn. Empty fields use the plot's defaults.
+type SetStyle struct {
+	Color string `json:"color"`
+	// A line type name or hex dash string, as in PlotSpec scales
+	Linetype string `json:"linetype"`
+	// "point", "line", "bar", or "area"
+	Geom string `json:"geom"`
+	// Opacity from 0 to 1
+	Alpha float64 `json:"alpha"`
+	// Line width, or point radius, in pixels
+	Width float64 `json:"width"`
+	// Name shown in legends instead of the set's name
+	Label string `json:"label"`
+}
+
+var setStyleGeoms = map[string]struct{}{"point": {}, "line": {}, "bar": {}, "area": {}}
+
+func (s SetStyle) Validate() error {
+	h := Handle("SetStyle.Validate: %w")
+	if s.Color != "" {
+		if _, err := ParseColor(s.Color); err != nil { return h(err) }
+	}
+	if s.Linetype != "" {
+		if _, err := ParseLinetype(s.Linetype); err != nil { return h(err) }
+	}
+	if _, ok := setStyleGeoms[s.Geom]; s.Geom != "" && !ok {
+		return h(fmt.Errorf("unknown geom %q", s.Geom))
+	}
+	if s.Alpha < 0 || s.Alpha > 1 {
+		return h(fmt.Errorf("alpha %v not between 0 and 1", s.Alpha))
+	}
+	if s.Width < 0 {
+		return h(fmt.Errorf("negative width %v", s.Width))
+	}
+	if strings.ContainsAny(s.Label, "\t\n") {
+		return h(fmt.Errorf("label %q contains a tab or newline", s.Label))
+	}
+	return nil
+}
+
+// The styles of the sets in plfmt data, by NAME, are kept in this file next
+// to it, so that readers of the fixed plfmt columns are not affected
+func SetStylesPath(outpre string) string {
+	return outpre + "_plfmt_styles.txt"
+}
+
+var setStyleHeader = []string{"NAME", "COLOR", "LINETYPE", "GEOM", "ALPHA", "WIDTH", "LABEL"}
+
+func naString(s string) string {
+	if s == "" {
+		return "NA"
+	}
+	return s
+}
+
+func naFloat(f float64) string {
+	if f == 0 {
+		return "NA"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func WriteSetStyles(w io.Writer, styles map[string]SetStyle, names []string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, strings.Join(setStyleHeader, "\t"))
+	for _, name := range names {
+		s, ok := styles[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, naString(s.Color), naString(s.Linetype),
+			naString(s.Geom), naFloat(s.Alpha), naFloat(s.Width), naString(s.Label))
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("WriteSetStyles: %w", err)
+	}
+	return nil
+}
+
+// Write the styles of the input sets that have one next to outpre's plfmt
+// data. Without any, remove the file left by an earlier run.
+func WriteInputSetStyles(outpre string, sets []InputSet) error {
+	h := Handle("WriteInputSetStyles: %w")
+	styles := map[string]SetStyle{}
+	var names []string
+	for _, set := range sets {
+		if set.Style == (SetStyle{}) {
+			continue
+		}
+		if err := set.Style.Validate(); err != nil { return h(fmt.Errorf("input set %q: %w", set.Name, err)) }
+		if _, ok := styles[set.Name]; !ok {
+			names = append(names, set.Name)
+		}
+		styles[set.Name] = set.Style
+	}
+
+	path := SetStylesPath(outpre)
+	if len(styles) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) { return h(err) }
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil { return h(err) }
+	err = WriteSetStyles(f, styles, names)
+	if e := f.Close(); err == nil { err = e }
+	if err != nil { return h(err) }
+	return nil
+}
+
+func ReadSetStyles(r io.Reader) (map[string]SetStyle, error) {
+	h := Handle("ReadSetStyles: %w")
+	out := map[string]SetStyle{}
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for i := 0; s.Scan(); i++ {
+		if i == 0 || s.Text() == "" {
+			continue
+		}
+		line := strings.Split(s.Text(), "\t")
+		if len(line) != len(setStyleHeader) {
+			return nil, h(fmt.Errorf("line %v does not have %v fields", line, len(setStyleHeader)))
+		}
+		for j, field := range line {
+			if field == "NA" {
+				line[j] = ""
+			}
+		}
+		st := SetStyle{Color: line[1], Linetype: line[2], Geom: line[3], Label: line[6]}
+		var e1, e2 error
+		if line[4] != "" {
+			st.Alpha, e1 = strconv.ParseFloat(line[4], 64)
+		}
+		if line[5] != "" {
+			st.Width, e2 = strconv.ParseFloat(line[5], 64)
+		}
+		if e1 != nil || e2 != nil {
+			return nil, h(fmt.Errorf("could not parse alpha and width of line %v", line))
+		}
+		out[line[0]] = st
+	}
+	if err := s.Err(); err != nil { return nil, h(err) }
+	return out, nil
+}
+
+// Read the set styles next to outpre's plfmt data. Data without a styles file
+// has no styles.
+func ReadPlfmtSetStyles(outpre string) (map[string]SetStyle, error) {
+	r, err := os.Open(SetStylesPath(outpre))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ReadPlfmtSetStyles: %w", err)
+	}
+	defer r.Close()
+	return ReadSetStyles(r)
+}
+
+// Styles from a and b, with a's style for a set used when both have one
+func mergeSetStyles(a, b map[string]SetStyle) map[string]SetStyle {
+	if len(b) == 0 {
+		return a
+	}
+	out := map[string]SetStyle{}
+	for name, s := range b {
+		out[name] = s
+	}
+	for name, s := range a {
+		out[name] = s
+	}
+	return out
+}
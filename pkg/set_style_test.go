@@ -0,0 +1,83 @@
+package covplots
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetStylesRoundTrip(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	sets := []InputSet{
+		{Name: "ixw", Style: SetStyle{Color: "#1b9e77", Linetype: "dashed", Geom: "line", Alpha: 0.5, Width: 2, Label: "ixw (female)"}},
+		{Name: "ixa"},
+		{Name: "bg", Style: SetStyle{Geom: "area"}},
+	}
+	if e := WriteInputSetStyles(outpre, sets); e != nil { panic(e) }
+
+	out, err := ReadPlfmtSetStyles(outpre)
+	if err != nil {
+		panic(err)
+	}
+	expect := map[string]SetStyle{"ixw": sets[0].Style, "bg": sets[2].Style}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+
+	// Without styles, the file of an earlier run is removed
+	if e := WriteInputSetStyles(outpre, sets[1:2]); e != nil { panic(e) }
+	out, err = ReadPlfmtSetStyles(outpre)
+	if err != nil {
+		panic(err)
+	}
+	if out != nil {
+		t.Errorf("out %v != expect %v", out, nil)
+	}
+
+	bad := []InputSet{{Name: "ixw", Style: SetStyle{Geom: "violin"}}}
+	if e := WriteInputSetStyles(outpre, bad); e == nil {
+		t.Errorf("no error for geom %q", "violin")
+	}
+}
+
+func TestPlotNativeSetStyles(t *testing.T) {
+	outpre := filepath.Join(t.TempDir(), "out")
+	plfmt := "2L\t0\t10\t1\tixw\t0\t0\t10\n" +
+		"2L\t10\t20\t2\tixw\t0\t10\t20\n" +
+		"2L\t0\t10\t3\tixa\t0\t0\t10\n" +
+		"2L\t10\t20\t4\tixa\t0\t10\t20\n"
+	if e := os.WriteFile(outpre + "_plfmt.bed", []byte(plfmt), 0644); e != nil { panic(e) }
+	sets := []InputSet{
+		{Name: "ixw", Style: SetStyle{Color: "#ff0000", Linetype: "dotted", Geom: "line", Width: 2, Label: "Female"}},
+		{Name: "ixa", Style: SetStyle{Color: "#0000ff", Geom: "bar", Alpha: 0.5}},
+	}
+	if e := WriteInputSetStyles(outpre, sets); e != nil { panic(e) }
+
+	if e := PlotNativeAny(PlfmtPlain)(outpre, []float64{0, 5}, nil, MultiplotPlotFuncArgs{}); e != nil { panic(e) }
+	svg, err := os.ReadFile(outpre + "_plotted.svg")
+	if err != nil {
+		panic(err)
+	}
+	s := string(svg)
+	for _, want := range []string{`stroke="#ff0000"`, `stroke-dasharray="2 6"`, `fill="#0000ff" fill-opacity="0.502"`, "Female"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("svg does not contain %q", want)
+		}
+	}
+	if strings.Contains(s, "<circle") {
+		t.Errorf("svg contains points, but no set is drawn as points")
+	}
+
+	if e := PlotHTMLAny(PlfmtPlain)(outpre, nil, nil, MultiplotPlotFuncArgs{}); e != nil { panic(e) }
+	page, err := os.ReadFile(outpre + "_plotted.html")
+	if err != nil {
+		panic(err)
+	}
+	for _, want := range []string{`"label":"Female"`, `"geom":"bar"`, `"dash":[1,3]`, `"color":"#0000ff"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("html does not contain %q", want)
+		}
+	}
+}
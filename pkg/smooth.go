@@ -0,0 +1,285 @@
+package covplots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type SmoothArgs struct {
+	// Window width in bp, centered on each record (trailing for ema)
+	WinSize float64
+	// Standard deviation of the gaussian kernel in bp; default WinSize / 4
+	Sigma float64
+	// Fraction (0 to 1) of the window's bp that must be covered by records
+	// with values; windows below this give NaN
+	MinCoverage float64
+	// 0-based value column; default 3
+	Col int
+}
+
+type smoothRec struct {
+	line []string
+	mid float64
+	len float64
+	val float64
+}
+
+// Read all records of r, grouped by chromosome in order of first appearance
+// and sorted by midpoint within each chromosome
+func readSmoothRecs(r io.Reader, col int) ([]string, map[string][]smoothRec, error) {
+	var chrs []string
+	recs := map[string][]smoothRec{}
+
+	s := bufio.NewScanner(r)
+	s.Buffer([]byte{}, 1e12)
+	for s.Scan() {
+		line := strings.Split(s.Text(), "\t")
+		if len(line) <= col {
+			fmt.Fprintf(os.Stderr, "Smooth: line %v too short\n", line)
+			continue
+		}
+		start, e1 := strconv.ParseFloat(line[1], 64)
+		end, e2 := strconv.ParseFloat(line[2], 64)
+		if e1 != nil || e2 != nil {
+			fmt.Fprintf(os.Stderr, "Smooth: could not parse span of line %v\n", line)
+			continue
+		}
+		val, e := strconv.ParseFloat(line[col], 64)
+		if e != nil {
+			val = math.NaN()
+		}
+		if _, ok := recs[line[0]]; !ok {
+			chrs = append(chrs, line[0])
+		}
+		recs[line[0]] = append(recs[line[0]], smoothRec{line, (start + end) / 2, end - start, val})
+	}
+	if e := s.Err(); e != nil {
+		return nil, nil, fmt.Errorf("readSmoothRecs: %w", e)
+	}
+
+	for _, chr := range chrs {
+		rs := recs[chr]
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].mid < rs[j].mid })
+	}
+	return chrs, recs, nil
+}
+
+// A smoother computes the smoothed value at recs[i] from the records
+// recs[lo:hi], whose midpoints are inside its window. It is called for every
+// i in order, starting from 0 on each chromosome.
+type smoother func(recs []smoothRec, i, lo, hi int) float64
+
+// Smooth one reader. Windows never cross chromosomes, and are clipped to the
+// span from 0 to the last record's end before coverage is computed.
+func SmoothOne(r io.Reader, args SmoothArgs, trailing bool, f smoother) io.Reader {
+	return SmoothOneLens(r, args, trailing, f, nil, ChrNaming{})
+}
+
+// Like SmoothOne, but windows are clipped to the end of each chromosome in
+// chrlens, by chromosome without its parent. Chromosomes missing from chrlens
+// end at their last record.
+func SmoothOneLens(r io.Reader, args SmoothArgs, trailing bool, f smoother, chrlens map[string]int64, naming ChrNaming) io.Reader {
+	return PipeWriteErr(func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+
+		chrs, recs, err := readSmoothRecs(r, args.Col)
+		if err != nil { return fmt.Errorf("SmoothOneLens: %w", err) }
+
+		for _, chr := range chrs {
+			rs := recs[chr]
+			chrEnd := 0.0
+			covered := make([]float64, len(rs) + 1)
+			for i, rec := range rs {
+				if end := rec.mid + rec.len / 2; end > chrEnd {
+					chrEnd = end
+				}
+				covered[i+1] = covered[i]
+				if !math.IsNaN(rec.val) {
+					covered[i+1] += rec.len
+				}
+			}
+			if chrlen, ok := chrlens[naming.Chr(chr)]; ok {
+				chrEnd = float64(chrlen)
+			}
+
+			lo, hi := 0, 0
+			for i, rec := range rs {
+				wstart, wend := rec.mid - args.WinSize / 2, rec.mid + args.WinSize / 2
+				if trailing {
+					wstart, wend = rec.mid - args.WinSize, rec.mid
+				}
+				for lo < len(rs) && rs[lo].mid < wstart {
+					lo++
+				}
+				for hi < len(rs) && rs[hi].mid <= wend {
+					hi++
+				}
+
+				wstart = math.Max(wstart, 0)
+				wend = math.Min(wend, chrEnd)
+				val := f(rs, i, lo, hi)
+				if wend > wstart && (covered[hi] - covered[lo]) / (wend - wstart) < args.MinCoverage {
+					val = math.NaN()
+				}
+
+				rec.line[args.Col] = strconv.FormatFloat(val, 'g', -1, 64)
+				fmt.Fprintln(bw, strings.Join(rec.line, "\t"))
+			}
+		}
+		return bw.Flush()
+	})
+}
+
+func smoothMedian(recs []smoothRec, i, lo, hi int) float64 {
+	var vals []float64
+	for _, rec := range recs[lo:hi] {
+		if !math.IsNaN(rec.val) {
+			vals = append(vals, rec.val)
+		}
+	}
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(vals)
+	n := len(vals)
+	if n % 2 == 1 {
+		return vals[n/2]
+	}
+	return (vals[n/2-1] + vals[n/2]) / 2
+}
+
+func smoothGaussian(sigma float64) smoother {
+	return func(recs []smoothRec, i, lo, hi int) float64 {
+		sum, wsum := 0.0, 0.0
+		for _, rec := range recs[lo:hi] {
+			if math.IsNaN(rec.val) {
+				continue
+			}
+			d := rec.mid - recs[i].mid
+			w := math.Exp(-d * d / (2 * sigma * sigma))
+			sum += w * rec.val
+			wsum += w
+		}
+		if wsum == 0 {
+			return math.NaN()
+		}
+		return sum / wsum
+	}
+}
+
+// Local linear regression with tricube weights, evaluated at recs[i]
+func smoothLoess(halfwidth float64) smoother {
+	return func(recs []smoothRec, i, lo, hi int) float64 {
+		x0 := recs[i].mid
+		var sw, swx, swy, swxx, swxy float64
+		for _, rec := range recs[lo:hi] {
+			if math.IsNaN(rec.val) {
+				continue
+			}
+			x := rec.mid - x0
+			u := math.Abs(x) / halfwidth
+			if u >= 1 {
+				// Keep a small weight at the window edge so a lone edge point still counts
+				u = 1 - 1e-9
+			}
+			t := 1 - u * u * u
+			w := t * t * t
+			sw += w
+			swx += w * x
+			swy += w * rec.val
+			swxx += w * x * x
+			swxy += w * x * rec.val
+		}
+		if sw == 0 {
+			return math.NaN()
+		}
+		denom := sw * swxx - swx * swx
+		if math.Abs(denom) < 1e-12 * sw * sw {
+			return swy / sw
+		}
+		slope := (sw * swxy - swx * swy) / denom
+		return (swy - slope * swx) / sw
+	}
+}
+
+// Exponential moving average along the chromosome, decaying by a factor of e
+// every winsize bp. Records without values leave the average unchanged.
+func smoothEma(winsize float64) smoother {
+	avg := math.NaN()
+	prev := 0.0
+	return func(recs []smoothRec, i, lo, hi int) float64 {
+		if i == 0 {
+			avg = math.NaN()
+		}
+		rec := recs[i]
+		if math.IsNaN(rec.val) {
+			return avg
+		}
+		if math.IsNaN(avg) {
+			avg = rec.val
+		} else {
+			avg += (1 - math.Exp(-(rec.mid - prev) / winsize)) * (rec.val - avg)
+		}
+		prev = rec.mid
+		return avg
+	}
+}
+
+// Get a smoothing pipeline function by name: "running_median",
+// "gaussian_smooth", "loess", or "ema". Args must be of type SmoothArgs.
+func Smooth(method string) func(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	return smoothFunc(method, func() ([]ChrLenSet, error) { return nil, nil }, ChrNaming{})
+}
+
+// Like Smooth, but windows end at the chromosome ends in the config's
+// chrlens, if it has one
+func SmoothCtx(method string, ctx MultiplotFuncCtx) func(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	return smoothFunc(method, ctx.chrLens, ctx.Cfg.Naming)
+}
+
+func smoothFunc(method string, getChrlens func() ([]ChrLenSet, error), naming ChrNaming) func(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+	return func(rs []io.Reader, anyargs any) ([]io.Reader, error) {
+		h := Handle("Smooth: %w")
+
+		var args SmoothArgs
+		err := UnmarshalJsonOut(anyargs, &args)
+		if err != nil { return nil, h(err) }
+		if args.WinSize <= 0 {
+			return nil, h(fmt.Errorf("WinSize %v must be positive", args.WinSize))
+		}
+		if args.Col == 0 {
+			args.Col = 3
+		}
+		if args.Sigma == 0 {
+			args.Sigma = args.WinSize / 4
+		}
+
+		var newf func() smoother
+		trailing := false
+		switch method {
+		case "running_median": newf = func() smoother { return smoothMedian }
+		case "gaussian_smooth": newf = func() smoother { return smoothGaussian(args.Sigma) }
+		case "loess": newf = func() smoother { return smoothLoess(args.WinSize / 2) }
+		case "ema":
+			// The average is stateful, so each reader gets its own
+			newf = func() smoother { return smoothEma(args.WinSize) }
+			trailing = true
+		default: return nil, h(fmt.Errorf("unknown method %q", method))
+		}
+
+		chrlens, err := getChrlens()
+		if err != nil { return nil, h(err) }
+
+		out := make([]io.Reader, len(rs))
+		for i, r := range rs {
+			out[i] = SmoothOneLens(r, args, trailing, newf(), chrLenMap(chrlens), naming)
+		}
+		return out, nil
+	}
+}
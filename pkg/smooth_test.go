@@ -0,0 +1,123 @@
+package covplots
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func runSmooth(method, in string, args SmoothArgs) []float64 {
+	rs, err := Smooth(method)([]io.Reader{strings.NewReader(in)}, args)
+	if err != nil {
+		panic(err)
+	}
+	out, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	var vals []float64
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		val, err := strconv.ParseFloat(strings.Split(line, "\t")[3], 64)
+		if err != nil {
+			panic(err)
+		}
+		vals = append(vals, val)
+	}
+	return vals
+}
+
+func closeFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) != math.IsNaN(b[i]) || (!math.IsNaN(a[i]) && math.Abs(a[i] - b[i]) > 1e-9) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRunningMedian(t *testing.T) {
+	// The spike is removed, and chr2 is not smoothed together with chr1
+	in := "chr1\t0\t10\t1\nchr1\t10\t20\t100\nchr1\t20\t30\t2\nchr2\t0\t10\t50\n"
+	expect := []float64{50.5, 2, 51, 50}
+	out := runSmooth("running_median", in, SmoothArgs{WinSize: 20})
+	if !closeFloats(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestSmoothMinCoverage(t *testing.T) {
+	// The last record is alone in its window, which is under 20% covered
+	in := "chr1\t0\t10\t1\nchr1\t10\t20\t1\nchr1\t20\t30\t1\nchr1\t1000\t1010\t1\n"
+	expect := []float64{1, 1, 1, math.NaN()}
+	out := runSmooth("gaussian_smooth", in, SmoothArgs{WinSize: 100, MinCoverage: 0.25})
+	if !closeFloats(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestLoessLinear(t *testing.T) {
+	in := "chr1\t0\t10\t5\nchr1\t10\t20\t15\nchr1\t20\t30\t25\nchr1\t30\t40\t35\n"
+	expect := []float64{5, 15, 25, 35}
+	out := runSmooth("loess", in, SmoothArgs{WinSize: 40})
+	if !closeFloats(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestEma(t *testing.T) {
+	in := "chr1\t0\t10\t0\nchr1\t10\t20\t10\n"
+	expect := []float64{0, 10 * (1 - math.Exp(-1))}
+	out := runSmooth("ema", in, SmoothArgs{WinSize: 10})
+	if !closeFloats(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
+
+func TestSmoothChrLens(t *testing.T) {
+	var in strings.Builder
+	for start := 0; start < 90; start += 10 {
+		in.WriteString("chr1_ISO1\t" + strconv.Itoa(start) + "\t" + strconv.Itoa(start + 10) + "\t1\n")
+	}
+	args := SmoothArgs{WinSize: 100, MinCoverage: 0.7}
+
+	// Without chrlens, the chromosome ends at the last record, so its window is covered
+	out := runSmooth("gaussian_smooth", in.String(), args)
+	if last := out[len(out)-1]; last != 1 {
+		t.Errorf("out %v != expect %v", last, 1)
+	}
+
+	chrlens := filepath.Join(t.TempDir(), "chrom.sizes")
+	if e := os.WriteFile(chrlens, []byte("chr1\t1000\n"), 0644); e != nil { panic(e) }
+	ctx := MultiplotFuncCtx{Cfg: UltimateConfig{Chrlens: chrlens}}
+	rs, err := SmoothCtx("gaussian_smooth", ctx)([]io.Reader{strings.NewReader(in.String())}, args)
+	if err != nil {
+		panic(err)
+	}
+	b, err := io.ReadAll(rs[0])
+	if err != nil {
+		panic(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if last := lines[len(lines)-1]; last != "chr1_ISO1\t80\t90\tNaN" {
+		t.Errorf("out %q != expect %q", last, "chr1_ISO1\t80\t90\tNaN")
+	}
+}
+
+func TestSmoothReadError(t *testing.T) {
+	in := io.MultiReader(strings.NewReader("chr1\t0\t10\t1\n"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	rs, err := Smooth("running_median")([]io.Reader{in}, SmoothArgs{WinSize: 20})
+	if err != nil {
+		panic(err)
+	}
+	if _, err := io.ReadAll(rs[0]); err == nil {
+		t.Errorf("no error for a failed read")
+	}
+}
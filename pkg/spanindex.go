@@ -0,0 +1,103 @@
+package covplots
+
+import (
+	"sort"
+)
+
+// An index of bed entries for finding every entry that overlaps a span.
+// Entries are sorted by start on each chromosome, with a running maximum of
+// their ends so that searches can stop early.
+type SpanIndex struct {
+	Entries []BedEntry
+	chrs map[string][]int
+	maxEnds map[string][]int64
+}
+
+func NewSpanIndex(entries []BedEntry) *SpanIndex {
+	idx := &SpanIndex{Entries: entries, chrs: map[string][]int{}, maxEnds: map[string][]int64{}}
+	for i, e := range entries {
+		idx.chrs[e.Chr] = append(idx.chrs[e.Chr], i)
+	}
+	for chr, is := range idx.chrs {
+		sort.SliceStable(is, func(a, b int) bool { return entries[is[a]].Start < entries[is[b]].Start })
+		maxEnds := make([]int64, len(is))
+		var max int64
+		for j, i := range is {
+			if j == 0 || entries[i].End > max {
+				max = entries[i].End
+			}
+			maxEnds[j] = max
+		}
+		idx.maxEnds[chr] = maxEnds
+	}
+	return idx
+}
+
+// Indices into idx.Entries of every entry overlapping the half-open span
+// start to end, in order of start
+func (idx *SpanIndex) Overlaps(chr string, start, end int64) []int {
+	is := idx.chrs[chr]
+	maxEnds := idx.maxEnds[chr]
+	hi := sort.Search(len(is), func(j int) bool { return idx.Entries[is[j]].Start >= end })
+
+	var out []int
+	for j := hi - 1; j >= 0 && maxEnds[j] > start; j-- {
+		if idx.Entries[is[j]].End > start {
+			out = append(out, is[j])
+		}
+	}
+	for a, b := 0, len(out)-1; a < b; a, b = a+1, b-1 {
+		out[a], out[b] = out[b], out[a]
+	}
+	return out
+}
+
+// The chromosome of idx to look up for a full name: the name itself if idx
+// has it, otherwise its chromosome part
+func (idx *SpanIndex) ChrFor(name string, naming ChrNaming) string {
+	if _, ok := idx.chrs[name]; ok {
+		return name
+	}
+	return naming.Chr(name)
+}
+
+// Indices of the entries nearest to the span on chr, in order of start, and
+// the number of bp between them and the span (0 if they overlap or touch).
+// Ok is false if chr has no entries.
+func (idx *SpanIndex) Nearest(chr string, start, end int64) (near []int, dist int64, ok bool) {
+	if ov := idx.Overlaps(chr, start, end); len(ov) > 0 {
+		return ov, 0, true
+	}
+	is := idx.chrs[chr]
+	maxEnds := idx.maxEnds[chr]
+	if len(is) == 0 {
+		return nil, 0, false
+	}
+
+	dist = -1
+	consider := func(i int, d int64) {
+		if dist < 0 || d < dist {
+			near = []int{i}
+			dist = d
+		} else if d == dist {
+			near = append(near, i)
+		}
+	}
+
+	// Entries starting at or after end: only those sharing the first start can be nearest
+	hi := sort.Search(len(is), func(j int) bool { return idx.Entries[is[j]].Start >= end })
+	for j := hi; j < len(is) && idx.Entries[is[j]].Start == idx.Entries[is[hi]].Start; j++ {
+		consider(is[j], idx.Entries[is[j]].Start - end)
+	}
+	// Entries before the span all end at or before start; stop once no
+	// earlier entry can end close enough
+	for j := hi - 1; j >= 0; j-- {
+		if dist >= 0 && start - maxEnds[j] > dist {
+			break
+		}
+		consider(is[j], start - idx.Entries[is[j]].End)
+	}
+
+	sort.Slice(near, func(a, b int) bool { return idx.Entries[near[a]].Start < idx.Entries[near[b]].Start })
+	return near, dist, true
+}
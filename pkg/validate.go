@@ -0,0 +1,131 @@
+package covplots
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Whether plotfunc draws with the built-in renderer, which writes the
+// formats, size, and resolution set in "plotoutput"
+func nativePlotfunc(plotfunc string) bool {
+	return strings.HasPrefix(plotfunc, "native_") || plotfunc == "plotspec"
+}
+
+// Whether plotfunc draws an interactive HTML plot
+func htmlPlotfunc(plotfunc string) bool {
+	_, ok := htmlPlotfuncs[plotfunc]
+	return ok
+}
+
+// Whether plotfunc draws input set styles. Of the R plot functions, only
+// plot_multi reads them.
+func styledPlotfunc(plotfunc string) bool {
+	switch {
+	case nativePlotfunc(plotfunc), htmlPlotfunc(plotfunc):
+		return true
+	case plotfunc == "", plotfunc == "plot_multi":
+		return true
+	}
+	return false
+}
+
+// The R plot functions whose scripts read a window's highlights file
+var highlightRPlotfuncs = map[string]struct{}{
+	"": {}, "plot_multi": {}, "plot_multi_facet": {}, "plot_multi_ribbon": {},
+	"plot_multi_facet_scales": {}, "plot_multi_facet_scales_boxed": {}, "plot_multi_facetname_scales": {},
+}
+
+// Whether plotfunc draws the config's highlights
+func highlightPlotfunc(plotfunc string) bool {
+	if nativePlotfunc(plotfunc) || htmlPlotfunc(plotfunc) {
+		return true
+	}
+	_, ok := highlightRPlotfuncs[plotfunc]
+	return ok
+}
+
+// Check that a config only asks for what its plot function can do. All
+// problems are reported together.
+func ValidateConfig(cfg UltimateConfig) error {
+	var errs Errors
+	for _, validate := range []func(UltimateConfig) error{ValidateFacets, ValidatePlotOutput, ValidateStyles, ValidateAutoYlim, ValidateHighlights} {
+		if err := validate(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ValidateConfig: %w", errs)
+	}
+	return nil
+}
+
+// Check that "plotoutput" is honored. The R and interactive plot functions
+// write their own files at their own size, so for them it may only turn on
+// the booklet and set the booklet's page size.
+func ValidatePlotOutput(cfg UltimateConfig) error {
+	if nativePlotfunc(cfg.Plotfunc) {
+		return nil
+	}
+	out := cfg.PlotOutput
+	var errs Errors
+	if len(out.Formats) > 0 {
+		errs = append(errs, fmt.Errorf("plotoutput formats %v need a native plot function, not %q", out.Formats, cfg.Plotfunc))
+	}
+	if out.DPI != 0 {
+		errs = append(errs, fmt.Errorf("plotoutput dpi %v needs a native plot function, not %q", out.DPI, cfg.Plotfunc))
+	}
+	if (out.Width != 0 || out.Height != 0) && !out.Booklet {
+		errs = append(errs, fmt.Errorf("plotoutput width and height only size the booklet with plot function %q", cfg.Plotfunc))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ValidatePlotOutput: config %q: %w", cfg.Outpre, errs)
+	}
+	return nil
+}
+
+// Check that no input set has a style that the plot function would ignore
+func ValidateStyles(cfg UltimateConfig) error {
+	if styledPlotfunc(cfg.Plotfunc) {
+		return nil
+	}
+	var styled []string
+	for _, set := range cfg.InputSets {
+		if set.Style != (SetStyle{}) {
+			styled = append(styled, set.Name)
+		}
+	}
+	if len(styled) > 0 {
+		return fmt.Errorf("ValidateStyles: config %q: input sets %q have styles, which plot function %q does not draw", cfg.Outpre, styled, cfg.Plotfunc)
+	}
+	return nil
+}
+
+func ValidateAutoYlim(cfg UltimateConfig) error {
+	if cfg.AutoYlim == nil {
+		return nil
+	}
+	if err := cfg.AutoYlim.Validate(); err != nil {
+		return fmt.Errorf("ValidateAutoYlim: config %q: %w", cfg.Outpre, err)
+	}
+	return nil
+}
+
+// Check each highlight, and that the plot function draws them
+func ValidateHighlights(cfg UltimateConfig) error {
+	if len(cfg.Highlights) == 0 {
+		return nil
+	}
+	var errs Errors
+	if !highlightPlotfunc(cfg.Plotfunc) {
+		errs = append(errs, fmt.Errorf("plot function %q does not draw highlights", cfg.Plotfunc))
+	}
+	for _, hl := range cfg.Highlights {
+		if err := hl.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ValidateHighlights: config %q: %w", cfg.Outpre, errs)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package covplots
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Drop NaN values, and their weights, from vals and weights. Negative or NaN
+// weights count as 0.
+func weightedNanFree(vals, weights []float64) ([]float64, []float64) {
+	var outv, outw []float64
+	for i, v := range vals {
+		if math.IsNaN(v) {
+			continue
+		}
+		w := weights[i]
+		if math.IsNaN(w) || w < 0 {
+			w = 0
+		}
+		outv = append(outv, v)
+		outw = append(outw, w)
+	}
+	return outv, outw
+}
+
+func sumFloats(vals []float64) float64 {
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	return sum
+}
+
+// Mean of vals, with each value counted weights[i] times. NaN values are skipped.
+func WeightedMean(vals, weights []float64) (float64, error) {
+	vals, weights = weightedNanFree(vals, weights)
+	total := sumFloats(weights)
+	if total == 0 {
+		return math.NaN(), fmt.Errorf("WeightedMean: total weight is 0")
+	}
+	sum := 0.0
+	for i, v := range vals {
+		sum += v * weights[i]
+	}
+	return sum / total, nil
+}
+
+// Population standard deviation of vals, with each value counted weights[i] times
+func WeightedStdDevP(vals, weights []float64) (float64, error) {
+	m, err := WeightedMean(vals, weights)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("WeightedStdDevP: %w", err)
+	}
+	vals, weights = weightedNanFree(vals, weights)
+	sum := 0.0
+	for i, v := range vals {
+		sum += weights[i] * (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / sumFloats(weights)), nil
+}
+
+// The smallest value at or above percent (0 to 100) of the total weight
+func WeightedPercentile(vals, weights []float64, percent float64) (float64, error) {
+	vals, weights = weightedNanFree(vals, weights)
+	total := sumFloats(weights)
+	if total == 0 {
+		return math.NaN(), fmt.Errorf("WeightedPercentile: total weight is 0")
+	}
+
+	idxs := make([]int, len(vals))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.Slice(idxs, func(i, j int) bool { return vals[idxs[i]] < vals[idxs[j]] })
+
+	target := total * percent / 100
+	cum := 0.0
+	for _, idx := range idxs {
+		cum += weights[idx]
+		if cum >= target && weights[idx] > 0 {
+			return vals[idx], nil
+		}
+	}
+	return vals[idxs[len(idxs)-1]], nil
+}
+
+func WeightedMedian(vals, weights []float64) (float64, error) {
+	return WeightedPercentile(vals, weights, 50)
+}
+
+func fitWeightedZscore(vals, weights []float64) normApply {
+	m, err := WeightedMean(vals, weights)
+	if err != nil {
+		m = 0
+	}
+	s, err := WeightedStdDevP(vals, weights)
+	if err != nil {
+		s = 1
+	}
+	return func(v, l float64) float64 { return (v - m) / s }
+}
+
+// Weighted z-score: subtract the weighted mean and divide by the weighted standard deviation
+func WeightedNormalizeFloats(vals, weights []float64) []float64 {
+	return applyNorm(fitWeightedZscore(vals, weights), vals, weights)
+}
+
+func fitWeightedRobustZ(vals, weights []float64) normApply {
+	med, err := WeightedMedian(vals, weights)
+	if err != nil {
+		med = 0
+	}
+	devs := mapFloats(vals, func(v float64) float64 { return math.Abs(v - med) })
+	mad, err := WeightedMedian(devs, weights)
+	if err != nil || mad == 0 {
+		mad = 1 / 1.4826
+	}
+	return robustZApply(med, mad)
+}
+
+// Like RobustZFloats, with the median and MAD weighted
+func WeightedRobustZFloats(vals, weights []float64) []float64 {
+	return applyNorm(fitWeightedRobustZ(vals, weights), vals, weights)
+}
+
+func fitWeightedPercentile(vals, weights []float64) normApply {
+	nfv, nfw := weightedNanFree(vals, weights)
+	idxs := make([]int, len(nfv))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.Slice(idxs, func(i, j int) bool { return nfv[idxs[i]] < nfv[idxs[j]] })
+
+	// cum[i] is the weight of the i smallest values
+	sorted := make([]float64, len(idxs))
+	cum := make([]float64, len(idxs) + 1)
+	for i, idx := range idxs {
+		sorted[i] = nfv[idx]
+		cum[i+1] = cum[i] + nfw[idx]
+	}
+	total := cum[len(idxs)]
+
+	return func(v, l float64) float64 {
+		if math.IsNaN(v) {
+			return v
+		}
+		below, equal := sortedRank(sorted, v)
+		return 100 * (cum[below] + (cum[below + equal] - cum[below]) / 2) / total
+	}
+}
+
+// Percentile rank of each value, as the percent of the total weight below it
+// plus half the weight equal to it
+func WeightedPercentileRankFloats(vals, weights []float64) []float64 {
+	return applyNorm(fitWeightedPercentile(vals, weights), vals, weights)
+}
+
+func fitWeightedWinsorize(vals, weights []float64, lowPerc, highPerc float64) normApply {
+	lo, err1 := WeightedPercentile(vals, weights, lowPerc)
+	hi, err2 := WeightedPercentile(vals, weights, highPerc)
+	if err1 != nil || err2 != nil {
+		return func(v, l float64) float64 { return v }
+	}
+	return clampApply(lo, hi)
+}
+
+// Like WinsorizeFloats, with weighted percentiles
+func WeightedWinsorizeFloats(vals, weights []float64, lowPerc, highPerc float64) []float64 {
+	return applyNorm(fitWeightedWinsorize(vals, weights, lowPerc, highPerc), vals, weights)
+}
+
+// The span length (end - start) of every bed entry, for use as weights
+func BedSpanLens(entries ...BedEntry) []float64 {
+	out := make([]float64, len(entries))
+	for i, e := range entries {
+		out[i] = float64(e.End - e.Start)
+	}
+	return out
+}